@@ -0,0 +1,150 @@
+// Package clipboard connects the editor's copy buffer to the system
+// clipboard: through the terminal (OSC 52 escape sequences, which work over
+// SSH and in tmux), or through helper programs such as xclip and wl-copy.
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrNoPaste is returned by clipboards which can be written but not read.
+// The editor pastes from its own copy buffer instead.
+var ErrNoPaste = errors.New("clipboard can't be read")
+
+// timeout is how long a helper program gets to run.
+const timeout = 2 * time.Second
+
+// Clipboard is a system clipboard.
+type Clipboard interface {
+	// Copy puts text on the clipboard.
+	Copy(text string) error
+	// Paste returns the text on the clipboard.
+	Paste() (string, error)
+}
+
+// helpers are the clipboard programs, by name: the command to copy (which
+// reads standard input), and the command to paste (which writes standard
+// output).
+var helpers = map[string][2][]string{
+	"xclip":   {{"xclip", "-selection", "clipboard"}, {"xclip", "-selection", "clipboard", "-o"}},
+	"xsel":    {{"xsel", "--clipboard", "--input"}, {"xsel", "--clipboard", "--output"}},
+	"wl-copy": {{"wl-copy"}, {"wl-paste", "--no-newline"}},
+	"pbcopy":  {{"pbcopy"}, {"pbpaste"}},
+}
+
+// New returns the clipboard with a name: "internal" (none; the editor
+// keeps to its own copy buffer), "osc52", "xclip", "xsel", "wl-copy",
+// "pbcopy" or "auto" (a helper program if there is one for the display,
+// or else OSC 52). The OSC 52 clipboard sends the text to setTerm.
+func New(name string, setTerm func([]byte)) (Clipboard, error) {
+	switch name {
+	case "", "internal":
+		return Internal{}, nil
+	case "osc52":
+		return OSC52{set: setTerm}, nil
+	case "auto":
+		return auto(setTerm), nil
+	}
+	cmds, ok := helpers[name]
+	if !ok {
+		return Internal{}, fmt.Errorf("unknown clipboard %q", name)
+	}
+	return Command{CopyCmd: cmds[0], PasteCmd: cmds[1]}, nil
+}
+
+// auto picks a clipboard to suit the environment. Over SSH, the display's
+// clipboard (if any) is on the wrong machine, so only the terminal will do.
+func auto(setTerm func([]byte)) Clipboard {
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return OSC52{set: setTerm}
+	}
+	names := []string{"pbcopy"}
+	if os.Getenv("DISPLAY") != "" {
+		names = append([]string{"xclip", "xsel"}, names...)
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		names = append([]string{"wl-copy"}, names...)
+	}
+	for _, name := range names {
+		cmds := helpers[name]
+		if _, err := exec.LookPath(cmds[0][0]); err == nil {
+			return Command{CopyCmd: cmds[0], PasteCmd: cmds[1]}
+		}
+	}
+	return OSC52{set: setTerm}
+}
+
+// Internal is no system clipboard at all.
+type Internal struct{}
+
+// Copy does nothing.
+func (Internal) Copy(text string) error {
+	return nil
+}
+
+// Paste always fails with ErrNoPaste.
+func (Internal) Paste() (string, error) {
+	return "", ErrNoPaste
+}
+
+// OSC52 sets the clipboard with the OSC 52 terminal escape sequence. Few
+// terminals allow the clipboard to be read back, so it can't be pasted
+// from (text pasted into the terminal arrives as typing instead).
+type OSC52 struct {
+	set func([]byte)
+}
+
+// Copy sends the text to the terminal.
+func (clip OSC52) Copy(text string) error {
+	if clip.set == nil {
+		return errors.New("no terminal")
+	}
+	clip.set([]byte(text))
+	return nil
+}
+
+// Paste always fails with ErrNoPaste.
+func (clip OSC52) Paste() (string, error) {
+	return "", ErrNoPaste
+}
+
+// Command uses helper programs: CopyCmd reads the text to copy on standard
+// input, and PasteCmd writes the clipboard to standard output.
+type Command struct {
+	CopyCmd  []string
+	PasteCmd []string
+}
+
+// Copy runs the copy command. Its output is ignored, as some helpers (such
+// as xclip) stay in the background to serve the clipboard.
+func (clip Command) Copy(text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, clip.CopyCmd[0], clip.CopyCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", clip.CopyCmd[0], err)
+	}
+	return nil
+}
+
+// Paste runs the paste command, and returns its output.
+func (clip Command) Paste() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, clip.PasteCmd[0], clip.PasteCmd[1:]...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s: %v", clip.PasteCmd[0], err)
+	}
+	return string(out), nil
+}
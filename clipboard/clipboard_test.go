@@ -0,0 +1,56 @@
+package clipboard_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/wx13/sith/clipboard"
+)
+
+func TestNew(t *testing.T) {
+	clip, err := clipboard.New("internal", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clip.Paste(); !errors.Is(err, clipboard.ErrNoPaste) {
+		t.Error("internal clipboard should not paste:", err)
+	}
+	if _, err := clipboard.New("bogus", nil); err == nil {
+		t.Error("expected an error for an unknown clipboard")
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	var sent []byte
+	clip, _ := clipboard.New("osc52", func(data []byte) { sent = data })
+	if err := clip.Copy("hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	if string(sent) != "hello\n" {
+		t.Errorf("wrong text sent to the terminal: %q", sent)
+	}
+	if _, err := clip.Paste(); !errors.Is(err, clipboard.ErrNoPaste) {
+		t.Error("OSC 52 clipboard should not paste:", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "clip")
+	clip := clipboard.Command{
+		CopyCmd:  []string{"sh", "-c", "cat > " + name},
+		PasteCmd: []string{"cat", name},
+	}
+	if err := clip.Copy("one\ntwo"); err != nil {
+		t.Fatal(err)
+	}
+	text, err := clip.Paste()
+	if err != nil || text != "one\ntwo" {
+		t.Errorf("expected the copied text, got %q, %v", text, err)
+	}
+
+	clip.PasteCmd = []string{"sh", "-c", "echo oops >&2; exit 1"}
+	if _, err := clip.Paste(); err == nil || err.Error() != "sh: oops" {
+		t.Error("expected the helper's error message, got", err)
+	}
+}
@@ -26,13 +26,65 @@ type Config struct {
 	FmtCmd     string
 	FmtCmd_set bool
 
+	// LspCmd is the language server command (e.g. "gopls").
+	LspCmd     string
+	LspCmd_set bool
+
+	// BuildCmd and TestCmd are the shell commands which build and test
+	// the project (e.g. "go build ./...").
+	BuildCmd     string
+	BuildCmd_set bool
+	TestCmd      string
+	TestCmd_set  bool
+
+	// ErrorFormat is a list of regular expressions which find errors in
+	// the output of the build and test commands. The named groups "file"
+	// and "line" (and optionally "col" and "msg") locate each error.
+	ErrorFormat     []string
+	ErrorFormat_set bool
+
+	// Backup is the kind of backup made on save: "none", "tilde" (file~)
+	// or "timestamp" (file.20060102-150405~).
+	Backup     string
+	Backup_set bool
+
+	// SoftWrap wraps long lines on screen (instead of scrolling sideways).
+	SoftWrap     bool
+	SoftWrap_set bool
+
+	// LineNumbers shows line numbers in the gutter: "none", "absolute",
+	// "relative" (to the cursor) or "hybrid" (relative, but absolute on
+	// the cursor line).
+	LineNumbers     string
+	LineNumbers_set bool
+
+	// Theme is the name of the color theme.
+	Theme     string
+	Theme_set bool
+
+	// LargeFile is the size (in megabytes) over which a file is opened
+	// read-only, and syntax coloring, diffs and completion are off.
+	LargeFile     int
+	LargeFile_set bool
+
+	// Clipboard is the system clipboard which cuts and copies go to (and
+	// pastes come from): "internal" (none), "osc52" (through the
+	// terminal), "xclip", "xsel", "wl-copy", "pbcopy" or "auto".
+	Clipboard     string
+	Clipboard_set bool
+
 	Parent      string
 	ExtMap      map[string]string
 	SyntaxRules map[string]Color
 	FileConfigs map[string]Config
+
+	// Key bindings: key name => action ID.
+	Keys      map[string]string
+	ExtraKeys map[string]string
 }
 
-// Color defines background/forground color and attributes for text.
+// Color defines background/forground color and attributes for text. Colors
+// are either theme roles (e.g. "comment") or colors (e.g. "red", "#ff8800").
 // The 'Clobber' bool says to color this pattern even if it is nested
 // within another.
 type Color struct {
@@ -83,10 +135,30 @@ func (config *Config) markAsSet(meta toml.MetaData, prefix string) {
 			config.AutoTab_set = true
 		case prefix + "fmtcmd":
 			config.FmtCmd_set = true
+		case prefix + "lspcmd":
+			config.LspCmd_set = true
+		case prefix + "buildcmd":
+			config.BuildCmd_set = true
+		case prefix + "testcmd":
+			config.TestCmd_set = true
+		case prefix + "errorformat":
+			config.ErrorFormat_set = true
+		case prefix + "backup":
+			config.Backup_set = true
+		case prefix + "softwrap":
+			config.SoftWrap_set = true
+		case prefix + "linenumbers":
+			config.LineNumbers_set = true
+		case prefix + "theme":
+			config.Theme_set = true
 		case prefix + "tabdetect":
 			config.TabDetect_set = true
 		case prefix + "linelen":
 			config.LineLen_set = true
+		case prefix + "largefile":
+			config.LargeFile_set = true
+		case prefix + "clipboard":
+			config.Clipboard_set = true
 		}
 	}
 }
@@ -94,22 +166,44 @@ func (config *Config) markAsSet(meta toml.MetaData, prefix string) {
 // Dup deep copies a Config struct.
 func (config Config) Dup() Config {
 	newCfg := Config{
-		TabString:     config.TabString,
-		TabString_set: config.TabString_set,
-		TabWidth:      config.TabWidth,
-		TabWidth_set:  config.TabWidth_set,
-		AutoTab:       config.AutoTab,
-		AutoTab_set:   config.AutoTab_set,
-		TabDetect:     config.TabDetect,
-		TabDetect_set: config.TabDetect_set,
-		LineLen:       config.LineLen,
-		LineLen_set:   config.LineLen_set,
-		FmtCmd:        config.FmtCmd,
-		FmtCmd_set:    config.FmtCmd_set,
-		Parent:        config.Parent,
-		ExtMap:        map[string]string{},
-		FileConfigs:   map[string]Config{},
-		SyntaxRules:   map[string]Color{},
+		TabString:       config.TabString,
+		TabString_set:   config.TabString_set,
+		TabWidth:        config.TabWidth,
+		TabWidth_set:    config.TabWidth_set,
+		AutoTab:         config.AutoTab,
+		AutoTab_set:     config.AutoTab_set,
+		TabDetect:       config.TabDetect,
+		TabDetect_set:   config.TabDetect_set,
+		LineLen:         config.LineLen,
+		LineLen_set:     config.LineLen_set,
+		FmtCmd:          config.FmtCmd,
+		FmtCmd_set:      config.FmtCmd_set,
+		LspCmd:          config.LspCmd,
+		LspCmd_set:      config.LspCmd_set,
+		BuildCmd:        config.BuildCmd,
+		BuildCmd_set:    config.BuildCmd_set,
+		TestCmd:         config.TestCmd,
+		TestCmd_set:     config.TestCmd_set,
+		ErrorFormat:     append([]string{}, config.ErrorFormat...),
+		ErrorFormat_set: config.ErrorFormat_set,
+		Backup:          config.Backup,
+		Backup_set:      config.Backup_set,
+		SoftWrap:        config.SoftWrap,
+		SoftWrap_set:    config.SoftWrap_set,
+		LineNumbers:     config.LineNumbers,
+		LineNumbers_set: config.LineNumbers_set,
+		Theme:           config.Theme,
+		Theme_set:       config.Theme_set,
+		LargeFile:       config.LargeFile,
+		LargeFile_set:   config.LargeFile_set,
+		Clipboard:       config.Clipboard,
+		Clipboard_set:   config.Clipboard_set,
+		Parent:          config.Parent,
+		ExtMap:          map[string]string{},
+		FileConfigs:     map[string]Config{},
+		SyntaxRules:     map[string]Color{},
+		Keys:            map[string]string{},
+		ExtraKeys:       map[string]string{},
 	}
 	for k, v := range config.ExtMap {
 		newCfg.ExtMap[k] = v
@@ -120,6 +214,12 @@ func (config Config) Dup() Config {
 	for k, v := range config.SyntaxRules {
 		newCfg.SyntaxRules[k] = v.Dup()
 	}
+	for k, v := range config.Keys {
+		newCfg.Keys[k] = v
+	}
+	for k, v := range config.ExtraKeys {
+		newCfg.ExtraKeys[k] = v
+	}
 	return newCfg
 }
 
@@ -145,6 +245,12 @@ func (config Config) Merge(other Config) Config {
 	for pattern, color := range other.SyntaxRules {
 		config.SyntaxRules[pattern] = color
 	}
+	for key, id := range other.Keys {
+		config.Keys[key] = id
+	}
+	for key, id := range other.ExtraKeys {
+		config.ExtraKeys[key] = id
+	}
 
 	// Set the elementary values.
 	if other.AutoTab_set {
@@ -174,6 +280,46 @@ func (config Config) Merge(other Config) Config {
 		config.FmtCmd = other.FmtCmd
 		config.FmtCmd_set = true
 	}
+	if other.LspCmd_set {
+		config.LspCmd = other.LspCmd
+		config.LspCmd_set = true
+	}
+	if other.BuildCmd_set {
+		config.BuildCmd = other.BuildCmd
+		config.BuildCmd_set = true
+	}
+	if other.TestCmd_set {
+		config.TestCmd = other.TestCmd
+		config.TestCmd_set = true
+	}
+	if other.ErrorFormat_set {
+		config.ErrorFormat = other.ErrorFormat
+		config.ErrorFormat_set = true
+	}
+	if other.Backup_set {
+		config.Backup = other.Backup
+		config.Backup_set = true
+	}
+	if other.SoftWrap_set {
+		config.SoftWrap = other.SoftWrap
+		config.SoftWrap_set = true
+	}
+	if other.LineNumbers_set {
+		config.LineNumbers = other.LineNumbers
+		config.LineNumbers_set = true
+	}
+	if other.Theme_set {
+		config.Theme = other.Theme
+		config.Theme_set = true
+	}
+	if other.LargeFile_set {
+		config.LargeFile = other.LargeFile
+		config.LargeFile_set = true
+	}
+	if other.Clipboard_set {
+		config.Clipboard = other.Clipboard
+		config.Clipboard_set = true
+	}
 
 	return config
 }
@@ -56,6 +56,60 @@ func TestMarkAsSet(t *testing.T) {
 
 }
 
+func TestLspCmd(t *testing.T) {
+	contents := "" +
+		"[fileconfigs.go]\n" +
+		"  lspcmd = \"gopls serve\"\n"
+	path := writeTempFile(contents)
+	defer os.Remove(path)
+	cfg := config.Read(path)
+
+	if cmd := cfg.ForExt("go").LspCmd; cmd != "gopls serve" {
+		t.Errorf("expected gopls, got %q", cmd)
+	}
+	if cmd := cfg.ForExt("py").LspCmd; cmd != "" {
+		t.Errorf("expected no server for python, got %q", cmd)
+	}
+}
+
+func TestBuildCmd(t *testing.T) {
+	contents := "" +
+		"errorFormat = ['^(?P<file>.+)\\((?P<line>\\d+)\\)']\n" +
+		"[fileconfigs.go]\n" +
+		"  buildCmd = \"go build ./...\"\n" +
+		"  testCmd = \"go test ./...\"\n"
+	path := writeTempFile(contents)
+	defer os.Remove(path)
+	cfg := config.Read(path)
+
+	goCfg := cfg.ForExt("go")
+	if goCfg.BuildCmd != "go build ./..." || goCfg.TestCmd != "go test ./..." {
+		t.Errorf("wrong go commands: %q, %q", goCfg.BuildCmd, goCfg.TestCmd)
+	}
+	if len(goCfg.ErrorFormat) != 1 || goCfg.ErrorFormat[0] != `^(?P<file>.+)\((?P<line>\d+)\)` {
+		t.Errorf("wrong error format: %q", goCfg.ErrorFormat)
+	}
+	if cmd := cfg.ForExt("py").BuildCmd; cmd != "" {
+		t.Errorf("expected no build command for python, got %q", cmd)
+	}
+}
+
+func TestSoftWrap(t *testing.T) {
+	contents := "" +
+		"[fileconfigs.txt]\n" +
+		"  softWrap = true\n"
+	path := writeTempFile(contents)
+	defer os.Remove(path)
+	cfg := config.Read(path)
+
+	if !cfg.ForExt("txt").SoftWrap {
+		t.Error("expected soft wrap for txt files")
+	}
+	if cfg.ForExt("go").SoftWrap {
+		t.Error("expected no soft wrap for go files")
+	}
+}
+
 func TestReadUppercase(t *testing.T) {
 	contents := "" +
 		"AutoTab = true\n" +
@@ -74,6 +128,30 @@ func TestReadUppercase(t *testing.T) {
 	}
 }
 
+func TestReadKeys(t *testing.T) {
+	contents := "" +
+		"[keys]\n" +
+		"  ctrlU = \"redo\"\n" +
+		"  ctrlY = \"undo\"\n" +
+		"[extraKeys]\n" +
+		"  x = \"save-all\"\n"
+	path := writeTempFile(contents)
+	defer os.Remove(path)
+	cfg := config.Read(path)
+	if cfg.Keys["ctrlU"] != "redo" || cfg.Keys["ctrlY"] != "undo" {
+		t.Errorf("Keys not read: %+v\n", cfg.Keys)
+	}
+	if cfg.ExtraKeys["x"] != "save-all" {
+		t.Errorf("ExtraKeys not read: %+v\n", cfg.ExtraKeys)
+	}
+
+	cfg = config.Config{Keys: map[string]string{"ctrlU": "undo", "ctrlY": "redo"}}
+	cfg = cfg.Merge(config.Config{Keys: map[string]string{"ctrlU": "redo"}})
+	if cfg.Keys["ctrlU"] != "redo" || cfg.Keys["ctrlY"] != "redo" {
+		t.Errorf("Keys did not merge: %+v\n", cfg.Keys)
+	}
+}
+
 func TestMerge(t *testing.T) {
 
 	cfg := config.Config{
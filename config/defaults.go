@@ -40,7 +40,7 @@ func defaultExtMap() map[string]string {
 
 func defaultSyntaxRules() map[string]Color {
 	sr := map[string]Color{
-		"[ \t]+$": {BG: "yellow", Clobber: true},
+		"[ \t]+$": {BG: "whitespace", Clobber: true},
 	}
 	return sr
 }
@@ -49,53 +49,57 @@ func defaultFileConfigs() map[string]Config {
 	fc := map[string]Config{}
 	fc["code"] = Config{
 		SyntaxRules: map[string]Color{
-			"'.*?'": {FG: "yellow"},
-			`".*?"`: {FG: "yellow"},
+			"'.*?'": {FG: "string"},
+			`".*?"`: {FG: "string"},
 		},
 	}
 	fc["sh"] = Config{
 		Parent: "code",
 		SyntaxRules: map[string]Color{
-			"#.*$": {FG: "cyan"},
+			"#.*$": {FG: "comment"},
 		},
 	}
 	fc["c-style"] = Config{
 		Parent: "code",
 		SyntaxRules: map[string]Color{
-			"//.*$":     {FG: "cyan"},
-			`/\*.*?\*/`: {FG: "cyan"},
+			"//.*$":     {FG: "comment"},
+			`/\*.*?\*/`: {FG: "comment"},
 		},
 	}
 	fc["c"] = Config{
 		Parent: "c-style",
 		SyntaxRules: map[string]Color{
-			"^#[a-z]*": {FG: "blue"},
+			"^#[a-z]*": {FG: "preproc"},
 		},
 	}
 	fc["go"] = Config{
 		Parent: "code",
 		SyntaxRules: map[string]Color{
-			"//.*$": {FG: "cyan"},
-			"'.*?'": {FG: "red"},
-			"`.*?`": {FG: "yellow"},
+			"//.*$": {FG: "comment"},
+			"'.*?'": {FG: "constant"},
+			"`.*?`": {FG: "string"},
 		},
 	}
 	fc["md"] = Config{
+		SoftWrap:     true,
+		SoftWrap_set: true,
 		SyntaxRules: map[string]Color{
-			"^#+.*$": {FG: "green"},
-			"^===*$": {FG: "green"},
-			"^---*$": {FG: "green"},
+			"^#+.*$": {FG: "heading"},
+			"^===*$": {FG: "heading"},
+			"^---*$": {FG: "heading"},
 		},
 	}
 	fc["toml"] = Config{
 		Parent: "sh",
 		SyntaxRules: map[string]Color{
-			`\[.*?\]`: {FG: "green"},
+			`\[.*?\]`: {FG: "heading"},
 		},
 	}
 	fc["git_commit"] = Config{
+		SoftWrap:     true,
+		SoftWrap_set: true,
 		SyntaxRules: map[string]Color{
-			"#.*?$": {FG: "cyan"},
+			"#.*?$": {FG: "comment"},
 		},
 	}
 	fc["git_rebase"] = Config{
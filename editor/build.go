@@ -0,0 +1,207 @@
+package editor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/quickfix"
+	"github.com/wx13/sith/ui"
+)
+
+// buildTimeout is how long a build (or test) command gets to run.
+const buildTimeout = 10 * time.Minute
+
+// builder runs build and test commands in the background. Starting a
+// command cancels the one before it.
+type builder struct {
+	gen    int
+	cancel context.CancelFunc
+}
+
+// buildResult is the outcome of a build command.
+type buildResult struct {
+	gen     int
+	command string
+	output  string
+	formats []*regexp.Regexp
+	err     error
+}
+
+// Build runs the current file's build command.
+func (editor *Editor) Build() {
+	editor.startBuild("build", editor.file.BuildCmd())
+}
+
+// Test runs the current file's test command.
+func (editor *Editor) Test() {
+	editor.startBuild("test", editor.file.TestCmd())
+}
+
+// startBuild runs a build (or test) command in the background. The
+// keyboard hands the result back once it is done (see collectBuild), so
+// the editor carries on meanwhile.
+func (editor *Editor) startBuild(kind, command string) {
+	if command == "" {
+		editor.screen.Notify("No " + kind + " command for this filetype")
+		return
+	}
+	formats, err := quickfix.Compile(editor.file.ErrorFormat())
+	if err != nil {
+		editor.screen.Notify(err.Error())
+		return
+	}
+
+	b := &editor.builder
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.gen++
+	gen := b.gen
+	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+	b.cancel = cancel
+
+	keyboard := editor.keyboard
+	go func() {
+		output, err := runBuild(ctx, command)
+		cancel()
+		result := buildResult{gen, command, output, formats, err}
+		keyboard.Post(func() { editor.collectBuild(result) })
+	}()
+	editor.screen.Notify("Running " + command)
+}
+
+// runBuild runs a shell command, and returns its output (standard output
+// and standard error together).
+func runBuild(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = errors.New("timed out")
+	case errors.Is(ctx.Err(), context.Canceled):
+		err = errors.New("cancelled")
+	}
+	return string(out), err
+}
+
+// collectBuild picks up the errors from a build command which has
+// finished, and marks them in the open files. Results from a command which
+// was superseded (and cancelled) are dropped.
+func (editor *Editor) collectBuild(result buildResult) {
+	if result.gen != editor.builder.gen {
+		return
+	}
+
+	// Skip matches which aren't really files (such as URLs).
+	errs := []quickfix.Error{}
+	for _, e := range quickfix.Parse(result.output, result.formats) {
+		if _, err := os.Stat(e.Path); err == nil || editor.findFile(e.Path) >= 0 {
+			errs = append(errs, e)
+		}
+	}
+	editor.errors = quickfix.NewList(errs)
+	editor.buildOutput = result.output
+	for _, f := range editor.files {
+		editor.markErrors(f)
+	}
+
+	switch {
+	case len(errs) == 1:
+		editor.file.NotifyUser(result.command + ": 1 error")
+	case len(errs) > 1:
+		editor.file.NotifyUser(fmt.Sprintf("%s: %d errors", result.command, len(errs)))
+	case result.err != nil:
+		editor.file.NotifyUser(result.command + ": " + result.err.Error())
+	default:
+		editor.file.NotifyUser(result.command + ": OK")
+	}
+}
+
+// markErrors marks the rows of a file with errors from the last build.
+func (editor *Editor) markErrors(f *file.File) {
+	if editor.errors == nil || f.Name == "" {
+		return
+	}
+	f.SetBuildErrors(editor.errors.Rows(f.Name))
+}
+
+// NextError jumps to the next error from the last build.
+func (editor *Editor) NextError() {
+	if editor.errors == nil {
+		editor.screen.Notify("No errors")
+		return
+	}
+	editor.goToError(editor.errors.Next())
+}
+
+// PrevError jumps to the previous error from the last build.
+func (editor *Editor) PrevError() {
+	if editor.errors == nil {
+		editor.screen.Notify("No errors")
+		return
+	}
+	editor.goToError(editor.errors.Prev())
+}
+
+// ErrorList offers a menu of the errors from the last build, and jumps to
+// the chosen one.
+func (editor *Editor) ErrorList() {
+	if editor.errors == nil || editor.errors.Len() == 0 {
+		editor.screen.Notify("No errors")
+		return
+	}
+	choices := make([]string, editor.errors.Len())
+	for k, e := range editor.errors.Errors {
+		choices[k] = e.String()
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	idx, key := menu.Choose(choices, max(editor.errors.Index(), 0), "")
+	editor.Flush()
+	if key == "cancel" || idx < 0 {
+		return
+	}
+	editor.goToError(editor.errors.Select(idx))
+}
+
+// BuildOutput shows the output of the last build in a new, read-only
+// buffer.
+func (editor *Editor) BuildOutput() {
+	if editor.errors == nil {
+		editor.screen.Notify("Nothing has been built")
+		return
+	}
+	scratch := file.NewScratch("[build]", editor.buildOutput, editor.flushChan, editor.screen, editor.cfg)
+	editor.files = append(editor.files, scratch)
+	editor.SwitchFile(len(editor.files) - 1)
+}
+
+// goToError switches to an error's file (opening it if need be), and
+// moves the cursor to the error.
+func (editor *Editor) goToError(e quickfix.Error, ok bool) {
+	if !ok {
+		editor.screen.Notify("No errors")
+		return
+	}
+	if err := editor.SwitchFileByName(e.Path); err != nil {
+		editor.switchToFile(e.Path)
+	}
+	// Compilers count columns in bytes.
+	col := e.Col
+	if e.Row < editor.file.Length() {
+		if line := editor.file.GetLine(e.Row); col <= len(line) {
+			col = utf8.RuneCountInString(line[:col])
+		}
+	}
+	editor.file.ClearCursors()
+	editor.file.CursorGoTo(e.Row, col)
+	editor.file.NotifyUser(fmt.Sprintf("Error %d of %d: %s",
+		editor.errors.Index()+1, editor.errors.Len(), e.Message))
+}
@@ -1,18 +1,29 @@
 package editor
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/wx13/sith/clipboard"
 	"github.com/wx13/sith/ui"
 )
 
+// clip is a saved copy buffer. A partial clip holds a character-wise
+// selection rather than whole lines.
+type clip struct {
+	lines   []string
+	partial bool
+}
+
 // Keeps track of the current copy buffer and a history of copy buffers.
 type CopyBuffer struct {
 	// Current copy buffer is a set of lines.
 	current []string
+	// partial is true if the current buffer is a character-wise selection.
+	partial bool
 	// History is a set of buffers.
-	history [][]string
+	history []clip
 	// contig keeps track of whether or not cuts are contiguous
 	contig int
 	// Maximum history elements to store.
@@ -23,7 +34,7 @@ type CopyBuffer struct {
 func NewCopyBuffer() *CopyBuffer {
 	return &CopyBuffer{
 		current: []string{},
-		history: [][]string{},
+		history: []clip{},
 		contig:  0,
 		maxHist: 100,
 	}
@@ -37,15 +48,39 @@ func (cb *CopyBuffer) NoOp() {
 
 // Adds a set of lines to the copy buffer.
 func (cb *CopyBuffer) Cut(lines ...string) {
-	if cb.contig > 0 {
+	if cb.contig > 0 && !cb.partial {
 		cb.current = append(cb.current, lines...)
 	} else {
 		cb.Save()
 		cb.current = lines
 	}
+	cb.partial = false
 	cb.contig = 2
 }
 
+// CutPartial sets the copy buffer to a character-wise selection. Partial
+// cuts are never joined with neighboring cuts.
+func (cb *CopyBuffer) CutPartial(lines ...string) {
+	cb.Save()
+	cb.current = lines
+	cb.partial = true
+	cb.contig = 0
+}
+
+// Store sets the copy buffer to text from elsewhere (such as the system
+// clipboard). It is never joined with neighboring cuts.
+func (cb *CopyBuffer) Store(partial bool, lines ...string) {
+	cb.Save()
+	cb.current = lines
+	cb.partial = partial
+	cb.contig = 0
+}
+
+// IsPartial returns true if the current buffer is a character-wise selection.
+func (cb *CopyBuffer) IsPartial() bool {
+	return cb.partial
+}
+
 // Saves the current copy buffer to history.
 func (cb *CopyBuffer) Save() {
 	if len(cb.current) == 0 {
@@ -56,14 +91,14 @@ func (cb *CopyBuffer) Save() {
 	tmp := cb.history[:0]
 	cur := strings.Join(cb.current, "\n")
 	for _, buf := range cb.history {
-		if strings.Join(buf, "\n") != cur {
+		if strings.Join(buf.lines, "\n") != cur || buf.partial != cb.partial {
 			tmp = append(tmp, buf)
 		}
 	}
 	cb.history = tmp
 
 	// Prepend the current buffer to the history list.
-	cb.history = append([][]string{cb.current}, cb.history...)
+	cb.history = append([]clip{{cb.current, cb.partial}}, cb.history...)
 
 	// Ensure the list is not too long.
 	if len(cb.history) > cb.maxHist {
@@ -81,28 +116,61 @@ func (cb *CopyBuffer) Join(delim string) []string {
 	cb.Save()
 	items := []string{}
 	for _, buffer := range cb.history {
-		str := strings.Join(buffer, delim)
+		str := strings.Join(buffer.lines, delim)
 		items = append(items, str)
 	}
 	return items
 }
 
-// Returns the Nth buffer from history.
-func (cb *CopyBuffer) History(index int) ([]string, error) {
-	if (index < 0) || (index > len(cb.history)) {
-		return cb.history[0], fmt.Errorf("index out of range")
+// Returns the Nth buffer from history, and whether or not it is partial.
+func (cb *CopyBuffer) History(index int) ([]string, bool, error) {
+	if (index < 0) || (index >= len(cb.history)) {
+		return nil, false, fmt.Errorf("index out of range")
 	}
-	return cb.history[index], nil
+	return cb.history[index].lines, cb.history[index].partial, nil
 }
 
-// Cut cuts the current line and sticks it in the copy buffer.
+// Cut cuts the selection (or else the current line) and sticks it in the
+// copy buffer. A read-only file is only copied from.
 func (editor *Editor) Cut() {
-	editor.copyBuffer.Cut(editor.file.Cut()...)
+	if editor.file.ReadOnly() {
+		editor.Copy()
+		return
+	}
+	if editor.file.HasSelection() {
+		editor.copyBuffer.CutPartial(editor.file.CutSelection()...)
+	} else {
+		editor.copyBuffer.Cut(editor.file.Cut()...)
+	}
+	editor.toClipboard()
+}
+
+// Copy copies the selection (or else the current line) into the copy buffer.
+func (editor *Editor) Copy() {
+	if editor.file.HasSelection() {
+		editor.copyBuffer.CutPartial(editor.file.SelectedText()...)
+		editor.file.ClearSelection()
+	} else {
+		row, _ := editor.file.GetRowCol(0)
+		editor.copyBuffer.Cut(editor.file.GetLine(row))
+	}
+	editor.toClipboard()
 }
 
-// Paste pastes the current copy buffer into the main buffer.
+// Paste pastes the current copy buffer into the main buffer. Text copied
+// to the system clipboard by another program takes over the copy buffer
+// first.
 func (editor *Editor) Paste() {
-	editor.file.Paste(editor.copyBuffer.Paste())
+	editor.fromClipboard()
+	editor.paste(editor.copyBuffer.Paste(), editor.copyBuffer.IsPartial())
+}
+
+func (editor *Editor) paste(lines []string, partial bool) {
+	if partial {
+		editor.file.PasteText(lines)
+	} else {
+		editor.file.Paste(lines)
+	}
 }
 
 // PasteFromMenu allows the user to select from the paste history.
@@ -113,9 +181,47 @@ func (editor *Editor) PasteFromMenu() {
 	if name != "" {
 		return
 	}
-	buf, err := editor.copyBuffer.History(idx)
+	buf, partial, err := editor.copyBuffer.History(idx)
 	if err == nil {
-		editor.file.Paste(buf)
-		editor.copyBuffer.Cut(buf...)
+		editor.paste(buf, partial)
+		if partial {
+			editor.copyBuffer.CutPartial(buf...)
+		} else {
+			editor.copyBuffer.Cut(buf...)
+		}
+		editor.toClipboard()
+	}
+}
+
+// toClipboard puts the copy buffer on the system clipboard. Whole lines
+// end with a newline, so that they paste as lines elsewhere.
+func (editor *Editor) toClipboard() {
+	text := strings.Join(editor.copyBuffer.Paste(), "\n")
+	if !editor.copyBuffer.IsPartial() {
+		text += "\n"
+	}
+	editor.clipText = text
+	if err := editor.clipboard.Copy(text); err != nil {
+		editor.screen.Notify("Clipboard: " + err.Error())
+	}
+}
+
+// fromClipboard takes text from the system clipboard into the copy buffer,
+// if it has changed since sith last used the clipboard. Text ending in a
+// newline is whole lines.
+func (editor *Editor) fromClipboard() {
+	text, err := editor.clipboard.Paste()
+	if err != nil {
+		if !errors.Is(err, clipboard.ErrNoPaste) {
+			editor.screen.Notify("Clipboard: " + err.Error())
+		}
+		return
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" || text == editor.clipText {
+		return
 	}
+	editor.clipText = text
+	lines, whole := strings.CutSuffix(text, "\n")
+	editor.copyBuffer.Store(!whole, strings.Split(lines, "\n")...)
 }
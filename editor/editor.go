@@ -6,12 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/wx13/sith/autocomplete"
+	"github.com/wx13/sith/clipboard"
 	"github.com/wx13/sith/config"
 	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/lsp"
+	"github.com/wx13/sith/project"
+	"github.com/wx13/sith/quickfix"
 	"github.com/wx13/sith/state"
 	"github.com/wx13/sith/terminal"
 	"github.com/wx13/sith/ui"
@@ -37,10 +43,35 @@ type Editor struct {
 	searchHist  []string
 	replaceHist []string
 	gotoHist    []string
+	cmdHist     []string
 	history     *state.History
 	session     *state.Session
 
 	copyBuffer *CopyBuffer
+	index      *project.Index
+	lsp        *lsp.Manager
+	lspReqs    lspRequests
+
+	// Build and test commands, and the errors (and output) from the last
+	// one.
+	builder     builder
+	errors      *quickfix.List
+	buildOutput string
+
+	// The system clipboard, and the text last put on (or taken from) it.
+	clipboard clipboard.Clipboard
+	clipText  string
+
+	layout *layout
+	focus  *pane
+
+	// Keyboard macros.
+	macros        *state.Macros
+	macroRegister string
+	lastMacro     string
+	cmdStart      int
+	playingMacro  bool
+	macroPlays    int
 
 	cfg config.Config
 }
@@ -48,21 +79,40 @@ type Editor struct {
 // NewEditor creates a new Editor object.
 func NewEditor() *Editor {
 	history := state.NewHistory()
-	return &Editor{
+	session := state.NewSession()
+	session.Load()
+	screen := terminal.NewScreen()
+	layout, focus := newLayout(screen)
+	editor := &Editor{
 		flushChan:   make(chan struct{}, 1),
-		screen:      terminal.NewScreen(),
+		screen:      screen,
+		layout:      layout,
+		focus:       focus,
 		copyBuffer:  NewCopyBuffer(),
 		cfg:         config.CreateConfig(),
 		completer:   autocomplete.New(),
 		history:     history,
-		session:     state.NewSession(),
+		session:     session,
 		searchHist:  history.GetSearch(),
 		replaceHist: history.GetReplace(),
 		gotoHist:    history.GetGoto(),
+		macros:      state.NewMacros(),
 	}
+	editor.lsp = lsp.NewManager(".", func(string) { editor.RequestFlush() })
+	if editor.cfg.Theme != "" {
+		if err := editor.SetTheme(editor.cfg.Theme); err != nil {
+			editor.screen.Notify(err.Error())
+		}
+	}
+	clip, err := clipboard.New(editor.cfg.Clipboard, screen.GetTcell().SetClipboard)
+	if err != nil {
+		editor.screen.Notify(err.Error())
+	}
+	editor.clipboard = clip
+	return editor
 }
 
-// OpenNewFile offers a file selection menu to choose a new file to open.
+// OpenNewFile offers a directory-browsing menu to choose a new file to open.
 func (editor *Editor) OpenNewFile() {
 	dir, _ := os.Getwd()
 	dir += "/"
@@ -125,25 +175,28 @@ func (editor *Editor) OpenNewFile() {
 	for _, filename := range filenames {
 		chosenFile, _ := filepath.Rel(cwd, dir+filename)
 		editor.OpenFile(chosenFile)
-		editor.fileIdxPrv = editor.fileIdx
-		editor.fileIdx = len(editor.files) - 1
-		editor.file = editor.files[editor.fileIdx]
+		editor.SwitchFile(len(editor.files) - 1)
 	}
 }
 
-// OpenFile opens a specified file.
+// OpenFile opens a specified file, and waits for it to be read in.
 func (editor *Editor) OpenFile(name string) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	file := file.NewFile(name, editor.flushChan, editor.screen, editor.cfg, &wg)
-	file.SetCompleter(editor.AutoComplete)
+	file.SetCompleter(editor.CodeComplete)
 	editor.files = append(editor.files, file)
+	editor.addRecent(name)
+	wg.Wait()
+	file.CheckSwap()
+	editor.markErrors(file)
+	editor.attachLsp(file, &wg)
 }
 
 func (editor *Editor) AutoComplete(prefix string) []string {
 	text := editor.file.ToCorpus(editor.file.GetRowsCols())
 	for i, file := range editor.files {
-		if i != editor.fileIdx {
+		if i != editor.fileIdx && !file.Large() {
 			text += "\n" + file.ToString()
 		}
 	}
@@ -156,13 +209,15 @@ func (editor *Editor) OpenFiles(fileNames []string) {
 	wg.Add(len(fileNames))
 	for _, name := range fileNames {
 		file := file.NewFile(name, editor.flushChan, editor.screen, editor.cfg, &wg)
-		file.SetCompleter(editor.AutoComplete)
+		file.SetCompleter(editor.CodeComplete)
 		editor.files = append(editor.files, file)
+		editor.addRecent(name)
+		editor.attachLsp(file, &wg)
 	}
 	if len(editor.files) == 0 {
 		wg.Add(1)
 		file := file.NewFile("", editor.flushChan, editor.screen, editor.cfg, &wg)
-		file.SetCompleter(editor.AutoComplete)
+		file.SetCompleter(editor.CodeComplete)
 		editor.files = append(editor.files, file)
 	}
 	editor.fileIdx = 0
@@ -200,6 +255,11 @@ func (editor *Editor) Quit() {
 	// Save history and session before exiting.
 	editor.saveHistory()
 	editor.saveSession()
+	for _, file := range editor.files {
+		file.SaveHistory()
+		file.RemoveSwap()
+	}
+	editor.lsp.Shutdown()
 
 	// Exit.
 	editor.screen.Close()
@@ -238,8 +298,10 @@ func (editor *Editor) saveSession() {
 		return
 	}
 
-	// Create a fresh session
+	// Create a fresh session, keeping the list of recent files.
+	recent := editor.session.GetRecent()
 	editor.session = state.NewSession()
+	editor.session.SetRecent(recent)
 
 	for i, f := range editor.files {
 		row, col := f.GetRowCol(0)
@@ -352,12 +414,21 @@ func (editor *Editor) CloseFile() bool {
 	if !editor.files[idx].Close() {
 		return false
 	}
+	closed := editor.files[idx]
+	closed.SaveHistory()
+	closed.RemoveSwap()
+	if doc := closed.LspDoc(); doc != nil {
+		doc.Close()
+	}
 	editor.files = append(editor.files[:idx], editor.files[idx+1:]...)
 	if len(editor.files) == 0 {
 		editor.screen.Close()
 		return true
 	}
-	editor.NextFile()
+	// Switch to the file that followed the closed one.
+	editor.fileIdx = -1
+	editor.SwitchFile(idx)
+	editor.replaceInPanes(closed, editor.file)
 	return true
 }
 
@@ -366,18 +437,60 @@ func (editor *Editor) Listen() {
 
 	editor.keyboard = terminal.NewKeyboard()
 	editor.keyboard.SetScreen(editor.screen.GetTcell())
+	editor.index = project.NewIndex(".")
 	editor.keymap = editor.MakeKeyMap()
 	editor.xKeymap = editor.MakeExtraKeyMap()
+	editor.applyKeyBindings()
+	for _, file := range editor.files {
+		file.CheckSwap()
+	}
+	editor.Flush()
 	for {
+		// Note where each command starts, so that stopping a macro
+		// recording can leave out the keys which stopped it.
+		editor.cmdStart = editor.keyboard.Macros.Len()
 		cmd, r := editor.keyboard.GetKey()
 		editor.handleCmd(cmd, r)
+		editor.file.SyncLsp()
+		editor.file.SyncSwap()
 		editor.copyBuffer.NoOp()
 		editor.RequestFlush()
 	}
 
 }
 
+// applyKeyBindings rebinds keys according to the config file.
+func (editor *Editor) applyKeyBindings() {
+	actions := editor.xKeymap.Actions()
+	for id, action := range editor.keymap.Actions() {
+		actions[id] = action
+	}
+	isRune := func(key string) bool {
+		return utf8.RuneCountInString(key) == 1
+	}
+	errs := editor.keymap.Rebind(editor.cfg.Keys, actions, editor.keyboard.ValidKey)
+	errs = append(errs, editor.xKeymap.Rebind(editor.cfg.ExtraKeys, actions, isRune)...)
+	if len(errs) > 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		editor.screen.Notify("Key bindings: " + strings.Join(msgs, ", "))
+	}
+}
+
 func (editor *Editor) handleCmd(cmd string, r rune) {
+	switch cmd {
+	case "mouse":
+		editor.handleMouse(editor.keyboard.Mouse())
+		return
+	case "paste":
+		editor.file.InsertPasted(editor.keyboard.Pasted())
+		return
+	case "interrupt":
+		// Left over from a shell command (see runCommand).
+		return
+	}
 	ans := editor.keymap.Run(cmd)
 	if ans == "" {
 		return
@@ -389,6 +502,15 @@ func (editor *Editor) handleCmd(cmd string, r rune) {
 	}
 }
 
+// Tab indents the selected lines, or else inserts a tab.
+func (editor *Editor) Tab() {
+	if editor.file.HasSelection() {
+		editor.file.IndentSelection(1)
+		return
+	}
+	editor.file.InsertChar('\t')
+}
+
 // ExtraMode allows for additional keypresses.
 func (editor *Editor) ExtraMode() {
 	p := ui.MakePrompt(editor.screen, editor.keyboard)
@@ -563,39 +685,48 @@ func (editor *Editor) SwitchFile(n int) {
 	}
 	editor.fileIdxPrv = editor.fileIdx
 	editor.fileIdx = n
-	editor.file = editor.files[n]
+	editor.showFile(editor.files[n])
 }
 
-// HighlightCursors highlights all the multi-cursors.
-func (editor *Editor) HighlightCursors() {
-	cols, rows := editor.screen.Size()
-	r0, c0 := editor.file.GetCursor(0)
-	if editor.file.MultiCursor.Length() <= 1 {
+// HighlightCursors highlights all the multi-cursors of a file.
+func (editor *Editor) HighlightCursors(f *file.File, screen *terminal.Screen) {
+	cols, rows := screen.Size()
+	r0, c0 := f.GetCursor(0)
+	if f.MultiCursor.Length() <= 1 {
 		return
 	}
-	for k := range editor.file.MultiCursor.Cursors()[1:] {
-		r, c := editor.file.GetCursor(k + 1)
+	for k := range f.MultiCursor.Cursors()[1:] {
+		r, c := f.GetCursor(k + 1)
 		if r < 0 || r > rows || c < 0 || c > cols {
 			continue
 		}
 		if r == r0 && c == c0 {
-			editor.screen.ColorRange(r, r, c, c,
-				terminal.ColorYellow|terminal.AttrBold,
-				terminal.AttrBold)
+			fg, bg := terminal.RoleColors("cursor")
+			screen.ColorRange(r, r, c, c, fg, bg|terminal.AttrBold)
 		} else {
-			editor.screen.Highlight(r, c)
+			screen.Highlight(r, c)
 		}
 	}
 }
 
-// Flush writes the current buffer to the screen.
+// Flush writes the current buffer (or all the panes) to the screen.
 func (editor *Editor) Flush() {
-	editor.file.Flush()
-	editor.HighlightCursors()
-	editor.UpdateStatus()
+	if editor.layout.pane == nil {
+		editor.flushPanes()
+	} else {
+		editor.flushFile(editor.file, editor.fileIdx, editor.screen, true)
+	}
 	editor.screen.Flush()
 }
 
+// flushFile draws a file, its cursors and its status line.
+func (editor *Editor) flushFile(f *file.File, fileIdx int, screen *terminal.Screen, focused bool) {
+	f.Flush()
+	f.HighlightSelection()
+	editor.HighlightCursors(f, screen)
+	editor.UpdateStatus(f, fileIdx, screen, focused)
+}
+
 // KeepFlushed waits for flush requests, and then flushes
 // to the screen.
 func (editor *Editor) KeepFlushed() {
@@ -615,8 +746,8 @@ func (editor *Editor) RequestFlush() {
 	}
 }
 
-func (editor *Editor) getFilename(maxNameLen int) string {
-	name := editor.file.Name
+func (editor *Editor) getFilename(f *file.File, maxNameLen int) string {
+	name := f.Name
 	nameLen := len(name)
 	if nameLen > maxNameLen {
 		name = name[0:maxNameLen/2] + "..." + name[nameLen-maxNameLen/2:nameLen]
@@ -624,9 +755,10 @@ func (editor *Editor) getFilename(maxNameLen int) string {
 	return name
 }
 
-func (editor *Editor) writeModStatus(row, col int) int {
-	if editor.file.IsModified() {
-		editor.screen.WriteStringColor(row, col-3, "M  ", terminal.ColorRed, terminal.ColorDefault)
+func (editor *Editor) writeModStatus(f *file.File, screen *terminal.Screen, row, col int) int {
+	if f.IsModified() {
+		fg, bg := terminal.RoleColors("status.modified")
+		screen.WriteStringColor(row, col-3, "M  ", fg, bg)
 		return 3
 	}
 	if len(editor.files) <= 1 {
@@ -634,56 +766,64 @@ func (editor *Editor) writeModStatus(row, col int) int {
 	}
 	for _, file := range editor.files {
 		if file.IsModified() {
-			editor.screen.WriteStringColor(row, col-3, "M  ", terminal.ColorYellow, terminal.ColorDefault)
+			fg, bg := terminal.RoleColors("status.modified.other")
+			screen.WriteStringColor(row, col-3, "M  ", fg, bg)
 			return 3
 		}
 	}
 	return 0
 }
 
-func (editor *Editor) writeSyncStatus(row, col int) int {
-	changed, err := editor.file.FileChanged()
+func (editor *Editor) writeSyncStatus(f *file.File, screen *terminal.Screen, row, col int) int {
+	changed, err := f.FileChanged()
+	fg, bg := terminal.RoleColors("status.modified")
 	if err != nil {
-		editor.screen.WriteStringColor(row, col-3, "X", terminal.ColorDefault, terminal.ColorRed)
+		screen.WriteStringColor(row, col-3, "X", bg, fg)
 		return 3
 	}
 	if changed {
-		editor.screen.WriteStringColor(row, col-3, "S  ", terminal.ColorRed, terminal.ColorDefault)
+		screen.WriteStringColor(row, col-3, "S  ", fg, bg)
 		return 3
 	}
+	fg, bg = terminal.RoleColors("status.modified.other")
 	for _, file := range editor.files {
 		changed, err := file.FileChanged()
 		if err != nil {
-			editor.screen.WriteStringColor(row, col-3, "X", terminal.ColorDefault, terminal.ColorYellow)
+			screen.WriteStringColor(row, col-3, "X", bg, fg)
 		}
 		if changed {
-			editor.screen.WriteStringColor(row, col-3, "S  ", terminal.ColorYellow, terminal.ColorDefault)
+			screen.WriteStringColor(row, col-3, "S  ", fg, bg)
 			return 3
 		}
 	}
 	return 0
 }
 
-// UpdateStatus updates the status line.
-func (editor *Editor) UpdateStatus() {
-	cols, rows := editor.screen.Size()
+// UpdateStatus updates the status line of a file. Only the focused file
+// gets the terminal cursor.
+func (editor *Editor) UpdateStatus(f *file.File, fileIdx int, screen *terminal.Screen, focused bool) {
+	cols, rows := screen.Size()
 
-	name := editor.getFilename(cols / 3)
+	name := editor.getFilename(f, cols/3)
 	message := fmt.Sprintf("%s (%d/%d)   %d/%d,%d",
 		name,
-		editor.fileIdx,
+		fileIdx,
 		len(editor.files),
-		editor.file.MultiCursor.GetRow(0),
-		editor.file.Length()-1,
-		editor.file.MultiCursor.GetCol(0),
+		f.MultiCursor.GetRow(0),
+		f.Length()-1,
+		f.MultiCursor.GetCol(0),
 	)
 	col := cols - len(message)
-	editor.screen.WriteString(rows-1, col, message)
+	screen.WriteString(rows-1, col, message)
 	banner := "[ Sith " + version.Get() + " ]"
-	editor.screen.WriteString(rows-1, 0, banner)
-	editor.screen.DecorateStatusLine()
-	col -= editor.writeModStatus(rows-1, col)
-	col -= editor.writeSyncStatus(rows-1, col)
-	editor.file.WriteStatus(rows-1, col)
-	editor.screen.SetCursor(editor.file.GetCursor(0))
+	screen.WriteString(rows-1, 0, banner)
+	if focused {
+		screen.DecorateStatusLine()
+	}
+	col -= editor.writeModStatus(f, screen, rows-1, col)
+	col -= editor.writeSyncStatus(f, screen, rows-1, col)
+	f.WriteStatus(rows-1, col)
+	if focused {
+		screen.SetCursor(f.GetCursor(0))
+	}
 }
@@ -0,0 +1,56 @@
+package editor
+
+import (
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/terminal"
+)
+
+// NewTestEditor creates an editor which draws to a simulated screen, with
+// the named files open (and read in).
+func NewTestEditor(cols, rows int, names ...string) *Editor {
+	screen := terminal.NewSimulationScreen(cols, rows)
+	layout, focus := newLayout(screen)
+	editor := &Editor{
+		flushChan:  make(chan struct{}, 1),
+		screen:     screen,
+		layout:     layout,
+		focus:      focus,
+		copyBuffer: NewCopyBuffer(),
+		cfg:        config.Config{TabWidth: 4, TabWidth_set: true},
+	}
+	editor.OpenFiles(names)
+	for _, f := range editor.files {
+		f.CheckSwap()
+	}
+	return editor
+}
+
+// CurrentFile returns the file in the focused pane.
+func (editor *Editor) CurrentFile() *file.File {
+	return editor.file
+}
+
+// PaneFiles returns the file in each pane (in display order), showing the
+// pane's view.
+func (editor *Editor) PaneFiles() []*file.File {
+	files := []*file.File{}
+	for _, p := range editor.layout.leaves() {
+		if p == editor.focus {
+			files = append(files, editor.file)
+		} else {
+			files = append(files, p.file.WithView(p.view))
+		}
+	}
+	return files
+}
+
+// PaneSizes returns the size (columns, rows) of each pane.
+func (editor *Editor) PaneSizes() [][2]int {
+	sizes := [][2]int{}
+	for _, p := range editor.layout.leaves() {
+		cols, rows := p.screen.Size()
+		sizes = append(sizes, [2]int{cols, rows})
+	}
+	return sizes
+}
@@ -2,12 +2,15 @@ package editor
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/wx13/sith/terminal"
 )
 
-// Action defines a keyboard action.
+// Action defines a keyboard action. The ID is a stable name for the
+// action, used to rebind keys from the config file.
 type Action struct {
+	ID   string
 	Func func()
 	Name string
 }
@@ -18,108 +21,212 @@ type KeyMap map[string]Action
 // MakeKeyMap initializes the KeyMap.
 func (editor *Editor) MakeKeyMap() KeyMap {
 	km := make(KeyMap)
-	km.Add("backspace", func() { editor.file.Backspace() }, "")
-	km.Add("delete", func() { editor.file.Delete() }, "")
-	km.Add("ctrlD", func() { editor.file.Delete() }, "")
-	km.Add("space", func() { editor.file.InsertChar(' ') }, "")
-	km.Add("tab", func() { editor.file.InsertChar('\t') }, "")
-	km.Add("enter", func() { editor.file.Newline() }, "")
-	km.Add("arrowLeft", func() { editor.file.CursorLeft() }, "")
-	km.Add("arrowRight", func() { editor.file.CursorRight() }, "")
-	km.Add("arrowUp", func() { editor.file.CursorUp(1) }, "")
-	km.Add("arrowDown", func() { editor.file.CursorDown(1) }, "")
-	km.Add("ctrlJ", func() { editor.file.ScrollUp() }, "Scroll Up")
-	km.Add("ctrlK", func() { editor.file.ScrollDown() }, "Scroll Down")
-	km.Add("ctrlP", func() { editor.file.ScrollRight() }, "Scroll Right")
-	km.Add("ctrlO", func() { editor.file.ScrollLeft() }, "Scroll Left")
-	km.Add("pageDown", func() { editor.file.PageDown() }, "")
-	km.Add("ctrlN", func() { editor.file.PageDown() }, "")
-	km.Add("pageUp", func() { editor.file.PageUp() }, "")
-	km.Add("ctrlB", func() { editor.file.PageUp() }, "")
-	km.Add("ctrlG", func() { editor.GoToLine() },
+	km.Add("backspace", "backspace", func() { editor.file.Backspace() }, "")
+	km.Add("delete", "delete", func() { editor.file.Delete() }, "")
+	km.Add("ctrlD", "delete", func() { editor.file.Delete() }, "")
+	km.Add("space", "insert-space", func() { editor.file.InsertChar(' ') }, "")
+	km.Add("tab", "insert-tab", editor.Tab, "")
+	km.Add("enter", "newline", func() { editor.file.Newline() }, "")
+	km.Add("arrowLeft", "cursor-left", editor.move(func() { editor.file.CursorLeft() }), "")
+	km.Add("arrowRight", "cursor-right", editor.move(func() { editor.file.CursorRight() }), "")
+	km.Add("arrowUp", "cursor-up", editor.move(func() { editor.file.CursorUp(1) }), "")
+	km.Add("arrowDown", "cursor-down", editor.move(func() { editor.file.CursorDown(1) }), "")
+	km.Add("ctrlJ", "scroll-up", func() { editor.file.ScrollUp() }, "Scroll Up")
+	km.Add("ctrlK", "scroll-down", func() { editor.file.ScrollDown() }, "Scroll Down")
+	km.Add("ctrlP", "scroll-right", func() { editor.file.ScrollRight() }, "Scroll Right")
+	km.Add("ctrlO", "scroll-left", func() { editor.file.ScrollLeft() }, "Scroll Left")
+	km.Add("ctrl6", "mark", func() { editor.file.ToggleMark() }, "Set/clear the selection mark")
+	km.Add("shiftArrowLeft", "select-left", editor.selecting(func() { editor.file.CursorLeft() }), "")
+	km.Add("shiftArrowRight", "select-right", editor.selecting(func() { editor.file.CursorRight() }), "")
+	km.Add("shiftArrowUp", "select-up", editor.selecting(func() { editor.file.CursorUp(1) }), "")
+	km.Add("shiftArrowDown", "select-down", editor.selecting(func() { editor.file.CursorDown(1) }), "")
+	km.Add("shiftPageUp", "select-page-up", editor.selecting(func() { editor.file.PageUp() }), "")
+	km.Add("shiftPageDown", "select-page-down", editor.selecting(func() { editor.file.PageDown() }), "")
+	km.Add("shiftHome", "select-to-start-of-file", editor.selecting(func() { editor.file.CursorGoTo(0, 0) }), "")
+	km.Add("shiftEnd", "select-to-end-of-file", editor.selecting(func() { editor.file.CursorGoTo(-1, 0) }), "")
+	km.Add("pageDown", "page-down", editor.move(func() { editor.file.PageDown() }), "")
+	km.Add("ctrlN", "page-down", editor.move(func() { editor.file.PageDown() }), "")
+	km.Add("pageUp", "page-up", editor.move(func() { editor.file.PageUp() }), "")
+	km.Add("ctrlB", "page-up", editor.move(func() { editor.file.PageUp() }), "")
+	km.Add("ctrlG", "goto-line", func() { editor.GoToLine() },
 		"Go to line number (or bookmark)")
-	km.Add("home", func() { editor.file.CursorGoTo(0, 0) },
+	km.Add("home", "start-of-file", editor.move(func() { editor.file.CursorGoTo(0, 0) }),
 		"Go to the start of the file.")
-	km.Add("end", func() { editor.file.CursorGoTo(-1, 0) },
+	km.Add("end", "end-of-file", editor.move(func() { editor.file.CursorGoTo(-1, 0) }),
 		"Go to the end of the file.")
-	km.Add("altL", func() { editor.file.Refresh() }, "Refresh screen")
-	km.Add("altO", editor.OpenNewFile, "Open new file")
-	km.Add("altQ", editor.Quit, "Quit editor")
-	km.Add("altW", func() { editor.CloseFile() }, "Close file")
-	km.Add("ctrlZ", func() { editor.Suspend(); editor.keyboard = terminal.NewKeyboard() }, "Suspend")
-	km.Add("altN", editor.NextFile, "Next file buffer")
-	km.Add("altB", editor.PrevFile, "Previous file buffer")
-	km.Add("altK", editor.LastFile, "Toggle between recent buffers")
-	km.Add("altM", editor.SelectFile, "Select file buffer from menu")
-	km.Add("ctrlX", func() { editor.file.AddCursor() }, "Add cursor")
-	km.Add("altC", func() { editor.file.AddCursorCol() }, "Create column cursor")
-	km.Add("altX", func() { editor.file.ClearCursors() }, "Clear multi-cursor")
-	km.Add("altZ", func() { editor.file.ToggleMCMode() }, "Toggle among MC modes")
-	km.Add("ctrlU", func() { editor.file.Undo() }, "Undo")
-	km.Add("ctrlY", func() { editor.file.Redo() }, "Redo")
-	km.Add("altU", func() { editor.file.UndoSaved() }, "Macro undo")
-	km.Add("altY", func() { editor.file.RedoSaved() }, "Macro redo")
-	km.Add("ctrlS", editor.Save, "Save file")
-	km.Add("altS", editor.SaveAs, "Save as...")
-	km.Add("ctrlA", func() { editor.file.StartOfLine() }, "Move to start of line")
-	km.Add("ctrlE", func() { editor.file.EndOfLine() }, "Move to end of line")
-	km.Add("altA", func() { editor.file.CutToStartOfLine() }, "Cut to start of line")
-	km.Add("altE", func() { editor.file.CutToEndOfLine() }, "Cut to end of line")
-	km.Add("ctrlW", func() { editor.file.NextWord() }, "Move cursor to next word")
-	km.Add("ctrlQ", func() { editor.file.PrevWord() }, "Move cursor to previous word")
-	km.Add("alt[", func() { editor.file.CutWord(-1) }, "Cut to start of current word")
-	km.Add("alt]", func() { editor.file.CutWord(1) }, "Cut to end of current word")
-	km.Add("alt\\", func() { editor.file.CutWord(0) }, "Cut the word under the cursor")
-	km.Add("ctrlF", func() { editor.Search(false) }, "Search")
-	km.Add("ctrlR", func() { editor.Search(true) }, "Multi-file search")
-	km.Add("altF", func() { editor.SearchAndReplace(false) }, "Search and replace")
-	km.Add("altR", func() { editor.SearchAndReplace(true) }, "Multi-file search and replace")
-	km.Add("ctrlC", editor.Cut, "Cut line")
-	km.Add("ctrlV", editor.Paste, "Paste")
-	km.Add("altV", editor.PasteFromMenu, "Paste from menu")
-	km.Add("altJ", func() { editor.file.Justify() }, "Justify")
-	km.Add("altH", func() { editor.file.UnJustify() }, "Unjustify")
-	km.Add("altI", func() { editor.file.ToggleAutoIndent() }, "Toggle auto-indent")
-	km.Add("altT", func() { editor.file.ToggleAutoTab() }, "Toggle Auto-tab")
-	km.Add("alt6", editor.ExtraMode, "Extra mode")
-	km.Add("ctrlSlash", editor.CmdMenu, "Display command menu")
-	km.Add("alt?", editor.CmdMenu, "Display command menu")
-	km.Add("altG", func() { editor.file.ToggleAutoFmt() }, "Toggle auto fmt on save")
-	km.Add("alt.", func() { editor.file.NextChange() }, "Go to next changed line")
-	km.Add("alt,", func() { editor.file.PrevChange() }, "Go to previous changed line")
+	km.Add("altL", "refresh", func() { editor.file.Refresh() }, "Refresh screen")
+	km.Add("altO", "open-file", editor.FindFile, "Open file (fuzzy finder)")
+	km.Add("altQ", "quit", editor.Quit, "Quit editor")
+	km.Add("altW", "close-file", func() { editor.CloseFile() }, "Close file")
+	km.Add("ctrlZ", "suspend", func() { editor.Suspend(); editor.keyboard = terminal.NewKeyboard() }, "Suspend")
+	km.Add("altN", "next-file", editor.NextFile, "Next file buffer")
+	km.Add("altB", "prev-file", editor.PrevFile, "Previous file buffer")
+	km.Add("altK", "last-file", editor.LastFile, "Toggle between recent buffers")
+	km.Add("altM", "select-file", editor.SelectFile, "Select file buffer from menu")
+	km.Add("ctrlX", "add-cursor", func() { editor.file.AddCursor() }, "Add cursor")
+	km.Add("altC", "add-cursor-column", func() { editor.file.AddCursorCol() }, "Create column cursor")
+	km.Add("altX", "clear-cursors", func() { editor.file.ClearCursors() }, "Clear multi-cursor")
+	km.Add("altZ", "toggle-mc-mode", func() { editor.file.ToggleMCMode() }, "Toggle among MC modes")
+	km.Add("ctrlU", "undo", func() { editor.file.Undo() }, "Undo")
+	km.Add("ctrlY", "redo", func() { editor.file.Redo() }, "Redo")
+	km.Add("altU", "undo-saved", func() { editor.file.UndoSaved() }, "Macro undo")
+	km.Add("altY", "redo-saved", func() { editor.file.RedoSaved() }, "Macro redo")
+	km.Add("ctrlS", "save", editor.Save, "Save file")
+	km.Add("altS", "save-as", editor.SaveAs, "Save as...")
+	km.Add("ctrlA", "start-of-line", editor.move(func() { editor.file.StartOfLine() }), "Move to start of line")
+	km.Add("ctrlE", "end-of-line", editor.move(func() { editor.file.EndOfLine() }), "Move to end of line")
+	km.Add("altA", "cut-to-start-of-line", func() { editor.file.CutToStartOfLine() }, "Cut to start of line")
+	km.Add("altE", "cut-to-end-of-line", func() { editor.file.CutToEndOfLine() }, "Cut to end of line")
+	km.Add("ctrlW", "next-word", editor.move(func() { editor.file.NextWord() }), "Move cursor to next word")
+	km.Add("ctrlQ", "prev-word", editor.move(func() { editor.file.PrevWord() }), "Move cursor to previous word")
+	km.Add("alt[", "cut-word-start", func() { editor.file.CutWord(-1) }, "Cut to start of current word")
+	km.Add("alt]", "cut-word-end", func() { editor.file.CutWord(1) }, "Cut to end of current word")
+	km.Add("alt\\", "cut-word", func() { editor.file.CutWord(0) }, "Cut the word under the cursor")
+	km.Add("ctrlF", "search", func() { editor.Search(false) }, "Search")
+	km.Add("ctrlR", "multi-file-search", func() { editor.Search(true) }, "Multi-file search")
+	km.Add("altF", "search-replace", func() { editor.SearchAndReplace(false) }, "Search and replace")
+	km.Add("altR", "multi-file-search-replace", func() { editor.SearchAndReplace(true) }, "Multi-file search and replace")
+	km.Add("altP", "project-search", editor.ProjectSearch, "Search all files in the project")
+	km.Add("alt;", "next-pane", func() { editor.NextPane(1) }, "Next pane")
+	km.Add("alt:", "prev-pane", func() { editor.NextPane(-1) }, "Previous pane")
+	km.Add("ctrlC", "cut", editor.Cut, "Cut selection (or line)")
+	km.Add("altD", "copy", editor.Copy, "Copy selection (or line)")
+	km.Add("ctrlV", "paste", editor.Paste, "Paste")
+	km.Add("altV", "paste-from-menu", editor.PasteFromMenu, "Paste from menu")
+	km.Add("altJ", "justify", func() { editor.file.Justify() }, "Justify")
+	km.Add("altH", "unjustify", func() { editor.file.UnJustify() }, "Unjustify")
+	km.Add("altI", "toggle-auto-indent", func() { editor.file.ToggleAutoIndent() }, "Toggle auto-indent")
+	km.Add("altT", "toggle-auto-tab", func() { editor.file.ToggleAutoTab() }, "Toggle Auto-tab")
+	km.Add("alt6", "extra-mode", editor.ExtraMode, "Extra mode")
+	km.Add("ctrlSlash", "command-menu", editor.CmdMenu, "Display command menu")
+	km.Add("alt?", "command-menu", editor.CmdMenu, "Display command menu")
+	km.Add("altG", "toggle-auto-fmt", func() { editor.file.ToggleAutoFmt() }, "Toggle auto fmt on save")
+	km.Add("alt.", "next-change", func() { editor.file.NextChange() }, "Go to next changed line")
+	km.Add("alt,", "prev-change", func() { editor.file.PrevChange() }, "Go to previous changed line")
+	km.Add("alt>", "next-hunk", func() { editor.file.NextHunk() }, "Go to next git hunk")
+	km.Add("alt<", "prev-hunk", func() { editor.file.PrevHunk() }, "Go to previous git hunk")
 	return km
 }
 
 // MakeExtraKeyMap initializes the "extra" keys map.
 func (editor *Editor) MakeExtraKeyMap() KeyMap {
 	km := make(KeyMap)
-	km.Add("c", editor.SetCharMode, "Change character display mode")
-	km.Add("a", func() { editor.file.CursorAlign() }, "Align cursor")
-	km.Add("A", func() { editor.file.CursorUnalign() }, "Unalign cursor")
-	km.Add("w", func() { editor.SearchLineFo() }, "Search from cursor to end of line")
-	km.Add("q", func() { editor.SearchLineBa() }, "Search from cursor to start of line")
-	km.Add("W", func() { editor.AllLineFo() }, "Multiply cursors to all matches through end of line")
-	km.Add("Q", func() { editor.AllLineBa() }, "Multiply cursors to all matches through start of line")
-	km.Add("t", func() { editor.file.SetTabStr() }, "Manually set the indentation string")
-	km.Add("T", func() { editor.file.UnsetTabStr() }, "(Re)Enable auto tab string detection")
-	km.Add("i", func() { editor.file.SetTabWidth() }, "Set the tab display width")
-	km.Add("l", func() { editor.file.SetLineLen() }, "Set the justify line length")
-	km.Add("s", editor.SaveAll, "Save all files")
-	km.Add("r", func() { editor.file.Reload() }, "Reload file from disk")
-	km.Add("R", editor.ReloadAll, "Reload file from disk")
-	km.Add("f", func() { editor.file.Fmt() }, "Run code formatter")
-	km.Add("F", func() { editor.file.Fmt(true) }, "Run code formatter on selection")
-	km.Add("C", func() { editor.FmtCodeBlock() }, "Format code block at cursor (for markdown)")
-	km.Add("b", func() { editor.Bookmark() }, "Bookmark this file location.")
-	km.Add("B", func() { editor.BookmarkMenu() }, "Choose a bookmark from a menu.")
-	km.Add("h", func() { editor.file.ShowHistory() }, "Show buffer history (saved states)")
-	km.Add("d", func() { editor.file.ShowLineDiff() }, "Show diff at cursor")
+	km.Add("c", "char-mode", editor.SetCharMode, "Change character display mode")
+	km.Add("a", "align-cursors", func() { editor.file.CursorAlign() }, "Align cursor")
+	km.Add("A", "unalign-cursors", func() { editor.file.CursorUnalign() }, "Unalign cursor")
+	km.Add("w", "search-line-forward", func() { editor.SearchLineFo() }, "Search from cursor to end of line")
+	km.Add("q", "search-line-backward", func() { editor.SearchLineBa() }, "Search from cursor to start of line")
+	km.Add("W", "cursors-to-matches-forward", func() { editor.AllLineFo() }, "Multiply cursors to all matches through end of line")
+	km.Add("Q", "cursors-to-matches-backward", func() { editor.AllLineBa() }, "Multiply cursors to all matches through start of line")
+	km.Add("t", "set-tab-string", func() { editor.file.SetTabStr() }, "Manually set the indentation string")
+	km.Add("T", "detect-tab-string", func() { editor.file.UnsetTabStr() }, "(Re)Enable auto tab string detection")
+	km.Add("i", "set-tab-width", func() { editor.file.SetTabWidth() }, "Set the tab display width")
+	km.Add("l", "set-line-length", func() { editor.file.SetLineLen() }, "Set the justify line length")
+	km.Add("s", "save-all", editor.SaveAll, "Save all files")
+	km.Add("r", "reload", func() { editor.file.Reload() }, "Reload file from disk")
+	km.Add("R", "reload-all", editor.ReloadAll, "Reload file from disk")
+	km.Add("f", "format", func() { editor.file.Fmt() }, "Run code formatter")
+	km.Add("F", "format-selection", func() { editor.file.Fmt(true) }, "Run code formatter on selection")
+	km.Add("C", "format-code-block", func() { editor.FmtCodeBlock() }, "Format code block at cursor (for markdown)")
+	km.Add("b", "bookmark", func() { editor.Bookmark() }, "Bookmark this file location.")
+	km.Add("B", "bookmark-menu", func() { editor.BookmarkMenu() }, "Choose a bookmark from a menu.")
+	km.Add("h", "show-history", func() { editor.file.ShowHistory() }, "Show buffer history (saved states)")
+	km.Add("d", "show-line-diff", func() { editor.file.ShowLineDiff() }, "Show diff at cursor")
+	km.Add(">", "indent", func() { editor.file.IndentSelection(1) }, "Indent selected lines")
+	km.Add("<", "unindent", func() { editor.file.IndentSelection(-1) }, "Unindent selected lines")
+	km.Add("o", "browse-files", editor.OpenNewFile, "Open file by browsing directories")
+	km.Add("g", "project-search", editor.ProjectSearch, "Search all files in the project")
+	km.Add("G", "project-search-replace", editor.ProjectSearchAndReplace, "Search and replace in all project files")
+	km.Add("|", "split-vertical", func() { editor.SplitPane(true) }, "Split pane side-by-side")
+	km.Add("-", "split-horizontal", func() { editor.SplitPane(false) }, "Split pane top/bottom")
+	km.Add("x", "close-pane", editor.ClosePane, "Close pane")
+	km.Add("]", "grow-pane", func() { editor.ResizePane(0.05) }, "Grow pane")
+	km.Add("[", "shrink-pane", func() { editor.ResizePane(-0.05) }, "Shrink pane")
+	km.Add(".", "goto-definition", editor.GoToDefinition, "Go to definition (language server)")
+	km.Add("?", "hover", editor.ShowHover, "Show symbol information (language server)")
+	km.Add("D", "diagnostics", editor.ShowDiagnostics, "List diagnostics (language server)")
+	km.Add("v", "revert-hunk", func() { editor.file.RevertHunk() }, "Revert git hunk at cursor")
+	km.Add("S", "stage-hunk", func() { editor.file.StageHunk() }, "Stage git hunk at cursor")
+	km.Add("L", "blame", func() { editor.file.ShowBlame() }, "Show git blame for the current line")
+	km.Add("H", "toggle-git-base", func() { editor.file.ToggleGitBase() }, "Toggle git diff base (HEAD/index)")
+	km.Add("m", "record-macro", editor.RecordMacro, "Start/stop recording a macro")
+	km.Add("@", "play-macro", editor.PlayMacro, "Play a macro (with optional repeat count)")
+	km.Add("M", "macro-menu", editor.MacroMenu, "Choose a macro to play")
+	km.Add("z", "toggle-soft-wrap", func() { editor.file.ToggleSoftWrap() }, "Toggle soft line wrapping")
+	km.Add("n", "cycle-line-numbers", func() { editor.file.CycleLineNumbers() }, "Cycle line numbers (none/absolute/relative/hybrid)")
+	km.Add("y", "theme-menu", editor.ThemeMenu, "Choose a color theme")
+	km.Add("!", "filter", editor.Filter, "Filter through a shell command")
+	km.Add("$", "insert-command-output", editor.InsertCommandOutput, "Insert the output of a shell command")
+	km.Add("%", "command-output", editor.CommandOutput, "Show the output of a shell command")
+	km.Add("k", "build", editor.Build, "Run the build command")
+	km.Add("K", "test", editor.Test, "Run the test command")
+	km.Add("j", "next-error", editor.NextError, "Go to the next build error")
+	km.Add("J", "prev-error", editor.PrevError, "Go to the previous build error")
+	km.Add("E", "error-list", editor.ErrorList, "List the build errors")
+	km.Add("O", "build-output", editor.BuildOutput, "Show the output of the last build")
+	km.Add("e", "toggle-read-only", func() { editor.file.ToggleReadOnly() }, "Toggle read-only (large files)")
 	return km
 }
 
+// move wraps a cursor movement, so that it ends any shift-selection.
+func (editor *Editor) move(f func()) func() {
+	return func() {
+		editor.file.EndShiftSelection()
+		f()
+	}
+}
+
+// selecting wraps a cursor movement, so that it extends the selection.
+func (editor *Editor) selecting(f func()) func() {
+	return func() {
+		editor.file.ExtendSelection()
+		f()
+	}
+}
+
 // Add inserts a new action into the keymap.
-func (km KeyMap) Add(key string, f func(), name string) {
-	km[key] = Action{f, name}
+func (km KeyMap) Add(key, id string, f func(), name string) {
+	km[key] = Action{id, f, name}
+}
+
+// Actions returns the keymap's actions, indexed by ID.
+func (km KeyMap) Actions() map[string]Action {
+	actions := map[string]Action{}
+	for _, action := range km {
+		actions[action.ID] = action
+	}
+	return actions
+}
+
+// Rebind applies a set of key bindings (key => action ID) to the keymap.
+// An action ID of "none" removes the binding. Bindings with unknown keys
+// or action IDs are skipped, and reported in the returned errors.
+func (km KeyMap) Rebind(bindings map[string]string, actions map[string]Action,
+	validKey func(string) bool) []error {
+	errs := []error{}
+	keys := make([]string, 0, len(bindings))
+	for key := range bindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		id := bindings[key]
+		if !validKey(key) {
+			errs = append(errs, fmt.Errorf("unknown key %q", key))
+			continue
+		}
+		if id == "none" {
+			delete(km, key)
+			continue
+		}
+		action, ok := actions[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown action %q", id))
+			continue
+		}
+		km[key] = action
+	}
+	return errs
 }
 
 // Run runs the specified action.
@@ -135,26 +242,30 @@ func (km KeyMap) Run(key string) string {
 	return "unknown"
 }
 
-// Keys shows a list of available actions.
+// Keys shows a list of available actions (every bound key, since any
+// action may have been bound to a key of the user's choosing).
 func (km KeyMap) Keys() []string {
 	keys := []string{}
-	for key, action := range km {
-		if len(action.Name) > 0 {
-			keys = append(keys, key)
-		}
+	for key := range km {
+		keys = append(keys, key)
 	}
 	return keys
 }
 
-// DisplayNames returns pretty-formatted keymap action names.
+// DisplayNames returns pretty-formatted keymap action names. Actions
+// without a name are shown by their ID.
 func (km KeyMap) DisplayNames(keys []string, prefix string) []string {
 	names := make([]string, len(keys))
 	for idx, key := range keys {
 		action, ok := km[key]
-		if ok {
-			names[idx] = fmt.Sprintf("%s%-10s  %s", prefix, key, action.Name)
+		if !ok {
+			continue
+		}
+		if action.Name == "" {
+			names[idx] = fmt.Sprintf("%s%-10s  [%s]", prefix, key, action.ID)
 			continue
 		}
+		names[idx] = fmt.Sprintf("%s%-10s  %s [%s]", prefix, key, action.Name, action.ID)
 	}
 	return names
 }
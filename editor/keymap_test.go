@@ -0,0 +1,72 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/wx13/sith/editor"
+)
+
+func TestRebind(t *testing.T) {
+	calls := []string{}
+	km := make(editor.KeyMap)
+	km.Add("ctrlU", "undo", func() { calls = append(calls, "undo") }, "Undo")
+	km.Add("ctrlY", "redo", func() { calls = append(calls, "redo") }, "Redo")
+	km.Add("ctrlS", "save", func() { calls = append(calls, "save") }, "Save")
+
+	validKey := func(key string) bool {
+		return key != "bogus"
+	}
+	bindings := map[string]string{
+		"ctrlZ": "undo",
+		"ctrlY": "none",
+		"ctrlS": "nosuchaction",
+		"bogus": "redo",
+	}
+	errs := km.Rebind(bindings, km.Actions(), validKey)
+	if len(errs) != 2 {
+		t.Errorf("Expected two errors, got %v", errs)
+	}
+
+	km.Run("ctrlZ")
+	km.Run("ctrlU")
+	if len(calls) != 2 || calls[0] != "undo" || calls[1] != "undo" {
+		t.Errorf("Both keys should run undo: %v", calls)
+	}
+	if km.Run("ctrlY") != "unknown" {
+		t.Error("ctrlY should have been unbound")
+	}
+	km.Run("ctrlS")
+	if calls[len(calls)-1] != "save" {
+		t.Error("Invalid binding should leave the default in place", calls)
+	}
+}
+
+func TestDisplayNames(t *testing.T) {
+	km := make(editor.KeyMap)
+	km.Add("ctrlU", "undo", func() {}, "Undo")
+	km.Add("arrowUp", "cursor-up", func() {}, "")
+	keys := km.Keys()
+	if len(keys) != 2 {
+		t.Fatal("Every bound action should be listed:", keys)
+	}
+	names := km.DisplayNames([]string{"ctrlU", "arrowUp"}, "")
+	if names[0] != "ctrlU       Undo [undo]" {
+		t.Errorf("Bad display name: %q", names[0])
+	}
+	if names[1] != "arrowUp     [cursor-up]" {
+		t.Errorf("Unnamed actions should show their ID: %q", names[1])
+	}
+
+	// An unnamed action bound to a new key is listed under that key.
+	errs := km.Rebind(map[string]string{"ctrlK": "cursor-up"}, km.Actions(),
+		func(string) bool { return true })
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if keys := km.Keys(); len(keys) != 3 {
+		t.Fatal("The rebound action should be listed:", keys)
+	}
+	if names := km.DisplayNames([]string{"ctrlK"}, "Alt-6 "); names[0] != "Alt-6 ctrlK       [cursor-up]" {
+		t.Errorf("Bad display name for a rebound action: %q", names[0])
+	}
+}
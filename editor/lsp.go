@@ -0,0 +1,202 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/lsp"
+	"github.com/wx13/sith/ui"
+)
+
+// attachLsp connects a file to its language server (if it has one). The
+// server is started in the background, after the file has been read in.
+func (editor *Editor) attachLsp(f *file.File, wg *sync.WaitGroup) {
+	cmd := f.LspCmd()
+	if cmd == "" || f.Name == "" {
+		return
+	}
+	go func() {
+		wg.Wait()
+		if f.Large() {
+			return
+		}
+		client, err := editor.lsp.Client(cmd)
+		if err != nil {
+			f.NotifyUser("Language server: " + err.Error())
+			return
+		}
+		langID := lsp.LanguageID(file.GetFileExt(f.Name))
+		f.SetLspDoc(client.Open(f.Name, langID, f.ToString()))
+	}()
+}
+
+// lspDoc returns the current file's language server document, after
+// bringing it up to date.
+func (editor *Editor) lspDoc() *lsp.Document {
+	doc := editor.file.LspDoc()
+	if doc == nil {
+		editor.screen.Notify("No language server")
+		return nil
+	}
+	editor.file.SyncLsp()
+	return doc
+}
+
+// CodeComplete offers language server completions, falling back to
+// completion from the text of the open files.
+func (editor *Editor) CodeComplete(prefix string) []string {
+	if results := editor.file.LspComplete(prefix); len(results) > 0 {
+		return results
+	}
+	return editor.AutoComplete(prefix)
+}
+
+// lspRequests runs language server requests in the background. Starting a
+// request cancels the one before it.
+type lspRequests struct {
+	gen    int
+	cancel context.CancelFunc
+}
+
+// lspRequest runs a language server request about the symbol under the
+// cursor in the background, so that the editor carries on meanwhile. The
+// keyboard hands the result (a function to run) back once it is done. The
+// result is dropped if another request has started, or if the file or
+// cursor has moved on.
+func (editor *Editor) lspRequest(request func(ctx context.Context, doc *lsp.Document, row, col int) func()) {
+	doc := editor.lspDoc()
+	if doc == nil {
+		return
+	}
+	reqs := &editor.lspReqs
+	if reqs.cancel != nil {
+		reqs.cancel()
+	}
+	reqs.gen++
+	gen := reqs.gen
+	ctx, cancel := context.WithCancel(context.Background())
+	reqs.cancel = cancel
+
+	f := editor.file
+	version := f.Version()
+	row, col := f.GetRowCol(0)
+	keyboard := editor.keyboard
+	go func() {
+		done := request(ctx, doc, row, col)
+		cancel()
+		keyboard.Post(func() {
+			if gen != reqs.gen || editor.file != f || f.Version() != version {
+				return
+			}
+			if r, c := f.GetRowCol(0); r != row || c != col {
+				return
+			}
+			done()
+			editor.RequestFlush()
+		})
+	}()
+}
+
+// GoToDefinition jumps to the definition of the symbol under the cursor.
+func (editor *Editor) GoToDefinition() {
+	editor.lspRequest(func(ctx context.Context, doc *lsp.Document, row, col int) func() {
+		locs, err := doc.Definition(ctx, row, col)
+		return func() { editor.goToDefinition(locs, err) }
+	})
+}
+
+// goToDefinition jumps to the first of the locations of a definition.
+func (editor *Editor) goToDefinition(locs []lsp.Location, err error) {
+	if err != nil {
+		editor.screen.Notify(err.Error())
+		return
+	}
+	if len(locs) == 0 {
+		editor.screen.Notify("No definition found")
+		return
+	}
+	loc := locs[0]
+	path := loc.Path()
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	editor.switchToFile(path)
+	row := loc.Range.Start.Line
+	col := lsp.RuneCol(editor.file.GetLine(row), loc.Range.Start.Character)
+	editor.file.ClearCursors()
+	editor.file.CursorGoTo(row, col)
+}
+
+// ShowHover shows the language server's information about the symbol
+// under the cursor.
+func (editor *Editor) ShowHover() {
+	editor.lspRequest(func(ctx context.Context, doc *lsp.Document, row, col int) func() {
+		text, err := doc.Hover(ctx, row, col)
+		return func() { editor.showHover(text, err) }
+	})
+}
+
+// showHover shows hover text: a short one as a notification, and a longer
+// one as a menu.
+func (editor *Editor) showHover(text string, err error) {
+	if err != nil {
+		editor.screen.Notify(err.Error())
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) <= 1 {
+		if text == "" {
+			text = "No information"
+		}
+		editor.screen.Notify(text)
+		return
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	menu.Choose(lines, 0, "")
+	editor.Flush()
+}
+
+// ShowDiagnostics offers a menu of the current file's diagnostics, and
+// jumps to the chosen one.
+func (editor *Editor) ShowDiagnostics() {
+	if editor.lspDoc() == nil {
+		return
+	}
+	diags := editor.file.Diagnostics()
+	if len(diags) == 0 {
+		editor.screen.Notify("No diagnostics")
+		return
+	}
+	names := map[int]string{
+		lsp.SeverityError:       "error",
+		lsp.SeverityWarning:     "warning",
+		lsp.SeverityInformation: "info",
+		lsp.SeverityHint:        "hint",
+	}
+	choices := make([]string, len(diags))
+	for k, diag := range diags {
+		severity, ok := names[diag.Severity]
+		if !ok {
+			severity = "error"
+		}
+		msg := strings.SplitN(diag.Message, "\n", 2)[0]
+		choices[k] = fmt.Sprintf("%d: %s: %s", diag.Range.Start.Line+1, severity, msg)
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	idx, key := menu.Choose(choices, 0, "")
+	editor.Flush()
+	if key == "cancel" || idx < 0 || idx >= len(diags) {
+		return
+	}
+	pos := diags[idx].Range.Start
+	col := lsp.RuneCol(editor.file.GetLine(pos.Line), pos.Character)
+	editor.file.ClearCursors()
+	editor.file.CursorGoTo(pos.Line, col)
+}
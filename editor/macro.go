@@ -0,0 +1,176 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wx13/sith/terminal"
+	"github.com/wx13/sith/ui"
+)
+
+// maxMacroPlays limits how many macros one playback may play (including
+// macros played by macros), to stop runaway recursion.
+const maxMacroPlays = 10000
+
+// RecordMacro starts recording keypresses into a register, or, if already
+// recording, stops.
+func (editor *Editor) RecordMacro() {
+	if editor.keyboard.Macros.Recording() {
+		editor.StopRecording()
+		return
+	}
+	p := ui.MakePrompt(editor.screen, editor.keyboard)
+	r := p.GetRune("record macro into register:")
+	if r == 0 {
+		return
+	}
+	editor.macroRegister = string(r)
+	editor.keyboard.Macros.Start()
+	editor.screen.Notify("Recording macro @" + editor.macroRegister)
+}
+
+// StopRecording stops recording a macro, and saves it. The keys which
+// stopped the recording are left out.
+func (editor *Editor) StopRecording() {
+	keys := editor.keyboard.Macros.Stop(editor.cmdStart)
+	if len(keys) == 0 {
+		editor.screen.Notify("Empty macro discarded")
+		return
+	}
+	editor.macros.Set(editor.macroRegister, keys)
+	editor.lastMacro = editor.macroRegister
+	if err := editor.macros.Save(); err != nil {
+		editor.screen.Notify(fmt.Sprintf("Recorded %d keys into @%s, but could not save macros: %s",
+			len(keys), editor.macroRegister, err))
+		return
+	}
+	editor.screen.Notify(fmt.Sprintf("Recorded %d keys into @%s", len(keys), editor.macroRegister))
+}
+
+// PlayMacro asks for a register (optionally preceded by a repeat count,
+// e.g. "3a"), and plays back the macro in it. An empty answer replays the
+// last macro.
+func (editor *Editor) PlayMacro() {
+	p := ui.MakePrompt(editor.screen, editor.keyboard)
+	ans, err := p.Ask("play macro ([count]register):", nil)
+	if err != nil {
+		return
+	}
+	count, register := parseMacroAnswer(ans)
+	if register == "" {
+		register = editor.lastMacro
+	}
+	editor.playRegister(register, count)
+}
+
+// MacroMenu lets the user choose a macro to play.
+func (editor *Editor) MacroMenu() {
+	names := editor.macros.Names()
+	if len(names) == 0 {
+		editor.screen.Notify("No macros")
+		return
+	}
+	choices := []string{}
+	for _, name := range names {
+		keys, _ := editor.macros.Get(name)
+		choices = append(choices, "@"+name+": "+describeKeys(keys))
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	idx, key := menu.Choose(choices, 0, "")
+	editor.Flush()
+	if idx < 0 || key == "cancel" {
+		return
+	}
+	editor.playRegister(names[idx], 1)
+}
+
+// parseMacroAnswer splits an answer like "3a" into a count and a register.
+func parseMacroAnswer(ans string) (int, string) {
+	ans = strings.TrimSpace(ans)
+	i := 0
+	for i < len(ans) && ans[i] >= '0' && ans[i] <= '9' {
+		i++
+	}
+	count, err := strconv.Atoi(ans[:i])
+	if err != nil || count < 1 {
+		count = 1
+	}
+	register := []rune(ans[i:])
+	if len(register) == 0 {
+		return count, ""
+	}
+	return count, string(register[0])
+}
+
+// describeKeys formats keys for display.
+func describeKeys(keys []terminal.Key) string {
+	strs := []string{}
+	for _, key := range keys {
+		if key.Cmd == "char" {
+			strs = append(strs, string(key.Rune))
+		} else {
+			strs = append(strs, "<"+key.Cmd+">")
+		}
+	}
+	return strings.Join(strs, "")
+}
+
+// playRegister plays the macro in a register count times.
+func (editor *Editor) playRegister(register string, count int) {
+	keys, ok := editor.macros.Get(register)
+	if !ok {
+		editor.screen.Notify("No macro in register @" + register)
+		return
+	}
+	editor.lastMacro = register
+	editor.playMacro(keys, count)
+}
+
+// playMacro plays keys back count times, through the same path as real
+// keypresses. Each playback is a single undo step.
+func (editor *Editor) playMacro(keys []terminal.Key, count int) {
+	if !editor.playingMacro {
+		editor.macroPlays = 0
+	}
+	editor.macroPlays += count
+	if editor.macroPlays > maxMacroPlays {
+		editor.keyboard.Macros.Cancel()
+		editor.screen.Notify("Macro stopped: too many plays")
+		return
+	}
+	if editor.playingMacro {
+		// A macro playing a macro: the outer playback runs the keys, as
+		// part of its own undo step.
+		queue := []terminal.Key{}
+		for i := 0; i < count; i++ {
+			queue = append(queue, keys...)
+		}
+		editor.keyboard.Macros.Play(queue)
+		return
+	}
+
+	editor.playingMacro = true
+	for i := 0; i < count && editor.macroPlays <= maxMacroPlays; i++ {
+		editor.keyboard.Macros.Play(keys)
+		editor.runMacro()
+	}
+	editor.playingMacro = false
+}
+
+// runMacro runs the keys waiting to be played back, with the history of
+// the open files paused, so that they are undone in one step.
+func (editor *Editor) runMacro() {
+	files := append(editor.files[:0:0], editor.files...)
+	for _, f := range files {
+		f.PauseHistory()
+	}
+	for editor.keyboard.Macros.Playing() {
+		cmd, r := editor.keyboard.GetKey()
+		editor.handleCmd(cmd, r)
+		editor.file.SyncLsp()
+	}
+	for _, f := range files {
+		f.ResumeHistory()
+	}
+}
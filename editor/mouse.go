@@ -0,0 +1,53 @@
+package editor
+
+import (
+	"github.com/wx13/sith/terminal"
+)
+
+// wheelRows is how far one turn of the mouse wheel scrolls.
+const wheelRows = 3
+
+// handleMouse handles a mouse event. Clicking moves the cursor (or, with
+// ctrl or alt held, adds a cursor), dragging selects, and the wheel
+// scrolls. Clicking or scrolling in another pane focuses it first.
+func (editor *Editor) handleMouse(mouse terminal.Mouse) {
+	// A drag stays with the pane it started in, even if it leaves it.
+	if mouse.Action == terminal.MouseDrag {
+		row, col, _ := editor.focus.screen.FromCell(mouse.X, mouse.Y)
+		editor.file.DragTo(row, col)
+		return
+	}
+
+	for _, p := range editor.layout.leaves() {
+		if _, _, ok := p.screen.FromCell(mouse.X, mouse.Y); ok && p != editor.focus {
+			editor.focusPane(p)
+			editor.arrangePanes()
+			break
+		}
+	}
+	row, col, ok := editor.focus.screen.FromCell(mouse.X, mouse.Y)
+	if !ok {
+		return
+	}
+
+	switch mouse.Action {
+	case terminal.MouseWheelUp:
+		for k := 0; k < wheelRows; k++ {
+			editor.file.ScrollDown()
+		}
+	case terminal.MouseWheelDown:
+		for k := 0; k < wheelRows; k++ {
+			editor.file.ScrollUp()
+		}
+	case terminal.MousePress:
+		// Ignore clicks on the status line.
+		if _, rows := editor.focus.screen.Size(); row >= rows-1 {
+			return
+		}
+		if mouse.Ctrl || mouse.Alt {
+			editor.file.AddCursorAt(row, col)
+		} else {
+			editor.file.Click(row, col)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package editor
+
+import (
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/terminal"
+)
+
+// pane is a window onto a file. The focused pane's view is the current
+// view of its file (editor.file). A pane remembers its view of each file it
+// has shown, for when it switches back.
+type pane struct {
+	file   *file.File
+	view   *file.View
+	views  map[*file.File]*file.View
+	screen *terminal.Screen
+	node   *layout
+}
+
+// layout is a node in the tree of panes. A leaf holds a pane; any other
+// node is split (side-by-side if vertical) between two children.
+type layout struct {
+	pane     *pane
+	vertical bool
+	ratio    float64
+	children [2]*layout
+	parent   *layout
+}
+
+// newLayout creates a layout with a single pane, which draws to screen.
+func newLayout(screen *terminal.Screen) (*layout, *pane) {
+	node := &layout{}
+	node.pane = &pane{screen: screen, node: node, views: map[*file.File]*file.View{}}
+	return node, node.pane
+}
+
+// leaves returns the panes in display order (left-to-right, top-to-bottom).
+func (node *layout) leaves() []*pane {
+	if node.pane != nil {
+		return []*pane{node.pane}
+	}
+	return append(node.children[0].leaves(), node.children[1].leaves()...)
+}
+
+// arrange assigns screen regions to the panes, and returns the positions
+// of the vertical separators.
+func (node *layout) arrange(region terminal.Region, seps []terminal.Region) []terminal.Region {
+	if node.pane != nil {
+		node.pane.screen.SetRegion(region)
+		return seps
+	}
+	first, second := region, region
+	if node.vertical {
+		width := int(float64(region.Cols-1) * node.ratio)
+		first.Cols = width
+		second.Col = region.Col + width + 1
+		second.Cols = region.Cols - width - 1
+		seps = append(seps, terminal.Region{Row: region.Row, Col: region.Col + width, Rows: region.Rows, Cols: 1})
+	} else {
+		height := int(float64(region.Rows) * node.ratio)
+		first.Rows = height
+		second.Row = region.Row + height
+		second.Rows = region.Rows - height
+	}
+	seps = node.children[0].arrange(first, seps)
+	return node.children[1].arrange(second, seps)
+}
+
+// savePane stores the focused pane's view, before focus moves elsewhere
+// (or the pane switches to another file).
+func (editor *Editor) savePane() {
+	if editor.file == nil {
+		return
+	}
+	editor.focus.file = editor.file
+	editor.focus.view = editor.file.GetView()
+	editor.focus.views[editor.file] = editor.focus.view
+}
+
+// showFile makes the focused pane show a file (which becomes the current
+// one), with the pane's own view of it.
+func (editor *Editor) showFile(f *file.File) {
+	editor.savePane()
+	view := editor.viewFor(editor.focus, f)
+	view.SetScreen(editor.focus.screen)
+	f.SetView(view)
+	editor.file = f
+	editor.focus.file = f
+	editor.focus.view = view
+	editor.focus.views[f] = view
+}
+
+// viewFor returns a pane's view of a file: the one it last had, or else
+// the file's current view, unless another pane has that one.
+func (editor *Editor) viewFor(p *pane, f *file.File) *file.View {
+	if view, ok := p.views[f]; ok {
+		return view
+	}
+	current := f.GetView()
+	for _, other := range editor.layout.leaves() {
+		if other != p && (other.view == current || other.views[f] == current) {
+			return f.NewView()
+		}
+	}
+	return current
+}
+
+// focusPane moves the focus to a pane, and makes its file the current one.
+func (editor *Editor) focusPane(p *pane) {
+	if p == editor.focus {
+		return
+	}
+	editor.savePane()
+	editor.focus = p
+	editor.file = p.file
+	editor.file.SetView(p.view)
+	for idx, f := range editor.files {
+		if f == p.file && idx != editor.fileIdx {
+			editor.fileIdxPrv = editor.fileIdx
+			editor.fileIdx = idx
+		}
+	}
+}
+
+// arrangePanes fits the panes to the terminal. A lone pane draws to the
+// whole screen; split panes each draw to a sub-screen.
+func (editor *Editor) arrangePanes() []terminal.Region {
+	panes := editor.layout.leaves()
+	if len(panes) == 1 {
+		panes[0].screen = editor.screen
+		editor.file.SetScreen(editor.screen)
+		return nil
+	}
+	for _, p := range panes {
+		if p.screen == editor.screen {
+			p.screen = editor.screen.Sub()
+		}
+		p.view.SetScreen(p.screen)
+	}
+	editor.file.SetScreen(editor.focus.screen)
+	cols, rows := editor.screen.TermSize()
+	return editor.layout.arrange(terminal.Region{Rows: rows, Cols: cols}, nil)
+}
+
+// SplitPane splits the current pane in two, both showing the current file.
+// The new pane (right or bottom) gets the focus.
+func (editor *Editor) SplitPane(vertical bool) {
+	editor.savePane()
+	old := editor.focus
+	node := old.node
+	newPane := &pane{file: editor.file, view: editor.file.NewView(), screen: editor.screen.Sub()}
+	newPane.views = map[*file.File]*file.View{editor.file: newPane.view}
+	node.children[0] = &layout{pane: old, parent: node}
+	node.children[1] = &layout{pane: newPane, parent: node}
+	old.node = node.children[0]
+	newPane.node = node.children[1]
+	node.pane = nil
+	node.vertical = vertical
+	node.ratio = 0.5
+	editor.arrangePanes()
+	editor.focusPane(newPane)
+	editor.arrangePanes()
+}
+
+// ClosePane closes the current pane (but not its file). Its sibling takes
+// over the space.
+func (editor *Editor) ClosePane() {
+	node := editor.focus.node
+	parent := node.parent
+	if parent == nil {
+		editor.screen.Notify("Can't close the only pane")
+		return
+	}
+	sibling := parent.children[0]
+	if sibling == node {
+		sibling = parent.children[1]
+	}
+	// The sibling replaces the parent in the tree.
+	parent.pane = sibling.pane
+	parent.vertical = sibling.vertical
+	parent.ratio = sibling.ratio
+	parent.children = sibling.children
+	for _, child := range parent.children {
+		if child != nil {
+			child.parent = parent
+		}
+	}
+	if parent.pane != nil {
+		parent.pane.node = parent
+	}
+	editor.focusPane(parent.leaves()[0])
+	editor.arrangePanes()
+}
+
+// NextPane moves the focus to the next (dir > 0) or previous pane.
+func (editor *Editor) NextPane(dir int) {
+	panes := editor.layout.leaves()
+	for idx, p := range panes {
+		if p == editor.focus {
+			editor.focusPane(panes[intMod(idx+dir, len(panes))])
+			break
+		}
+	}
+	editor.arrangePanes()
+}
+
+// ResizePane grows (delta > 0) or shrinks the current pane.
+func (editor *Editor) ResizePane(delta float64) {
+	node := editor.focus.node
+	parent := node.parent
+	if parent == nil {
+		return
+	}
+	if parent.children[1] == node {
+		delta = -delta
+	}
+	parent.ratio += delta
+	if parent.ratio < 0.1 {
+		parent.ratio = 0.1
+	}
+	if parent.ratio > 0.9 {
+		parent.ratio = 0.9
+	}
+	editor.arrangePanes()
+}
+
+// replaceInPanes points any (unfocused) panes showing a closed file at
+// another file, and makes the panes forget their views of the closed file.
+func (editor *Editor) replaceInPanes(closed, other *file.File) {
+	for _, p := range editor.layout.leaves() {
+		delete(p.views, closed)
+		if p != editor.focus && p.file == closed {
+			p.file = other
+			p.view = editor.viewFor(p, other)
+			p.views[other] = p.view
+			p.view.SetScreen(p.screen)
+		}
+	}
+}
+
+// flushPanes draws all the panes. The focused pane is drawn last, so that
+// it gets the terminal cursor.
+func (editor *Editor) flushPanes() {
+	seps := editor.arrangePanes()
+	for _, p := range editor.layout.leaves() {
+		if p == editor.focus {
+			continue
+		}
+		fileIdx := 0
+		for idx, f := range editor.files {
+			if f == p.file {
+				fileIdx = idx
+			}
+		}
+		editor.flushFile(p.file.WithView(p.view), fileIdx, p.screen, false)
+	}
+	for _, sep := range seps {
+		editor.screen.DrawVLine(sep.Col, sep.Row, sep.Rows)
+	}
+	editor.flushFile(editor.file, editor.fileIdx, editor.focus.screen, true)
+}
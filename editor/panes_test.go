@@ -0,0 +1,78 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wx13/sith/editor"
+	"github.com/wx13/sith/file"
+)
+
+func TestPanes(t *testing.T) {
+	dir := t.TempDir()
+	file.SetStateDir(filepath.Join(dir, "state"))
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("one\ntwo\nthree"), 0644)
+	os.WriteFile(b, []byte("bee"), 0644)
+	e := editor.NewTestEditor(40, 10, a, b)
+
+	cursor := func(pane, row, col int, msg string) {
+		t.Helper()
+		panes := e.PaneFiles()
+		if r, c := panes[pane].GetRowCol(0); r != row || c != col {
+			t.Errorf("%s: pane %d cursor at %d,%d, expected %d,%d", msg, pane, r, c, row, col)
+		}
+	}
+
+	// Both panes show the file, each with its own cursor, and edits in
+	// one move the cursor in the other.
+	e.CurrentFile().MultiCursor.Set(2, 0, 0)
+	e.SplitPane(true)
+	sizes := e.PaneSizes()
+	if len(sizes) != 2 || sizes[0][0] > sizes[1][0] || sizes[1][0] > sizes[0][0]+1 {
+		t.Fatal("the panes should split the screen evenly:", sizes)
+	}
+	e.CurrentFile().MultiCursor.Set(0, 0, 0)
+	e.CurrentFile().Newline()
+	cursor(0, 3, 0, "edit in the other pane")
+	cursor(1, 1, 0, "edit in this pane")
+
+	// Switching to a file another pane shows gives this pane its own view.
+	e.SwitchFile(1)
+	e.NextPane(-1)
+	e.SwitchFile(1)
+	e.CurrentFile().MultiCursor.Set(0, 3, 3)
+	cursor(0, 0, 3, "moved cursor")
+	cursor(1, 0, 0, "other pane on the same file")
+
+	// Switching back brings back the pane's view of the file.
+	e.SwitchFile(0)
+	cursor(0, 3, 0, "switched back")
+	cursor(1, 0, 0, "other pane after switching back")
+
+	e.ResizePane(0.2)
+	if sizes := e.PaneSizes(); sizes[0][0] <= sizes[1][0] {
+		t.Error("the focused pane should have grown:", sizes)
+	}
+
+	// Closing a file moves the other panes showing it to another file,
+	// with their own views.
+	e.SwitchFile(1)
+	e.CloseFile()
+	panes := e.PaneFiles()
+	if panes[0].Name != a || panes[1].Name != a {
+		t.Fatal("both panes should show the remaining file:", panes[0].Name, panes[1].Name)
+	}
+	cursor(0, 3, 0, "after closing a file")
+	cursor(1, 1, 0, "other pane after closing a file")
+
+	// Closing a pane gives its space to the other one.
+	e.ClosePane()
+	if len(e.PaneSizes()) != 1 || e.PaneSizes()[0][0] <= sizes[1][0]+1 {
+		t.Error("one pane should fill the screen:", e.PaneSizes())
+	}
+	if r, c := e.CurrentFile().GetRowCol(0); r != 1 || c != 0 {
+		t.Error("the remaining pane should keep its cursor:", r, c)
+	}
+}
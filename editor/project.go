@@ -0,0 +1,189 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/wx13/sith/project"
+	"github.com/wx13/sith/ui"
+)
+
+const (
+	// maxProjectMatches limits the number of project search results.
+	maxProjectMatches = 5000
+	// maxFinderResults limits the number of files shown by the file finder.
+	maxFinderResults = 500
+	// indexMaxAge is how old the file index can get before it is rebuilt.
+	indexMaxAge = time.Minute
+)
+
+// grepProject prompts for a search term and searches the project files.
+func (editor *Editor) grepProject() (string, []project.Match, error) {
+	searchTerm, err := editor.searchPrompt()
+	if err != nil {
+		return "", nil, err
+	}
+	editor.screen.Notify("Searching...")
+	editor.screen.Flush()
+	matches, err := project.Grep(".", searchTerm, maxProjectMatches)
+	if err != nil {
+		editor.screen.Notify(err.Error())
+		return "", nil, err
+	}
+	if len(matches) == 0 {
+		editor.screen.Notify("Not Found")
+		return "", nil, fmt.Errorf("not found")
+	}
+	return searchTerm, matches, nil
+}
+
+// FindFile offers a fuzzy-search menu of all the files in the project,
+// with recently opened files first. Ctrl-O creates a new file instead.
+func (editor *Editor) FindFile() {
+	if editor.index == nil {
+		editor.index = project.NewIndex(".")
+	} else if editor.index.Age() > indexMaxAge {
+		editor.index.Refresh()
+	}
+	if _, done := editor.index.Files(); !done {
+		editor.screen.Notify("Indexing files...")
+	}
+	recent := editor.session.GetRecent()
+	filter := func(pattern string) []string {
+		files, _ := editor.index.Files()
+		ranked := project.Rank(pattern, files, recent)
+		if len(ranked) > maxFinderResults {
+			ranked = ranked[:maxFinderResults]
+		}
+		return ranked
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	name, key := menu.ChooseLive(filter, "", "ctrlO")
+	editor.Flush()
+	if key == "cancel" {
+		return
+	}
+	if key == "ctrlO" {
+		prompt := ui.MakePrompt(editor.screen, editor.keyboard)
+		var err error
+		name, err = prompt.Ask("new file:", nil)
+		if err != nil {
+			editor.screen.Notify("Cancelled")
+			return
+		}
+	}
+	if name == "" {
+		return
+	}
+	editor.switchToFile(name)
+}
+
+// addRecent records a file in the session's list of recent files.
+func (editor *Editor) addRecent(name string) {
+	if name == "" || editor.session == nil {
+		return
+	}
+	editor.session.AddRecent(filepath.ToSlash(filepath.Clean(name)))
+}
+
+// ProjectSearch searches all the files in the working directory (not just
+// the open ones) and offers a menu of matches.
+func (editor *Editor) ProjectSearch() {
+	_, matches, err := editor.grepProject()
+	if err != nil {
+		return
+	}
+	choices := make([]string, len(matches))
+	for k, match := range matches {
+		choices[k] = match.String()
+	}
+	if len(matches) == maxProjectMatches {
+		editor.screen.Notify(fmt.Sprintf("Showing the first %d matches", maxProjectMatches))
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	idx, key := menu.Choose(choices, 0, "")
+	editor.Flush()
+	if key == "cancel" || idx < 0 || idx >= len(matches) {
+		return
+	}
+	match := matches[idx]
+	editor.OpenFileAt(match.Path, match.Row, match.Col)
+}
+
+// ProjectSearchAndReplace replaces matches in all the files in the working
+// directory, asking for confirmation once per file. Changed files are
+// opened, but not saved.
+func (editor *Editor) ProjectSearchAndReplace() {
+	searchTerm, matches, err := editor.grepProject()
+	if err != nil {
+		return
+	}
+	prompt := ui.MakePrompt(editor.screen, editor.keyboard)
+	replaceTerm := prompt.GetAnswer("replace:", &editor.replaceHist)
+
+	// Group the matches by file.
+	paths := []string{}
+	counts := map[string]int{}
+	for _, match := range matches {
+		if counts[match.Path] == 0 {
+			paths = append(paths, match.Path)
+		}
+		counts[match.Path]++
+	}
+
+	numFiles, numReplaced := 0, 0
+	for _, path := range paths {
+		question := fmt.Sprintf("Replace in %s (%d lines)?", path, counts[path])
+		doReplace, err := prompt.AskYesNo(question)
+		if err != nil {
+			editor.screen.Notify("Cancelled")
+			break
+		}
+		if !doReplace {
+			continue
+		}
+		editor.OpenFileAt(path, 0, 0)
+		n := editor.file.ReplaceAll(searchTerm, replaceTerm)
+		if n > 0 {
+			numFiles++
+			numReplaced += n
+		}
+		editor.Flush()
+	}
+	editor.screen.Notify(fmt.Sprintf("Replaced %d matches in %d files", numReplaced, numFiles))
+}
+
+// OpenFileAt switches to the named file (opening it if need be), and moves
+// the cursor to row, col.
+func (editor *Editor) OpenFileAt(name string, row, col int) {
+	editor.switchToFile(name)
+	editor.file.ClearCursors()
+	editor.file.CursorGoTo(row, col)
+}
+
+// switchToFile switches to the named file, opening it if it is not
+// already open.
+func (editor *Editor) switchToFile(name string) {
+	idx := editor.findFile(name)
+	if idx < 0 {
+		editor.OpenFile(name)
+		idx = len(editor.files) - 1
+	}
+	editor.SwitchFile(idx)
+}
+
+// findFile returns the index of an open file, or -1 if the file is not open.
+func (editor *Editor) findFile(name string) int {
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return -1
+	}
+	for idx, file := range editor.files {
+		absFile, err := filepath.Abs(file.Name)
+		if err == nil && absFile == absName {
+			return idx
+		}
+	}
+	return -1
+}
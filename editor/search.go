@@ -137,7 +137,9 @@ func (editor *Editor) SearchAndReplace(multiFile bool) {
 		return
 	}
 
-	if editor.file.MultiCursor.Length() > 1 {
+	if editor.file.HasSelection() {
+		editor.SelectionSearchAndReplace(searchTerm, replaceTerm, replaceAll)
+	} else if editor.file.MultiCursor.Length() > 1 {
 		editor.MarkedSearchAndReplace(searchTerm, replaceTerm, replaceAll)
 	} else {
 		editor.MultiFileSearchAndReplace(searchTerm, replaceTerm, multiFile, replaceAll)
@@ -164,6 +166,38 @@ func (editor *Editor) MarkedSearchAndReplace(searchTerm, replaceTerm string, rep
 	}
 }
 
+// SelectionSearchAndReplace does search-and-replace within the selection.
+func (editor *Editor) SelectionSearchAndReplace(searchTerm, replaceTerm string, replaceAll bool) {
+	regions := editor.file.Selections()
+	editor.file.ClearSelection()
+	// Work backwards, so that replacements don't shift the remaining regions.
+	for k := len(regions) - 1; k >= 0; k-- {
+		region := regions[k]
+		row, col := region.StartRow, region.StartCol
+		for {
+			r, c, err := editor.file.SearchRegion(searchTerm, region, row, col)
+			if err != nil {
+				break
+			}
+			n := editor.file.RowLength(r)
+			err = editor.file.AskReplace(searchTerm, replaceTerm, r, c, replaceAll)
+			if err != nil {
+				editor.screen.Notify("Cancelled")
+				return
+			}
+			delta := editor.file.RowLength(r) - n
+			if r == region.EndRow {
+				region.EndCol += delta
+			}
+			// If nothing was replaced, step past this match.
+			row, col = editor.file.GetRowCol(0)
+			if row == r && col == c && delta == 0 {
+				col++
+			}
+		}
+	}
+}
+
 // MultiFileSearchAndReplace is just like SearchAndReplace but for all the file buffers.
 func (editor *Editor) MultiFileSearchAndReplace(searchTerm, replaceTerm string, multiFile, replaceAll bool) {
 
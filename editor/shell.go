@@ -0,0 +1,148 @@
+package editor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/ui"
+)
+
+// commandTimeout is how long a shell command gets to run.
+const commandTimeout = 30 * time.Second
+
+// askCommand prompts for a shell command. An empty answer repeats the last
+// command.
+func (editor *Editor) askCommand(question string) (string, bool) {
+	prompt := ui.MakePrompt(editor.screen, editor.keyboard)
+	command := prompt.GetAnswer(question, &editor.cmdHist)
+	if strings.TrimSpace(command) == "" {
+		editor.screen.Notify("Cancelled")
+		return "", false
+	}
+	return command, true
+}
+
+// runCommand calls fn (which runs shell commands) in the background, and
+// waits for it. Meanwhile, ctrl-C cancels the commands, and they time out
+// after commandTimeout. Other keys typed meanwhile are kept for later.
+func (editor *Editor) runCommand(command string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	// The interrupt is only sent if we are waiting for it, so that a stray
+	// one doesn't turn up at the next prompt.
+	var mutex sync.Mutex
+	waiting, finished := false, false
+	go func() {
+		fn(ctx)
+		close(done)
+		mutex.Lock()
+		defer mutex.Unlock()
+		finished = true
+		if waiting {
+			editor.keyboard.Interrupt()
+		}
+	}()
+
+	// Keys being played back from a macro aren't for us.
+	if !editor.keyboard.Macros.Playing() {
+		mutex.Lock()
+		waiting = !finished
+		mutex.Unlock()
+		if waiting {
+			editor.screen.WriteMessage("Running " + command + " (ctrl-C to cancel)")
+			editor.screen.Flush()
+			for {
+				editor.keyboard.Wait(cancel)
+				// Some other interrupt may have woken us.
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}
+	}
+	<-done
+}
+
+// reportCommand tells the user about a failed command, or anything it
+// wrote to standard error.
+func (editor *Editor) reportCommand(command, stderr string, err error) {
+	switch {
+	case err != nil:
+		editor.file.NotifyUser(command + ": " + err.Error())
+	case stderr != "":
+		editor.file.NotifyUser(stderr)
+	}
+}
+
+// Filter pipes text through a shell command, replacing it with the output:
+// the selected lines, each cursor's line, or else the whole buffer.
+func (editor *Editor) Filter() {
+	if editor.file.ReadOnly() {
+		editor.screen.Notify("Read only")
+		return
+	}
+	command, ok := editor.askCommand("Filter through:")
+	if !ok {
+		return
+	}
+	f := editor.file
+	var stderr string
+	var err error
+	editor.runCommand(command, func(ctx context.Context) {
+		err = f.Filter(func(input string) (string, error) {
+			out, msg, err := file.RunShell(ctx, command, input)
+			if msg != "" {
+				stderr = msg
+			}
+			return out, err
+		})
+	})
+	editor.reportCommand(command, stderr, err)
+}
+
+// InsertCommandOutput inserts the output of a shell command at the cursor.
+func (editor *Editor) InsertCommandOutput() {
+	if editor.file.ReadOnly() {
+		editor.screen.Notify("Read only")
+		return
+	}
+	command, ok := editor.askCommand("Insert output of:")
+	if !ok {
+		return
+	}
+	var out, stderr string
+	var err error
+	editor.runCommand(command, func(ctx context.Context) {
+		out, stderr, err = file.RunShell(ctx, command, "")
+	})
+	if err == nil {
+		editor.file.InsertPasted(strings.TrimSuffix(out, "\n"))
+	}
+	editor.reportCommand(command, stderr, err)
+}
+
+// CommandOutput shows the output of a shell command in a new, read-only
+// buffer.
+func (editor *Editor) CommandOutput() {
+	command, ok := editor.askCommand("Show output of:")
+	if !ok {
+		return
+	}
+	var out, stderr string
+	var err error
+	editor.runCommand(command, func(ctx context.Context) {
+		out, stderr, err = file.RunShell(ctx, command, "")
+	})
+	if out != "" || err == nil {
+		scratch := file.NewScratch("["+command+"]", out, editor.flushChan, editor.screen, editor.cfg)
+		editor.files = append(editor.files, scratch)
+		editor.SwitchFile(len(editor.files) - 1)
+	}
+	editor.reportCommand(command, stderr, err)
+}
@@ -0,0 +1,40 @@
+package editor
+
+import (
+	"github.com/wx13/sith/theme"
+	"github.com/wx13/sith/ui"
+)
+
+// ThemeMenu lets the user choose a color theme.
+func (editor *Editor) ThemeMenu() {
+	names := theme.Names()
+	current := 0
+	for k, name := range names {
+		if name == theme.Name() {
+			current = k
+		}
+	}
+	menu := ui.NewMenu(editor.screen, editor.keyboard)
+	idx, key := menu.Choose(names, current, "")
+	if idx < 0 || key == "cancel" {
+		editor.Flush()
+		return
+	}
+	if err := editor.SetTheme(names[idx]); err != nil {
+		editor.screen.Notify(err.Error())
+	}
+	editor.Flush()
+}
+
+// SetTheme switches to a color theme.
+func (editor *Editor) SetTheme(name string) error {
+	t, err := theme.Load(name)
+	if err != nil {
+		return err
+	}
+	theme.Use(t)
+	for _, f := range editor.files {
+		f.RefreshSyntax()
+	}
+	return nil
+}
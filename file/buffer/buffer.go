@@ -1,6 +1,6 @@
 // Package buffer provides a single editable text buffer.
-// The text is stored as a slice of Lines (split on line-endings).
-// A Line is a wrapper around a slice of runes.
+// The text is stored as a rope (a persistent tree) of Lines (split on
+// line-endings). A Line is a wrapper around a slice of runes.
 package buffer
 
 import (
@@ -11,10 +11,12 @@ import (
 	"sync"
 )
 
-// Buffer is an array of Line objects.
+// Buffer is a sequence of Line objects. Copies of a Buffer value are the
+// same buffer; use Dup for a separate one.
 type Buffer struct {
-	lines []Line
+	text  *text
 	mutex *sync.Mutex
+	log   *changeLog
 }
 
 // Cursor is any object which returns a row/col position.
@@ -31,45 +33,41 @@ func MakeBuffer(stringBuf []string) Buffer {
 		lines[row] = MakeLine(str)
 	}
 	return Buffer{
-		lines: lines,
+		text:  &text{lines: makeRope(lines)},
 		mutex: &sync.Mutex{},
+		log:   &changeLog{},
 	}
 }
 
 // Lines returns the slice of lines that the buffer contains. The slice
 // is a "deep copy" of the buffer's internal Line slice.
 func (buffer *Buffer) Lines() []Line {
-	lines := buffer.DeepDup().lines
+	buffer.mutex.Lock()
+	lines := buffer.text.lines.Slice(0, buffer.text.lines.Len())
+	buffer.mutex.Unlock()
+	for row, line := range lines {
+		lines[row] = line.Dup()
+	}
 	return lines
 }
 
-// Dup creates a new buffer with the same lines. The lines are shallow
-// copies of the original lines.
+// Dup creates a new buffer with the same lines. The new buffer shares
+// its structure with the original, so this takes constant time.
 func (buffer *Buffer) Dup() Buffer {
 	buffer.mutex.Lock()
-	linesCopy := make([]Line, len(buffer.lines))
-	for row, line := range buffer.lines {
-		linesCopy[row] = line
-	}
+	lines := buffer.text.lines
 	buffer.mutex.Unlock()
 	return Buffer{
-		lines: linesCopy,
+		text:  &text{lines: lines},
 		mutex: &sync.Mutex{},
+		log:   &changeLog{},
 	}
 }
 
-// DeepDup creates a new buffer with copies of the lines.
+// DeepDup creates a new buffer with copies of the lines. Lines are never
+// modified once they are in a buffer, so this is the same as Dup.
 func (buffer *Buffer) DeepDup() Buffer {
-	buffer.mutex.Lock()
-	linesCopy := make([]Line, len(buffer.lines))
-	for row, line := range buffer.lines {
-		linesCopy[row] = line.Dup()
-	}
-	buffer.mutex.Unlock()
-	return Buffer{
-		lines: linesCopy,
-		mutex: &sync.Mutex{},
-	}
+	return buffer.Dup()
 }
 
 // Length returns the number of lines in the buffer.
@@ -78,42 +76,35 @@ func (buffer *Buffer) Length() int {
 		return 0
 	}
 	buffer.mutex.Lock()
-	n := len(buffer.lines)
+	n := buffer.text.lines.Len()
 	buffer.mutex.Unlock()
 	return n
 }
 
 // ReplaceBuffer replaces the content (lines) with the content from
-// another buffer. If the buffer got shorter, then just copy over the
-// lines. Otherwise, check each line for equality, and only replace
-// if changed.
+// another buffer. The lines are shared with the other buffer, and only the
+// rows from the first difference on count as changed.
 func (buffer *Buffer) ReplaceBuffer(newBuffer Buffer) {
+	newBuffer.mutex.Lock()
+	lines := newBuffer.text.lines
+	newBuffer.mutex.Unlock()
 
-	newLen := newBuffer.Length()
-	bufLen := buffer.Length()
-
-	if newLen <= bufLen {
-		buffer.mutex.Lock()
-		buffer.lines = buffer.lines[:newLen]
-		buffer.mutex.Unlock()
-	}
-
-	for k, line := range newBuffer.Lines() {
-		if k >= bufLen {
-			buffer.Append(line)
-		} else {
-			if buffer.GetRow(k).ToString() != line.ToString() {
-				buffer.ReplaceLine(line, k)
-			}
-		}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	row := firstDiff(buffer.text.lines, lines)
+	if row >= 0 {
+		delta := lines.Len() - buffer.text.lines.Len()
+		buffer.text.lines = lines
+		buffer.changed(row, delta)
 	}
-
 }
 
 // Append appends a new line on to the buffer.
 func (buffer *Buffer) Append(line ...Line) {
 	buffer.mutex.Lock()
-	buffer.lines = append(buffer.lines, line...)
+	length := buffer.text.lines.Len()
+	buffer.changed(length, len(line))
+	buffer.text.lines = buffer.text.lines.Splice(length, length, line...)
 	buffer.mutex.Unlock()
 }
 
@@ -126,15 +117,16 @@ func (buffer *Buffer) InclSlice(row1, row2 int) *Buffer {
 		row2 += buffer.Length()
 	}
 	buffer.mutex.Lock()
-	lines := buffer.lines[row1 : row2+1]
+	lines, _ := buffer.text.lines.Split(row2 + 1)
+	_, lines = lines.Split(row1)
 	buffer.mutex.Unlock()
-	return &Buffer{lines: lines, mutex: &sync.Mutex{}}
+	return &Buffer{text: &text{lines: lines}, mutex: &sync.Mutex{}}
 }
 
 // RowSlice returns a Line containing a subset of the line at 'row'.
 func (buffer *Buffer) RowSlice(row, startCol, endCol int) Line {
 	buffer.mutex.Lock()
-	line := buffer.lines[row].Slice(startCol, endCol)
+	line := buffer.text.lines.Get(row).Slice(startCol, endCol)
 	buffer.mutex.Unlock()
 	return line
 }
@@ -144,11 +136,12 @@ func (buffer *Buffer) RowSlice(row, startCol, endCol int) Line {
 // and start and end columns. Also specify the tab width, because all tabs
 // are converted to spaces.
 func (buffer *Buffer) StrSlab(row1, row2, col1, col2, tabwidth int) []string {
-	lines := buffer.Lines()[row1:row2]
-	strs := make([]string, len(lines))
-	for idx, line := range lines {
-		strs[idx] = line.StrSlice(col1, col2, tabwidth)
-	}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	strs := make([]string, 0, row2-row1)
+	buffer.text.lines.Each(row1, row2, func(row int, line Line) {
+		strs = append(strs, line.StrSlice(col1, col2, tabwidth))
+	})
 	return strs
 }
 
@@ -186,7 +179,8 @@ func (buffer *Buffer) ToCorpus(cursors map[int][]int) string {
 // InsertAfter inserts a set of lines after the specified row in the buffer.
 func (buffer *Buffer) InsertAfter(row int, lines ...Line) {
 	buffer.mutex.Lock()
-	buffer.lines = append(buffer.lines[:row+1], append(lines, buffer.lines[row+1:]...)...)
+	buffer.text.lines = buffer.text.lines.Splice(row+1, row+1, lines...)
+	buffer.changed(row+1, len(lines))
 	buffer.mutex.Unlock()
 }
 
@@ -194,14 +188,12 @@ func (buffer *Buffer) InsertAfter(row int, lines ...Line) {
 func (buffer *Buffer) DeleteRow(row int) {
 	buffer.mutex.Lock()
 	defer buffer.mutex.Unlock()
-	if len(buffer.lines) == 1 {
-		buffer.lines = []Line{MakeLine("")}
-	} else if row == 0 {
-		buffer.lines = buffer.lines[1:]
-	} else if row < len(buffer.lines)-1 {
-		buffer.lines = append(buffer.lines[:row], buffer.lines[row+1:]...)
+	if buffer.text.lines.Len() == 1 {
+		buffer.changed(row, 0)
+		buffer.text.lines = makeRope([]Line{MakeLine("")})
 	} else {
-		buffer.lines = buffer.lines[:row]
+		buffer.changed(row, -1)
+		buffer.text.lines = buffer.text.lines.Splice(row, row+1)
 	}
 }
 
@@ -211,7 +203,8 @@ func (buffer *Buffer) ReplaceLine(line Line, row int) {
 		return
 	}
 	buffer.mutex.Lock()
-	buffer.lines[row] = line
+	buffer.text.lines = buffer.text.lines.Set(row, line)
+	buffer.changed(row, 0)
 	defer buffer.mutex.Unlock()
 }
 
@@ -230,7 +223,8 @@ func (buffer *Buffer) MergeRows(row int) error {
 // ReplaceLines replaces the lines from minRow to maxRow with lines.
 func (buffer *Buffer) ReplaceLines(lines []Line, minRow, maxRow int) {
 	buffer.mutex.Lock()
-	buffer.lines = append(buffer.lines[:minRow], append(lines, buffer.lines[maxRow+1:]...)...)
+	buffer.text.lines = buffer.text.lines.Splice(minRow, maxRow+1, lines...)
+	buffer.changed(minRow, len(lines)-(maxRow+1-minRow))
 	buffer.mutex.Unlock()
 }
 
@@ -274,12 +268,12 @@ func (buffer *Buffer) Search(searchTerm string, cursor Cursor, loop bool) (int,
 	return cursor.Row(), cursor.Col(), errors.New("Not Found")
 }
 
-// Replace replaces occurrences of a string within a line.
-func (buffer *Buffer) ReplaceWord(searchTerm, replaceTerm string, row, col int) {
-	startCol, endCol := buffer.GetRowDirect(row).Search(searchTerm, col, -1)
-	strLine := buffer.GetRowDirect(row).ToString()
-	newStrLine := strLine[:startCol] + replaceTerm + strLine[endCol:]
-	buffer.lines[row] = MakeLine(newStrLine)
+// ReplaceWord replaces the first match of searchTerm at (row, col). Regex
+// capture groups ($1, ${name}) are expanded. It returns the replacement text.
+func (buffer *Buffer) ReplaceWord(searchTerm, replaceTerm string, row, col int) string {
+	line, replacement := buffer.GetRowDirect(row).Replace(searchTerm, replaceTerm, col)
+	buffer.SetRow(row, line)
+	return replacement
 }
 
 // GetRow returns a copy of the Line at the specified row index.
@@ -287,10 +281,10 @@ func (buffer *Buffer) ReplaceWord(searchTerm, replaceTerm string, row, col int)
 func (buffer *Buffer) GetRow(row int) Line {
 	buffer.mutex.Lock()
 	defer buffer.mutex.Unlock()
-	if row < 0 || row >= len(buffer.lines) {
+	if row < 0 || row >= buffer.text.lines.Len() {
 		return MakeLine("")
 	}
-	line := buffer.lines[row]
+	line := buffer.text.lines.Get(row)
 	return MakeLine(line.ToString())
 }
 
@@ -300,10 +294,10 @@ func (buffer *Buffer) GetRow(row int) Line {
 func (buffer *Buffer) GetRowDirect(row int) Line {
 	buffer.mutex.Lock()
 	defer buffer.mutex.Unlock()
-	if row < 0 || row >= len(buffer.lines) {
+	if row < 0 || row >= buffer.text.lines.Len() {
 		return MakeLine("")
 	}
-	return buffer.lines[row]
+	return buffer.text.lines.Get(row)
 }
 
 // SetRow replaces the line at the specified row index.
@@ -312,7 +306,8 @@ func (buffer *Buffer) SetRow(row int, line Line) error {
 		return errors.New("index exceeds buffer length")
 	}
 	buffer.mutex.Lock()
-	buffer.lines[row] = line
+	buffer.text.lines = buffer.text.lines.Set(row, line)
+	buffer.changed(row, 0)
 	buffer.mutex.Unlock()
 	return nil
 }
@@ -386,12 +381,7 @@ func (buffer *Buffer) Equals(buffer2 *Buffer) bool {
 	buffer2.mutex.Lock()
 	defer buffer2.mutex.Unlock()
 
-	for idx := range buffer.lines {
-		if buffer.lines[idx].ToString() != buffer2.lines[idx].ToString() {
-			return false
-		}
-	}
-	return true
+	return firstDiff(buffer.text.lines, buffer2.text.lines) < 0
 }
 
 func (buffer *Buffer) hasLines(lines ...string) bool {
@@ -401,7 +391,7 @@ func (buffer *Buffer) hasLines(lines ...string) bool {
 	buffer.mutex.Lock()
 	defer buffer.mutex.Unlock()
 	for i := range lines {
-		if buffer.lines[i].ToString() != lines[i] {
+		if buffer.text.lines.Get(i).ToString() != lines[i] {
 			return false
 		}
 	}
@@ -703,388 +693,3 @@ func (buffer *Buffer) CutWord(row, col, mode int) int {
 	buffer.SetRow(row, line)
 	return newCol
 }
-
-// LineStatus represents the diff status of a line.
-type LineStatus int
-
-const (
-	LineUnchanged LineStatus = iota
-	LineModified
-	LineAdded
-)
-
-// DiffResult contains the full diff information including deletions.
-type DiffResult struct {
-	// Changes maps line numbers to their status (modified or added)
-	Changes map[int]LineStatus
-	// DeletionPoints contains line numbers *after which* deletions occurred.
-	// A value of -1 means deletions at the very beginning of the file.
-	DeletionPoints []int
-}
-
-// DiffLines computes the diff between this buffer and another buffer (typically the saved version).
-// Returns a map from line number (in this buffer) to its status.
-func (buffer *Buffer) DiffLines(saved *Buffer) map[int]LineStatus {
-	buffer.mutex.Lock()
-	defer buffer.mutex.Unlock()
-	saved.mutex.Lock()
-	defer saved.mutex.Unlock()
-
-	result := make(map[int]LineStatus)
-
-	// Build string slices for comparison
-	current := make([]string, len(buffer.lines))
-	for i, line := range buffer.lines {
-		current[i] = line.ToString()
-	}
-
-	savedLines := make([]string, len(saved.lines))
-	for i, line := range saved.lines {
-		savedLines[i] = line.ToString()
-	}
-
-	// Compute LCS to find unchanged lines
-	lcs := computeLCS(current, savedLines)
-
-	// Map LCS back to indices in both buffers
-	// For current buffer: which lines are unchanged
-	currentInLCS := make([]bool, len(current))
-	lcsIdx := 0
-	for i := 0; i < len(current) && lcsIdx < len(lcs); i++ {
-		if current[i] == lcs[lcsIdx] {
-			currentInLCS[i] = true
-			lcsIdx++
-		}
-	}
-
-	// For saved buffer: which lines are unchanged
-	savedInLCS := make([]bool, len(savedLines))
-	lcsIdx = 0
-	for i := 0; i < len(savedLines) && lcsIdx < len(lcs); i++ {
-		if savedLines[i] == lcs[lcsIdx] {
-			savedInLCS[i] = true
-			lcsIdx++
-		}
-	}
-
-	// Walk through both buffers in parallel, matching LCS positions
-	// Lines not in LCS in current are either added or modified
-	// - Modified: replaces a line from saved that's also not in LCS
-	// - Added: extra line with no corresponding deleted line
-	curIdx := 0
-	savedIdx := 0
-
-	for curIdx < len(current) {
-		if currentInLCS[curIdx] {
-			// This line is unchanged, advance both pointers past it
-			// Skip any non-LCS lines in saved (those were deleted)
-			for savedIdx < len(savedLines) && !savedInLCS[savedIdx] {
-				savedIdx++
-			}
-			savedIdx++ // skip the matching LCS line in saved
-			curIdx++
-			continue
-		}
-
-		// Current line is not in LCS - it's new content
-		// Check if there's a corresponding deleted line in saved
-		if savedIdx < len(savedLines) && !savedInLCS[savedIdx] {
-			// There's a deleted line here - this is a modification
-			result[curIdx] = LineModified
-			savedIdx++
-		} else {
-			// No deleted line to pair with - this is an addition
-			result[curIdx] = LineAdded
-		}
-		curIdx++
-	}
-
-	return result
-}
-
-// DiffLinesFull computes the diff including deletion points.
-func (buffer *Buffer) DiffLinesFull(saved *Buffer) DiffResult {
-	buffer.mutex.Lock()
-	defer buffer.mutex.Unlock()
-	saved.mutex.Lock()
-	defer saved.mutex.Unlock()
-
-	result := DiffResult{
-		Changes:        make(map[int]LineStatus),
-		DeletionPoints: []int{},
-	}
-
-	// Build string slices for comparison
-	current := make([]string, len(buffer.lines))
-	for i, line := range buffer.lines {
-		current[i] = line.ToString()
-	}
-
-	savedLines := make([]string, len(saved.lines))
-	for i, line := range saved.lines {
-		savedLines[i] = line.ToString()
-	}
-
-	// Compute LCS to find unchanged lines
-	lcs := computeLCS(current, savedLines)
-
-	// Map LCS entries to their positions in both buffers
-	currentLCSPos := make([]int, len(lcs))
-	savedLCSPos := make([]int, len(lcs))
-
-	lcsIdx := 0
-	for i := 0; i < len(current) && lcsIdx < len(lcs); i++ {
-		if current[i] == lcs[lcsIdx] {
-			currentLCSPos[lcsIdx] = i
-			lcsIdx++
-		}
-	}
-
-	lcsIdx = 0
-	for i := 0; i < len(savedLines) && lcsIdx < len(lcs); i++ {
-		if savedLines[i] == lcs[lcsIdx] {
-			savedLCSPos[lcsIdx] = i
-			lcsIdx++
-		}
-	}
-
-	// Build sets of which lines are matched to LCS
-	currentInLCS := make(map[int]bool)
-	savedInLCS := make(map[int]bool)
-	for i := 0; i < len(lcs); i++ {
-		currentInLCS[currentLCSPos[i]] = true
-		savedInLCS[savedLCSPos[i]] = true
-	}
-
-	// Process chunks between LCS anchors
-	prevCurAnchor := -1
-	prevSavedAnchor := -1
-
-	processChunk := func(curStart, curEnd, savedStart, savedEnd int) {
-		curCount := curEnd - curStart
-		savedCount := savedEnd - savedStart
-		minCount := curCount
-		if savedCount < minCount {
-			minCount = savedCount
-		}
-
-		// Mark changes in current
-		for i := 0; i < curCount; i++ {
-			if i < minCount {
-				result.Changes[curStart+i] = LineModified
-			} else {
-				result.Changes[curStart+i] = LineAdded
-			}
-		}
-
-		// If there are more deleted lines than added/modified, record deletion point
-		if savedCount > curCount {
-			// Deletions occurred after line (curStart - 1) in current buffer
-			// If curStart is 0, deletions are at the very beginning (-1)
-			deletionPoint := curStart - 1
-			result.DeletionPoints = append(result.DeletionPoints, deletionPoint)
-		}
-	}
-
-	// Walk through LCS anchors and process chunks
-	for i := 0; i < len(lcs); i++ {
-		curAnchor := currentLCSPos[i]
-		savedAnchor := savedLCSPos[i]
-		processChunk(prevCurAnchor+1, curAnchor, prevSavedAnchor+1, savedAnchor)
-		prevCurAnchor = curAnchor
-		prevSavedAnchor = savedAnchor
-	}
-
-	// Process final chunk
-	processChunk(prevCurAnchor+1, len(current), prevSavedAnchor+1, len(savedLines))
-
-	// Consolidate adjacent deletion points
-	// If we have deletion at line N and line N+1, merge them into just line N
-	if len(result.DeletionPoints) > 1 {
-		consolidated := []int{result.DeletionPoints[0]}
-		for i := 1; i < len(result.DeletionPoints); i++ {
-			prev := consolidated[len(consolidated)-1]
-			curr := result.DeletionPoints[i]
-			// If they're adjacent (curr == prev + 1), they're part of the same deletion region
-			// Keep only the first one (the one "above")
-			if curr != prev+1 {
-				consolidated = append(consolidated, curr)
-			}
-		}
-		result.DeletionPoints = consolidated
-	}
-
-	return result
-}
-
-// computeLCS returns the longest common subsequence of two string slices.
-func computeLCS(a, b []string) []string {
-	m, n := len(a), len(b)
-	if m == 0 || n == 0 {
-		return nil
-	}
-
-	// Build LCS length table
-	dp := make([][]int, m+1)
-	for i := range dp {
-		dp[i] = make([]int, n+1)
-	}
-
-	for i := 1; i <= m; i++ {
-		for j := 1; j <= n; j++ {
-			if a[i-1] == b[j-1] {
-				dp[i][j] = dp[i-1][j-1] + 1
-			} else {
-				if dp[i-1][j] > dp[i][j-1] {
-					dp[i][j] = dp[i-1][j]
-				} else {
-					dp[i][j] = dp[i][j-1]
-				}
-			}
-		}
-	}
-
-	// Backtrack to find LCS
-	lcsLen := dp[m][n]
-	lcs := make([]string, lcsLen)
-	i, j := m, n
-	for i > 0 && j > 0 {
-		if a[i-1] == b[j-1] {
-			lcs[lcsLen-1] = a[i-1]
-			lcsLen--
-			i--
-			j--
-		} else if dp[i-1][j] > dp[i][j-1] {
-			i--
-		} else {
-			j--
-		}
-	}
-
-	return lcs
-}
-
-// GetRegionDiff returns a git-style diff for a region of the current buffer compared to saved.
-// startRow and endRow define the region in the current buffer (inclusive).
-// Returns lines prefixed with "-" (deleted), "+" (added), or " " (context).
-func (buffer *Buffer) GetRegionDiff(saved *Buffer, startRow, endRow int) []string {
-	buffer.mutex.Lock()
-	defer buffer.mutex.Unlock()
-	saved.mutex.Lock()
-	defer saved.mutex.Unlock()
-
-	// Get current lines in region
-	current := make([]string, 0)
-	for i := startRow; i <= endRow && i < len(buffer.lines); i++ {
-		current = append(current, buffer.lines[i].ToString())
-	}
-
-	savedLines := make([]string, len(saved.lines))
-	for i, line := range saved.lines {
-		savedLines[i] = line.ToString()
-	}
-
-	// Compute full LCS to find anchor points
-	allCurrent := make([]string, len(buffer.lines))
-	for i, line := range buffer.lines {
-		allCurrent[i] = line.ToString()
-	}
-	lcs := computeLCS(allCurrent, savedLines)
-
-	// Find LCS positions
-	currentLCSPos := make([]int, len(lcs))
-	savedLCSPos := make([]int, len(lcs))
-
-	lcsIdx := 0
-	for i := 0; i < len(allCurrent) && lcsIdx < len(lcs); i++ {
-		if allCurrent[i] == lcs[lcsIdx] {
-			currentLCSPos[lcsIdx] = i
-			lcsIdx++
-		}
-	}
-
-	lcsIdx = 0
-	for i := 0; i < len(savedLines) && lcsIdx < len(lcs); i++ {
-		if savedLines[i] == lcs[lcsIdx] {
-			savedLCSPos[lcsIdx] = i
-			lcsIdx++
-		}
-	}
-
-	// Find the saved region that corresponds to our current region
-	// Look for LCS anchors just before startRow and just after endRow
-	savedStart := 0
-	savedEnd := len(savedLines) - 1
-
-	for i := 0; i < len(lcs); i++ {
-		if currentLCSPos[i] < startRow {
-			savedStart = savedLCSPos[i] + 1
-		}
-		if currentLCSPos[i] > endRow {
-			savedEnd = savedLCSPos[i] - 1
-			break
-		}
-	}
-
-	if savedStart > savedEnd {
-		savedEnd = savedStart - 1 // empty range
-	}
-
-	// Get saved lines in corresponding region
-	savedRegion := make([]string, 0)
-	for i := savedStart; i <= savedEnd && i < len(savedLines); i++ {
-		savedRegion = append(savedRegion, savedLines[i])
-	}
-
-	// Compute diff between current region and saved region
-	regionLCS := computeLCS(current, savedRegion)
-
-	// Build diff output
-	result := make([]string, 0)
-
-	curIdx := 0
-	savedIdx := 0
-	lcsIdx = 0
-
-	for curIdx < len(current) || savedIdx < len(savedRegion) {
-		// Check if current line is in LCS
-		curInLCS := false
-		if curIdx < len(current) && lcsIdx < len(regionLCS) && current[curIdx] == regionLCS[lcsIdx] {
-			curInLCS = true
-		}
-
-		// Check if saved line is in LCS
-		savedInLCS := false
-		if savedIdx < len(savedRegion) && lcsIdx < len(regionLCS) && savedRegion[savedIdx] == regionLCS[lcsIdx] {
-			savedInLCS = true
-		}
-
-		if curInLCS && savedInLCS {
-			// Both match LCS - context line
-			result = append(result, " "+current[curIdx])
-			curIdx++
-			savedIdx++
-			lcsIdx++
-		} else if !savedInLCS && savedIdx < len(savedRegion) {
-			// Saved line not in LCS - deleted
-			result = append(result, "-"+savedRegion[savedIdx])
-			savedIdx++
-		} else if !curInLCS && curIdx < len(current) {
-			// Current line not in LCS - added
-			result = append(result, "+"+current[curIdx])
-			curIdx++
-		} else {
-			// Edge case - advance whichever is behind
-			if savedIdx < len(savedRegion) {
-				result = append(result, "-"+savedRegion[savedIdx])
-				savedIdx++
-			}
-			if curIdx < len(current) {
-				result = append(result, "+"+current[curIdx])
-				curIdx++
-			}
-		}
-	}
-
-	return result
-}
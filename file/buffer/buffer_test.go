@@ -468,3 +468,99 @@ func TestDiffLines(t *testing.T) {
 		t.Error("Expected line 1 to be marked as new/modified, got:", diff)
 	}
 }
+
+func TestRegion(t *testing.T) {
+	buf := buffer.MakeBuffer([]string{"hello", "there", "world"})
+
+	strs := buf.RegionText(0, 2, 2, 3)
+	if len(strs) != 3 || strs[0] != "llo" || strs[1] != "there" || strs[2] != "wor" {
+		t.Errorf("RegionText is wrong: %q", strs)
+	}
+	strs = buf.RegionText(1, 1, 1, 3)
+	if len(strs) != 1 || strs[0] != "he" {
+		t.Errorf("RegionText is wrong: %q", strs)
+	}
+
+	buf.DeleteRegion(0, 2, 2, 3)
+	if buf.ToString("\n") != "held" {
+		t.Errorf("DeleteRegion is wrong: %q", buf.ToString("\n"))
+	}
+
+	row, col := buf.InsertText(0, 2, []string{"llo", "there", "wor"})
+	if buf.ToString("\n") != "hello\nthere\nworld" {
+		t.Errorf("InsertText is wrong: %q", buf.ToString("\n"))
+	}
+	if row != 2 || col != 3 {
+		t.Errorf("InsertText returned wrong position: %d, %d", row, col)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	old := buffer.MakeBuffer([]string{"a", "b", "c", "d", "e"})
+	buf := buffer.MakeBuffer([]string{"a", "B", "c", "new", "d"})
+
+	hunks := buf.DiffHunks(&old)
+	if len(hunks) != 3 {
+		t.Fatalf("expected 3 hunks, got %+v", hunks)
+	}
+	// Modified line.
+	if h := hunks[0]; h.Start != 1 || h.Count != 1 || h.OldStart != 1 || len(h.OldLines) != 1 || h.OldLines[0] != "b" {
+		t.Errorf("wrong modification hunk: %+v", h)
+	}
+	// Added line.
+	if h := hunks[1]; h.Start != 3 || h.Count != 1 || len(h.OldLines) != 0 {
+		t.Errorf("wrong addition hunk: %+v", h)
+	}
+	// Deleted line at the end.
+	if h := hunks[2]; h.Start != 5 || h.Count != 0 || h.OldStart != 4 || len(h.OldLines) != 1 || h.OldLines[0] != "e" {
+		t.Errorf("wrong deletion hunk: %+v", h)
+	}
+	if !hunks[2].Contains(4) || hunks[2].Contains(3) {
+		t.Errorf("deletion hunk should belong to the line before: %+v", hunks[2])
+	}
+
+	if hunks := old.DiffHunks(&old); len(hunks) != 0 {
+		t.Errorf("expected no hunks, got %+v", hunks)
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	buff := buffer.MakeBuffer([]string{"a", "b", "c", "d"})
+	version := buff.Version()
+	if row, _ := buff.ChangesSince(version); row != -1 {
+		t.Error("expected no changes, got row", row)
+	}
+
+	buff.SetRow(2, buffer.MakeLine("C"))
+	buff.InsertAfter(0, buffer.MakeLine("x"))
+	row, newVersion := buff.ChangesSince(version)
+	if row != 1 || newVersion != version+2 {
+		t.Error("expected row 1 and two changes, got", row, newVersion)
+	}
+	if row, _ := buff.ChangesSince(newVersion); row != -1 {
+		t.Error("expected no changes, got row", row)
+	}
+
+	buff.DeleteNewlines(map[int][]int{3: {0}})
+	if row, _ := buff.ChangesSince(newVersion); row != 2 {
+		t.Error("joining lines should change the previous line, got row", row)
+	}
+}
+
+// BenchmarkEdit edits a million-line buffer, taking a copy (as the undo
+// history does) after each edit.
+func BenchmarkEdit(b *testing.B) {
+	strs := make([]string, 1000000)
+	for k := range strs {
+		strs[k] = "some text"
+	}
+	buff := buffer.MakeBuffer(strs)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		row := (i * 7919) % buff.Length()
+		buff.InsertAfter(row, buffer.MakeLine("new line"))
+		buff.SetRow(row, buffer.MakeLine("changed"))
+		buff.DeleteRow(row + 1)
+		buff.Dup()
+	}
+}
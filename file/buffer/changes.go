@@ -0,0 +1,88 @@
+package buffer
+
+// maxChanges is the number of changes a buffer remembers. Asking about
+// older versions gives the conservative answer (row 0).
+const maxChanges = 1024
+
+// changeLog records where each change to a buffer happened, so that
+// things computed from the buffer (e.g. syntax highlighting states, or the
+// cursors of other views) can be brought up to date incrementally.
+type changeLog struct {
+	version int
+	changes []Shift
+}
+
+// Shift is a change to a buffer: the first row it touched, and the number
+// of lines it added (or removed, if negative) there.
+type Shift struct {
+	Row   int
+	Delta int
+}
+
+// Apply moves a row past the change. Rows from the change on move by the
+// number of lines added; rows which were deleted end up on the change.
+func (shift Shift) Apply(row int) int {
+	if row < shift.Row {
+		return row
+	}
+	return max(row+shift.Delta, shift.Row)
+}
+
+// changed records a change at (or after) row, which added delta lines. The
+// caller must hold the lock.
+func (buffer *Buffer) changed(row, delta int) {
+	if buffer.log == nil {
+		return
+	}
+	log := buffer.log
+	log.version++
+	log.changes = append(log.changes, Shift{row, delta})
+	if len(log.changes) > maxChanges {
+		log.changes = log.changes[len(log.changes)-maxChanges:]
+	}
+}
+
+// Version returns a number which goes up every time the buffer changes.
+func (buffer *Buffer) Version() int {
+	if buffer.mutex == nil || buffer.log == nil {
+		return 0
+	}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.log.version
+}
+
+// ChangesSince returns the first row which has changed since a version of
+// the buffer (or -1 if nothing has), along with the current version.
+func (buffer *Buffer) ChangesSince(version int) (int, int) {
+	shifts, current, ok := buffer.ShiftsSince(version)
+	if !ok {
+		return 0, current
+	}
+	first := -1
+	for _, shift := range shifts {
+		if first < 0 || shift.Row < first {
+			first = shift.Row
+		}
+	}
+	return first, current
+}
+
+// ShiftsSince returns the changes since a version of the buffer, oldest
+// first, along with the current version. It returns false if the version
+// is too old (or new) to know.
+func (buffer *Buffer) ShiftsSince(version int) ([]Shift, int, bool) {
+	if buffer.mutex == nil || buffer.log == nil {
+		return nil, 0, true
+	}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	log := buffer.log
+	n := log.version - version
+	if n < 0 || n > len(log.changes) {
+		return nil, log.version, false
+	}
+	shifts := make([]Shift, n)
+	copy(shifts, log.changes[len(log.changes)-n:])
+	return shifts, log.version, true
+}
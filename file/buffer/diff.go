@@ -0,0 +1,276 @@
+package buffer
+
+// LineStatus represents the diff status of a line.
+type LineStatus int
+
+const (
+	LineUnchanged LineStatus = iota
+	LineModified
+	LineAdded
+)
+
+// DiffResult contains the full diff information including deletions.
+type DiffResult struct {
+	// Changes maps line numbers to their status (modified or added)
+	Changes map[int]LineStatus
+	// DeletionPoints contains line numbers *after which* deletions occurred.
+	// A value of -1 means deletions at the very beginning of the file.
+	DeletionPoints []int
+}
+
+// match pairs up a line of a buffer with the same line in an older version
+// of the buffer.
+type match struct {
+	row, oldRow int
+}
+
+// Diff is the difference between a buffer and an older version of it (such
+// as the saved version). It records the lines the two have in common, from
+// which the changes can be worked out in various forms.
+type Diff struct {
+	current []string
+	old     []string
+	matches []match
+}
+
+// Diff computes the difference from an older version of the buffer to this
+// one, using Myers' algorithm. It takes O((N+M)D) time, where D is the
+// number of lines added or removed, so small changes to large buffers are
+// cheap.
+func (buffer *Buffer) Diff(old *Buffer) *Diff {
+	buffer.mutex.Lock()
+	current := buffer.strings()
+	buffer.mutex.Unlock()
+	old.mutex.Lock()
+	oldLines := old.strings()
+	old.mutex.Unlock()
+	return &Diff{
+		current: current,
+		old:     oldLines,
+		matches: diffMatches(current, oldLines),
+	}
+}
+
+// DiffLines computes the diff between this buffer and another buffer (typically the saved version).
+// Returns a map from line number (in this buffer) to its status.
+func (buffer *Buffer) DiffLines(saved *Buffer) map[int]LineStatus {
+	return buffer.Diff(saved).Result().Changes
+}
+
+// DiffLinesFull computes the diff including deletion points.
+func (buffer *Buffer) DiffLinesFull(saved *Buffer) DiffResult {
+	return buffer.Diff(saved).Result()
+}
+
+// GetRegionDiff returns a git-style diff for a region of the current buffer compared to saved.
+// startRow and endRow define the region in the current buffer (inclusive).
+// Returns lines prefixed with "-" (deleted), "+" (added), or " " (context).
+func (buffer *Buffer) GetRegionDiff(saved *Buffer, startRow, endRow int) []string {
+	return buffer.Diff(saved).Region(startRow, endRow)
+}
+
+// Result returns the status of each changed line, and the places where
+// lines were deleted.
+func (diff *Diff) Result() DiffResult {
+	result := DiffResult{
+		Changes:        make(map[int]LineStatus),
+		DeletionPoints: []int{},
+	}
+
+	processChunk := func(curStart, curEnd, savedStart, savedEnd int) {
+		curCount := curEnd - curStart
+		savedCount := savedEnd - savedStart
+
+		// Lines which replace deleted lines are modified; any more are added.
+		for i := 0; i < curCount; i++ {
+			if i < savedCount {
+				result.Changes[curStart+i] = LineModified
+			} else {
+				result.Changes[curStart+i] = LineAdded
+			}
+		}
+
+		// If there are more deleted lines than added/modified, record deletion point
+		if savedCount > curCount {
+			// Deletions occurred after line (curStart - 1) in current buffer
+			// If curStart is 0, deletions are at the very beginning (-1)
+			result.DeletionPoints = append(result.DeletionPoints, curStart-1)
+		}
+	}
+
+	// Walk through the common lines and process the chunks between them.
+	row, oldRow := 0, 0
+	for _, m := range diff.matches {
+		processChunk(row, m.row, oldRow, m.oldRow)
+		row, oldRow = m.row+1, m.oldRow+1
+	}
+	processChunk(row, len(diff.current), oldRow, len(diff.old))
+
+	// Consolidate adjacent deletion points
+	// If we have deletion at line N and line N+1, merge them into just line N
+	if len(result.DeletionPoints) > 1 {
+		consolidated := []int{result.DeletionPoints[0]}
+		for i := 1; i < len(result.DeletionPoints); i++ {
+			prev := consolidated[len(consolidated)-1]
+			curr := result.DeletionPoints[i]
+			if curr != prev+1 {
+				consolidated = append(consolidated, curr)
+			}
+		}
+		result.DeletionPoints = consolidated
+	}
+
+	return result
+}
+
+// Region returns a git-style diff for the rows from startRow to endRow
+// (inclusive) of the buffer. Lines are prefixed with "-" (deleted), "+"
+// (added), or " " (context).
+func (diff *Diff) Region(startRow, endRow int) []string {
+	if endRow >= len(diff.current) {
+		endRow = len(diff.current) - 1
+	}
+	result := []string{}
+	emit := func(prefix string, lines []string) {
+		for _, line := range lines {
+			result = append(result, prefix+line)
+		}
+	}
+
+	// Each run of changed rows goes with the old lines between the same
+	// pair of common lines. Runs which start before the region are only
+	// partly in it, so their old lines are left out.
+	chunk := func(row, end, oldRow, oldEnd int) {
+		if row >= startRow && row <= endRow {
+			emit("-", diff.old[oldRow:oldEnd])
+		}
+		emit("+", diff.current[max(row, startRow):min(end, endRow+1)])
+	}
+	row, oldRow := 0, 0
+	for _, m := range diff.matches {
+		if m.row > endRow {
+			chunk(row, m.row, oldRow, m.oldRow)
+			return result
+		}
+		if m.row >= startRow {
+			chunk(row, m.row, oldRow, m.oldRow)
+			emit(" ", diff.current[m.row:m.row+1])
+		}
+		row, oldRow = m.row+1, m.oldRow+1
+	}
+	chunk(row, len(diff.current), oldRow, len(diff.old))
+	return result
+}
+
+// diffMatches returns the longest sequence of lines which a and b have in
+// common.
+func diffMatches(a, b []string) []match {
+	// Number the distinct lines, so that comparisons are cheap.
+	ids := map[string]int{}
+	number := func(lines []string) []int {
+		nums := make([]int, len(lines))
+		for k, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			nums[k] = id
+		}
+		return nums
+	}
+	d := differ{a: number(a), b: number(b)}
+	d.compare(0, len(a), 0, len(b))
+	return d.matches
+}
+
+// differ finds the common lines of two sequences with the linear-space
+// version of Myers' diff algorithm.
+type differ struct {
+	a, b    []int
+	matches []match
+}
+
+// compare finds the common lines of a[aLo:aHi] and b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.matches = append(d.matches, match{aLo, bLo})
+		aLo++
+		bLo++
+	}
+	aEnd := aHi
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	// With the common ends trimmed off, one side being empty means the
+	// rest was purely added or removed. Otherwise split the problem at
+	// the middle snake.
+	if aLo < aHi && bLo < bHi {
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.matches = append(d.matches, match{x, y})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for k := aHi; k < aEnd; k++ {
+		d.matches = append(d.matches, match{k, bHi + k - aHi})
+	}
+}
+
+// middleSnake finds the middle snake of an optimal edit path from
+// (aLo, bLo) to (aHi, bHi), by searching from both ends at once. It
+// returns the start and end of the snake.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+
+	// forward[k] is the furthest x reached on diagonal k (x - y = k) from
+	// the start, and backward[k] is the same from the end, with both
+	// sequences reversed.
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for dist := 0; dist <= limit; dist++ {
+		for k := -dist; k <= dist; k += 2 {
+			x := forward[offset+k-1] + 1
+			if k == -dist || (k != dist && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if odd && delta-k >= -(dist-1) && delta-k <= dist-1 && x+backward[offset+delta-k] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y
+			}
+		}
+		for k := -dist; k <= dist; k += 2 {
+			x := backward[offset+k-1] + 1
+			if k == -dist || (k != dist && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if !odd && delta-k >= -dist && delta-k <= dist && x+forward[offset+delta-k] >= n {
+				return aHi - x, bHi - y, aHi - x0, bHi - y0
+			}
+		}
+	}
+
+	// Not reached: the searches always meet by the limit.
+	return aLo, bLo, aLo, bLo
+}
@@ -0,0 +1,65 @@
+package buffer
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// lcsLength is the length of the longest common subsequence, by dynamic
+// programming.
+func lcsLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+func TestDiffMatches(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for k := range lines {
+			lines[k] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+	for k := 0; k < 2000; k++ {
+		a, b := random(), random()
+		matches := diffMatches(a, b)
+		if len(matches) != lcsLength(a, b) {
+			t.Fatal("not a longest common subsequence:", a, b, matches)
+		}
+		prev := match{-1, -1}
+		for _, m := range matches {
+			if m.row <= prev.row || m.oldRow <= prev.oldRow || a[m.row] != b[m.oldRow] {
+				t.Fatal("bad match:", a, b, matches)
+			}
+			prev = m
+		}
+	}
+}
+
+func TestRegionDiff(t *testing.T) {
+	old := MakeBuffer([]string{"a", "b", "c", "d", "e"})
+	buf := MakeBuffer([]string{"a", "B", "c", "e", "f"})
+	diff := buf.Diff(&old)
+	if region := diff.Region(1, 1); !strSliceEq(region, "-b", "+B") {
+		t.Errorf("wrong region diff: %q", region)
+	}
+	if region := diff.Region(2, 3); !strSliceEq(region, " c", "-d", " e") {
+		t.Errorf("wrong region diff: %q", region)
+	}
+	if region := diff.Region(4, 4); !strSliceEq(region, "+f") {
+		t.Errorf("wrong region diff: %q", region)
+	}
+}
@@ -0,0 +1,69 @@
+package buffer
+
+// Hunk is a contiguous change between an old version of a buffer and this
+// one: the Count lines starting at Start (in this buffer) replace OldLines,
+// which started at OldStart (in the old version). Pure deletions have a
+// Count of zero, and pure additions have no OldLines.
+type Hunk struct {
+	Start    int
+	Count    int
+	OldStart int
+	OldLines []string
+}
+
+// Contains returns true if a row is part of the hunk. A deletion is
+// considered part of the line on which it occurred.
+func (hunk Hunk) Contains(row int) bool {
+	if hunk.Count == 0 {
+		return row == hunk.Start || row == hunk.Start-1
+	}
+	return row >= hunk.Start && row < hunk.Start+hunk.Count
+}
+
+// DiffHunks returns the changes from an old version of the buffer (such as
+// the saved version) to this one.
+func (buffer *Buffer) DiffHunks(old *Buffer) []Hunk {
+	return buffer.Diff(old).Hunks()
+}
+
+// Hunks returns the changes as a list of hunks.
+func (diff *Diff) Hunks() []Hunk {
+	hunks := []Hunk{}
+	addHunk := func(start, end, oldStart, oldEnd int) {
+		if start == end && oldStart == oldEnd {
+			return
+		}
+		hunks = append(hunks, Hunk{
+			Start:    start,
+			Count:    end - start,
+			OldStart: oldStart,
+			OldLines: append([]string{}, diff.old[oldStart:oldEnd]...),
+		})
+	}
+
+	// Walk both buffers, between the common lines.
+	i, j := 0, 0
+	for _, m := range diff.matches {
+		addHunk(i, m.row, j, m.oldRow)
+		i, j = m.row+1, m.oldRow+1
+	}
+	addHunk(i, len(diff.current), j, len(diff.old))
+	return hunks
+}
+
+// Strings returns the buffer's lines as strings.
+func (buffer *Buffer) Strings() []string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.strings()
+}
+
+// strings returns the buffer's lines as strings. The caller must hold the
+// lock.
+func (buffer *Buffer) strings() []string {
+	strs := make([]string, 0, buffer.text.lines.Len())
+	buffer.text.lines.Each(0, buffer.text.lines.Len(), func(row int, line Line) {
+		strs = append(strs, line.ToString())
+	})
+	return strs
+}
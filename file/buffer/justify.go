@@ -155,7 +155,7 @@ func MakeSplitBuffer(bigString string, lineLen int) Buffer {
 	}
 	lines = append(lines, MakeLine(lineStr))
 	return Buffer{
-		lines: lines,
+		text:  &text{lines: makeRope(lines)},
 		mutex: &sync.Mutex{},
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Line struct {
@@ -109,9 +110,45 @@ func (line Line) SearchAll(term string, start, end int) []int {
 	return matches
 }
 
-func isRegex(term string) bool {
-	n := len(term)
-	return (term[0:1] == "/" && term[n-1:n] == "/" && len(term) > 1)
+// parseRegex checks for a regex search term of the form /pattern/ or
+// /pattern/flags, where flags is any combination of i, m, s and U. It
+// returns nil if the term is not a regex, or fails to compile (and so is
+// searched for as plain text). Paths such as /usr/local/bin stay plain
+// text, since "bin" is not a set of flags.
+func parseRegex(term string) *regexp.Regexp {
+	if len(term) < 3 || term[0] != '/' {
+		return nil
+	}
+	end := strings.LastIndex(term, "/")
+	if end <= 1 {
+		return nil
+	}
+	pattern := term[1:end]
+	if flags := term[end+1:]; flags != "" {
+		if strings.Trim(flags, "imsU") != "" {
+			return nil
+		}
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// isSmartCaseSensitive returns true if a plain-text search should be
+// case-sensitive, i.e. if the term contains uppercase characters.
+func isSmartCaseSensitive(term string) bool {
+	return strings.ToLower(term) != term
+}
+
+// runeIndex converts a byte index within a string to a rune index.
+func runeIndex(s string, idx int) int {
+	if idx < 0 {
+		return idx
+	}
+	return utf8.RuneCountInString(s[:idx])
 }
 
 func (line Line) search(term string, start, end int) (int, int) {
@@ -124,39 +161,38 @@ func (line Line) search(term string, start, end int) (int, int) {
 		end -= 1
 	}
 
-	n := len(term)
 	var startCol, endCol int
 	if end == line.Length() {
 		end--
 	}
 	target := string(line.chars[start : end+1])
 
-	if isRegex(term) {
-		re, err := regexp.Compile(term[1 : n-1])
-		if err != nil {
-			return -1, -1
-		}
+	if re := parseRegex(term); re != nil {
 		var cols []int
 		if forward {
 			cols = re.FindStringIndex(target)
 		} else {
 			colses := re.FindAllStringIndex(target, -1)
-			cols = colses[len(colses)-1]
+			if len(colses) > 0 {
+				cols = colses[len(colses)-1]
+			}
 		}
 		if cols == nil {
 			return -1, -1
 		}
-		startCol = cols[0]
-		endCol = cols[1]
+		startCol = runeIndex(target, cols[0])
+		endCol = runeIndex(target, cols[1])
 	} else {
-		strLine := strings.ToLower(target)
-		term = strings.ToLower(term)
+		strLine := target
+		if !isSmartCaseSensitive(term) {
+			strLine = strings.ToLower(target)
+		}
 		if forward {
-			startCol = strings.Index(strLine, term)
+			startCol = runeIndex(strLine, strings.Index(strLine, term))
 		} else {
-			startCol = strings.LastIndex(strLine, term)
+			startCol = runeIndex(strLine, strings.LastIndex(strLine, term))
 		}
-		endCol = startCol + len(term)
+		endCol = startCol + utf8.RuneCountInString(term)
 	}
 	// Ignore zero-length metches.
 	if startCol == endCol {
@@ -168,6 +204,74 @@ func (line Line) search(term string, start, end int) (int, int) {
 	return startCol + start, endCol + start
 }
 
+// Replace replaces the first match of term, at or after column start, with
+// replaceTerm. For regex terms, $1 and ${name} in replaceTerm expand to the
+// captured groups. It returns the new line and the replacement text.
+func (line Line) Replace(term, replaceTerm string, start int) (Line, string) {
+	startCol, endCol := line.Search(term, start, -1)
+	if startCol < 0 {
+		return line, ""
+	}
+	if re := parseRegex(term); re != nil {
+		// Re-run the match that Search found, to get the submatches.
+		target := string(line.chars[start:])
+		match := re.FindStringSubmatchIndex(target)
+		if match != nil {
+			replaceTerm = string(re.ExpandString(nil, replaceTerm, target, match))
+			startCol = start + runeIndex(target, match[0])
+			endCol = start + runeIndex(target, match[1])
+		}
+	}
+	newLine := MakeLine(string(line.chars[:startCol]) + replaceTerm + string(line.chars[endCol:]))
+	return newLine, replaceTerm
+}
+
+// ReplaceAll replaces every (non-empty) match of term with replaceTerm,
+// expanding capture groups for regex terms. It returns the new line and
+// the number of replacements.
+func (line Line) ReplaceAll(term, replaceTerm string) (Line, int) {
+	re, err := Pattern(term)
+	if err != nil {
+		return line, 0
+	}
+	isRegex := parseRegex(term) != nil
+	str := line.ToString()
+	result := []byte{}
+	last, count := 0, 0
+	for _, match := range re.FindAllStringSubmatchIndex(str, -1) {
+		if match[0] == match[1] {
+			continue
+		}
+		result = append(result, str[last:match[0]]...)
+		if isRegex {
+			result = re.ExpandString(result, replaceTerm, str, match)
+		} else {
+			result = append(result, replaceTerm...)
+		}
+		last = match[1]
+		count++
+	}
+	if count == 0 {
+		return line, 0
+	}
+	result = append(result, str[last:]...)
+	return MakeLine(string(result)), count
+}
+
+// Pattern returns the regex used to search for term. A /pattern/flags term
+// is compiled as a regex; any other term (or an invalid regex) is matched
+// literally, ignoring case unless the term contains capitals.
+func Pattern(term string) (*regexp.Regexp, error) {
+	if re := parseRegex(term); re != nil {
+		return re, nil
+	}
+	pattern := regexp.QuoteMeta(term)
+	if !isSmartCaseSensitive(term) {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
 func (line Line) RemoveTrailingWhitespace() Line {
 	re := regexp.MustCompile("[\t ]*$")
 	str := re.ReplaceAllString(string(line.chars), "")
@@ -226,6 +330,20 @@ func (line Line) TabCursorPos(col int, tabwidth int) int {
 	return len(strLine)
 }
 
+// TabRunePos is like TabCursorPos, but counts runes rather than bytes: it
+// returns the position of a column in the line with tabs expanded.
+func (line Line) TabRunePos(col int, tabwidth int) int {
+	pos := 0
+	for _, r := range line.chars[:col] {
+		if r == '\t' {
+			pos += tabwidth
+		} else {
+			pos++
+		}
+	}
+	return pos
+}
+
 func (line Line) StrSlice(startCol, endCol int, tabwidth int) string {
 	pline := line.Tabs2spaces(tabwidth)
 	return pline.Slice(startCol, endCol).ToString()
@@ -487,3 +605,41 @@ func (line *Line) BracketMatch(start, end rune, idx, dir, count int) (int, int)
 func (line *Line) RegexMatch(pattern string) (bool, error) {
 	return regexp.MatchString(pattern, line.ToString())
 }
+
+// Wrap splits the line (with tabs expanded) into rows of at most width
+// columns, breaking after whitespace where possible. It returns the start of
+// each row, as a position in the expanded line, and the indent for the
+// continuation rows, which matches the line's own indentation (unless that
+// would leave too little room).
+func (line Line) Wrap(width, tabwidth int) ([]int, int) {
+	chars := line.Tabs2spaces(tabwidth).chars
+	starts := []int{0}
+	if width <= 0 {
+		return starts, 0
+	}
+
+	indent := 0
+	for indent < len(chars) && chars[indent] == ' ' {
+		indent++
+	}
+	if indent > width/2 {
+		indent = 0
+	}
+
+	pos := 0
+	avail := width
+	for len(chars)-pos > avail {
+		end := pos + avail
+		next := end
+		for k := end; k > pos; k-- {
+			if k-1 >= indent && unicode.IsSpace(chars[k-1]) {
+				next = k
+				break
+			}
+		}
+		starts = append(starts, next)
+		pos = next
+		avail = width - indent
+	}
+	return starts, indent
+}
@@ -1,6 +1,7 @@
 package buffer_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/wx13/sith/file/buffer"
@@ -64,6 +65,70 @@ func TestSearch(t *testing.T) {
 		t.Error("search:", line.ToString(), "/.o/", a, b)
 	}
 
+	a, b = line.Search("/(?i)WOR/", 0, -1)
+	if a != 6 || b != 9 {
+		t.Error("search:", line.ToString(), "/(?i)WOR/", a, b)
+	}
+
+	a, b = line.Search("/WOR/i", 0, -1)
+	if a != 6 || b != 9 {
+		t.Error("search:", line.ToString(), "/WOR/i", a, b)
+	}
+
+}
+
+func TestSearchPath(t *testing.T) {
+	// Paths are plain text, unless what follows the last slash is a set
+	// of flags.
+	line := buffer.MakeLine("cd /usr/local/bin; ls /tmp/x")
+	for _, term := range []string{"/usr/local/bin", "/tmp/x", "/usr"} {
+		a, b := line.Search(term, 0, -1)
+		if str := line.ToString(); a < 0 || str[a:b] != term {
+			t.Errorf("search %q: got %d, %d", term, a, b)
+		}
+	}
+
+	// An invalid regex is searched for as plain text.
+	line = buffer.MakeLine("a /(/ b")
+	if a, b := line.Search("/(/", 0, -1); a != 2 || b != 5 {
+		t.Error("invalid regex should match literally:", a, b)
+	}
+}
+
+func TestSmartCase(t *testing.T) {
+	line := buffer.MakeLine("Hello world")
+	a, _ := line.Search("hello", 0, -1)
+	if a != 0 {
+		t.Error("smart case: lower-case term should match any case", a)
+	}
+	a, _ = line.Search("World", 0, -1)
+	if a != -1 {
+		t.Error("smart case: mixed-case term should match exactly", a)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	line := buffer.MakeLine("x: key=value")
+	newLine, repl := line.Replace(`/(\w+)=(\w+)/`, "$2=$1", 0)
+	if newLine.ToString() != "x: value=key" || repl != "value=key" {
+		t.Error("replace:", newLine.ToString(), repl)
+	}
+	newLine, _ = line.Replace(`/(?P<k>\w+)=/`, "${k}:", 3)
+	if newLine.ToString() != "x: key:value" {
+		t.Error("replace:", newLine.ToString())
+	}
+	newLine, repl = line.Replace(`/(KEY)=/i`, "$1:", 0)
+	if newLine.ToString() != "x: key:value" || repl != "key:" {
+		t.Error("replace:", newLine.ToString(), repl)
+	}
+	newLine, repl = line.Replace("key", "K", 0)
+	if newLine.ToString() != "x: K=value" || repl != "K" {
+		t.Error("replace:", newLine.ToString(), repl)
+	}
+	newLine, _ = line.Replace("nope", "K", 0)
+	if newLine.ToString() != line.ToString() {
+		t.Error("replace:", newLine.ToString())
+	}
 }
 
 func TestRemoveTrailingWhitespace(t *testing.T) {
@@ -442,3 +507,52 @@ func TestWordBounds(t *testing.T) {
 		t.Error("WordBounds 0:", start, end)
 	}
 }
+
+func TestReplaceAll(t *testing.T) {
+	line := buffer.MakeLine("Foo foo FOO")
+	newLine, n := line.ReplaceAll("foo", "bar")
+	if newLine.ToString() != "bar bar bar" || n != 3 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+	newLine, n = line.ReplaceAll("Foo", "bar")
+	if newLine.ToString() != "bar foo FOO" || n != 1 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+	newLine, n = line.ReplaceAll(`/(\w)(\w+)/`, "$2$1")
+	if newLine.ToString() != "ooF oof OOF" || n != 3 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+	newLine, n = line.ReplaceAll(`/f(\w+)/i`, "<$1>")
+	if newLine.ToString() != "<oo> <oo> <OO>" || n != 3 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+	newLine, n = line.ReplaceAll("/foo/i", "bar")
+	if newLine.ToString() != "bar bar bar" || n != 3 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+	newLine, n = line.ReplaceAll("/foo/", "bar")
+	if newLine.ToString() != "Foo bar FOO" || n != 1 {
+		t.Error("replace all:", newLine.ToString(), n)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	check := func(str string, width int, expStarts []int, expIndent int) {
+		starts, indent := buffer.MakeLine(str).Wrap(width, 4)
+		if fmt.Sprint(starts) != fmt.Sprint(expStarts) || indent != expIndent {
+			t.Errorf("Wrap(%q, %d): expected %v/%d, got %v/%d",
+				str, width, expStarts, expIndent, starts, indent)
+		}
+	}
+	check("short", 10, []int{0}, 0)
+	check("", 10, []int{0}, 0)
+	// Breaks after spaces.
+	check("hello there world", 12, []int{0, 12}, 0)
+	check("hello there world", 8, []int{0, 6, 12}, 0)
+	// Long words are broken.
+	check("abcdefghij", 4, []int{0, 4, 8}, 0)
+	// Continuation rows are indented, and get less room.
+	check("  one two three", 8, []int{0, 6, 10}, 2)
+	// Tabs are expanded.
+	check("\tone two", 8, []int{0, 8}, 4)
+}
@@ -0,0 +1,76 @@
+package buffer
+
+// clampPos forces a row, col position to lie within the buffer.
+func (buffer *Buffer) clampPos(row, col int) (int, int) {
+	if row < 0 {
+		return 0, 0
+	}
+	if row >= buffer.Length() {
+		row = buffer.Length() - 1
+		return row, buffer.RowLength(row)
+	}
+	if col < 0 {
+		col = 0
+	}
+	if col > buffer.RowLength(row) {
+		col = buffer.RowLength(row)
+	}
+	return row, col
+}
+
+// RegionText returns the text from (row1, col1) up to (but not including)
+// (row2, col2), split into lines.
+func (buffer *Buffer) RegionText(row1, col1, row2, col2 int) []string {
+	row1, col1 = buffer.clampPos(row1, col1)
+	row2, col2 = buffer.clampPos(row2, col2)
+	if row1 == row2 {
+		if col2 < col1 {
+			return []string{""}
+		}
+		return []string{string(buffer.GetRowDirect(row1).chars[col1:col2])}
+	}
+	strs := []string{string(buffer.GetRowDirect(row1).chars[col1:])}
+	for row := row1 + 1; row < row2; row++ {
+		strs = append(strs, buffer.GetRowDirect(row).ToString())
+	}
+	strs = append(strs, string(buffer.GetRowDirect(row2).chars[:col2]))
+	return strs
+}
+
+// DeleteRegion removes the text from (row1, col1) up to (but not including)
+// (row2, col2).
+func (buffer *Buffer) DeleteRegion(row1, col1, row2, col2 int) {
+	row1, col1 = buffer.clampPos(row1, col1)
+	row2, col2 = buffer.clampPos(row2, col2)
+	if row2 < row1 || (row1 == row2 && col2 <= col1) {
+		return
+	}
+	start := string(buffer.GetRowDirect(row1).chars[:col1])
+	end := string(buffer.GetRowDirect(row2).chars[col2:])
+	buffer.ReplaceLines([]Line{MakeLine(start + end)}, row1, row2)
+}
+
+// InsertText inserts lines of text at a (row, col) position, splitting the
+// line as needed. It returns the position just after the inserted text.
+func (buffer *Buffer) InsertText(row, col int, strs []string) (int, int) {
+	if len(strs) == 0 {
+		return row, col
+	}
+	row, col = buffer.clampPos(row, col)
+	line := buffer.GetRowDirect(row)
+	start := string(line.chars[:col])
+	end := string(line.chars[col:])
+	n := len(strs)
+	if n == 1 {
+		buffer.SetRow(row, MakeLine(start+strs[0]+end))
+		return row, col + len([]rune(strs[0]))
+	}
+	lines := make([]Line, n)
+	lines[0] = MakeLine(start + strs[0])
+	for k := 1; k < n-1; k++ {
+		lines[k] = MakeLine(strs[k])
+	}
+	lines[n-1] = MakeLine(strs[n-1] + end)
+	buffer.ReplaceLines(lines, row, row)
+	return row + n - 1, len([]rune(strs[n-1]))
+}
@@ -0,0 +1,265 @@
+package buffer
+
+import "slices"
+
+// maxLeaf is the most lines a leaf of a rope holds.
+const maxLeaf = 64
+
+// text holds a buffer's lines. It is shared by copies of the Buffer value
+// (along with the lock).
+type text struct {
+	lines *rope
+}
+
+// rope is a persistent, balanced tree of lines. Edits never modify a rope,
+// but make a new one which shares all the unchanged parts with the old one.
+// So a copy of a rope is free, and edits take O(log n) time.
+//
+// A nil rope is empty. Leaves hold the lines, and every other node has
+// two children whose heights differ by at most one (an AVL tree).
+type rope struct {
+	lines       []Line
+	left, right *rope
+	size        int
+	height      int
+}
+
+// makeRope builds a balanced rope out of a slice of lines. The rope keeps
+// its own copy of the slice.
+func makeRope(lines []Line) *rope {
+	if len(lines) == 0 {
+		return nil
+	}
+	if len(lines) <= maxLeaf {
+		return makeLeaf(append([]Line{}, lines...))
+	}
+	mid := len(lines) / 2
+	return makeNode(makeRope(lines[:mid]), makeRope(lines[mid:]))
+}
+
+func makeLeaf(lines []Line) *rope {
+	return &rope{lines: lines, size: len(lines), height: 1}
+}
+
+func makeNode(left, right *rope) *rope {
+	height := left.height
+	if right.height > height {
+		height = right.height
+	}
+	return &rope{
+		left:   left,
+		right:  right,
+		size:   left.size + right.size,
+		height: height + 1,
+	}
+}
+
+func (r *rope) leaf() bool {
+	return r.left == nil
+}
+
+// Len returns the number of lines.
+func (r *rope) Len() int {
+	if r == nil {
+		return 0
+	}
+	return r.size
+}
+
+func (r *rope) depth() int {
+	if r == nil {
+		return 0
+	}
+	return r.height
+}
+
+// Get returns the line at a row, which must be in range.
+func (r *rope) Get(row int) Line {
+	for !r.leaf() {
+		if row < r.left.size {
+			r = r.left
+		} else {
+			row -= r.left.size
+			r = r.right
+		}
+	}
+	return r.lines[row]
+}
+
+// Set returns a rope with the line at a row (which must be in range)
+// replaced.
+func (r *rope) Set(row int, line Line) *rope {
+	if r.leaf() {
+		lines := append([]Line{}, r.lines...)
+		lines[row] = line
+		return makeLeaf(lines)
+	}
+	if row < r.left.size {
+		return makeNode(r.left.Set(row, line), r.right)
+	}
+	return makeNode(r.left, r.right.Set(row-r.left.size, line))
+}
+
+// Split returns the rows before row, and the rows from row on.
+func (r *rope) Split(row int) (*rope, *rope) {
+	if r == nil {
+		return nil, nil
+	}
+	if row <= 0 {
+		return nil, r
+	}
+	if row >= r.size {
+		return r, nil
+	}
+	if r.leaf() {
+		return makeLeaf(r.lines[:row:row]), makeLeaf(r.lines[row:])
+	}
+	if row < r.left.size {
+		left, right := r.left.Split(row)
+		return left, join(right, r.right)
+	}
+	left, right := r.right.Split(row - r.left.size)
+	return join(r.left, left), right
+}
+
+// Splice returns a rope with the rows from start up to (but not including)
+// end replaced by lines.
+func (r *rope) Splice(start, end int, lines ...Line) *rope {
+	before, rest := r.Split(start)
+	_, after := rest.Split(end - start)
+	return join(join(before, makeRope(lines)), after)
+}
+
+// Slice returns the lines from start up to (but not including) end.
+func (r *rope) Slice(start, end int) []Line {
+	lines := make([]Line, 0, end-start)
+	r.Each(start, end, func(row int, line Line) {
+		lines = append(lines, line)
+	})
+	return lines
+}
+
+// Each calls fn on each line from start up to (but not including) end.
+func (r *rope) Each(start, end int, fn func(row int, line Line)) {
+	r.each(0, start, end, fn)
+}
+
+func (r *rope) each(offset, start, end int, fn func(row int, line Line)) {
+	if r == nil || start >= offset+r.size || end <= offset {
+		return
+	}
+	if !r.leaf() {
+		r.left.each(offset, start, end, fn)
+		r.right.each(offset+r.left.size, start, end, fn)
+		return
+	}
+	for k, line := range r.lines {
+		if row := offset + k; row >= start && row < end {
+			fn(row, line)
+		}
+	}
+}
+
+// firstDiff returns the first row at which two ropes differ, or -1 if they
+// are the same. Subtrees which the ropes share at the same rows are skipped,
+// so comparing a rope with an edited copy of it is cheap up to the edit.
+func firstDiff(a, b *rope) int {
+	diff := -1
+	if a != b && a != nil {
+		diff = a.firstDiff(0, b)
+	}
+	if diff < 0 && a.Len() != b.Len() {
+		diff = min(a.Len(), b.Len())
+	}
+	return diff
+}
+
+func (r *rope) firstDiff(offset int, other *rope) int {
+	if other.node(offset, r.size) == r {
+		return -1
+	}
+	if !r.leaf() {
+		if diff := r.left.firstDiff(offset, other); diff >= 0 {
+			return diff
+		}
+		return r.right.firstDiff(offset+r.left.size, other)
+	}
+	for k, line := range r.lines {
+		row := offset + k
+		if row >= other.Len() || !slices.Equal(line.chars, other.Get(row).chars) {
+			return row
+		}
+	}
+	return -1
+}
+
+// node returns the subtree holding exactly the rows from offset up to
+// offset+size, if there is one.
+func (r *rope) node(offset, size int) *rope {
+	for r != nil {
+		if offset == 0 && r.size == size {
+			return r
+		}
+		if r.leaf() {
+			return nil
+		}
+		switch {
+		case offset+size <= r.left.size:
+			r = r.left
+		case offset >= r.left.size:
+			offset -= r.left.size
+			r = r.right
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// join concatenates two ropes, keeping the result balanced.
+func join(left, right *rope) *rope {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.leaf() && right.leaf() && left.size+right.size <= maxLeaf {
+		lines := make([]Line, 0, left.size+right.size)
+		return makeLeaf(append(append(lines, left.lines...), right.lines...))
+	}
+	if left.height > right.height+1 {
+		return rebalance(makeNode(left.left, join(left.right, right)))
+	}
+	if right.height > left.height+1 {
+		return rebalance(makeNode(join(left, right.left), right.right))
+	}
+	return makeNode(left, right)
+}
+
+// rebalance fixes up a node whose children's heights differ by two.
+func rebalance(r *rope) *rope {
+	switch {
+	case r.left.height > r.right.height+1:
+		left := r.left
+		if left.left.depth() < left.right.depth() {
+			left = rotateLeft(left)
+		}
+		return rotateRight(makeNode(left, r.right))
+	case r.right.height > r.left.height+1:
+		right := r.right
+		if right.right.depth() < right.left.depth() {
+			right = rotateRight(right)
+		}
+		return rotateLeft(makeNode(r.left, right))
+	}
+	return r
+}
+
+func rotateRight(r *rope) *rope {
+	return makeNode(r.left.left, makeNode(r.left.right, r.right))
+}
+
+func rotateLeft(r *rope) *rope {
+	return makeNode(makeNode(r.left, r.right.left), r.right.right)
+}
@@ -0,0 +1,97 @@
+package buffer
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// checkRope checks that a rope is balanced and that its sizes add up.
+func checkRope(t *testing.T, r *rope) {
+	if r == nil || r.leaf() {
+		return
+	}
+	if r.size != r.left.size+r.right.size {
+		t.Fatal("bad size:", r.size, r.left.size, r.right.size)
+	}
+	if d := r.left.height - r.right.height; d > 1 || d < -1 {
+		t.Fatal("unbalanced:", r.left.height, r.right.height)
+	}
+	checkRope(t, r.left)
+	checkRope(t, r.right)
+}
+
+func ropeStrings(r *rope) []string {
+	strs := []string{}
+	for _, line := range r.Slice(0, r.Len()) {
+		strs = append(strs, line.ToString())
+	}
+	return strs
+}
+
+func TestRope(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	strs := []string{}
+	for k := 0; k < 1000; k++ {
+		strs = append(strs, fmt.Sprint(k))
+	}
+	lines := []Line{}
+	for _, str := range strs {
+		lines = append(lines, MakeLine(str))
+	}
+	r := makeRope(lines)
+	checkRope(t, r)
+
+	for k := 0; k < 2000; k++ {
+		old, oldStrs := r, append([]string{}, strs...)
+		start := rng.Intn(len(strs) + 1)
+		end := start + rng.Intn(len(strs)-start+1)
+		if end-start > 100 {
+			end = start + 100
+		}
+		insert := []Line{}
+		insertStrs := []string{}
+		for j := rng.Intn(100); j > 0; j-- {
+			str := fmt.Sprint("new", k, j)
+			insert = append(insert, MakeLine(str))
+			insertStrs = append(insertStrs, str)
+		}
+		r = r.Splice(start, end, insert...)
+		strs = append(append(append([]string{}, strs[:start]...), insertStrs...), strs[end:]...)
+		if len(strs) > 0 {
+			row := rng.Intn(len(strs))
+			r = r.Set(row, MakeLine("set"))
+			strs[row] = "set"
+		}
+
+		checkRope(t, r)
+		if !strSliceEq(ropeStrings(r), strs...) {
+			t.Fatal("rope differs from slice after splice", start, end)
+		}
+		if !strSliceEq(ropeStrings(old), oldStrs...) {
+			t.Fatal("old rope was modified")
+		}
+	}
+}
+
+func TestFirstDiff(t *testing.T) {
+	lines := []Line{}
+	for k := 0; k < 1000; k++ {
+		lines = append(lines, MakeLine(fmt.Sprint(k)))
+	}
+	r := makeRope(lines)
+	if diff := firstDiff(r, makeRope(lines)); diff != -1 {
+		t.Error("equal ropes differ at", diff)
+	}
+	for _, row := range []int{0, 1, 500, 999} {
+		if diff := firstDiff(r, r.Set(row, MakeLine("x"))); diff != row {
+			t.Error("expected a difference at", row, "not", diff)
+		}
+		if diff := firstDiff(r.Splice(row, row, MakeLine("x")), r); diff != row {
+			t.Error("expected a difference at", row, "not", diff)
+		}
+	}
+	if diff := firstDiff(r, r.Splice(1000, 1000, MakeLine(""))); diff != 1000 {
+		t.Error("expected a difference at the end, not", diff)
+	}
+}
@@ -37,6 +37,9 @@ type BufferHist struct {
 	snapChan chan struct{}
 	snapReq  BufferState
 	reqMutex *sync.Mutex
+
+	// paused counts the calls to Pause not yet matched by Resume.
+	paused int
 }
 
 // NewBufferHist creates a new BufferHist object initialized with the current state.
@@ -79,16 +82,23 @@ func (bh *BufferHist) Snapshot(buff buffer.Buffer, mc cursor.MultiCursor) {
 func (bh *BufferHist) handleSnapshots() {
 	go func() {
 		for range time.Tick(time.Millisecond * 100) {
-			select {
-			case <-bh.snapChan:
-				bh.reqMutex.Lock()
-				bh.snapshot(bh.snapReq.buff, bh.snapReq.mc)
-				bh.reqMutex.Unlock()
-			}
+			bh.takeRequested()
 		}
 	}()
 }
 
+// takeRequested takes the requested snapshot, if there is one. Moving
+// through the history takes it first, so that the latest edit isn't lost.
+func (bh *BufferHist) takeRequested() {
+	bh.reqMutex.Lock()
+	defer bh.reqMutex.Unlock()
+	select {
+	case <-bh.snapChan:
+		bh.snapshot(bh.snapReq.buff, bh.snapReq.mc)
+	default:
+	}
+}
+
 // SnapshotSaved toggles on the "saved" attribute for the current state.
 func (bh *BufferHist) SnapshotSaved() {
 	bh.elemMutex.Lock()
@@ -96,8 +106,58 @@ func (bh *BufferHist) SnapshotSaved() {
 	bh.elemMutex.Unlock()
 }
 
+// Pause stops snapshots from being taken, so that a series of edits (such
+// as a macro playback) becomes a single undo step. Any pending snapshot
+// request is taken first.
+func (bh *BufferHist) Pause() {
+	bh.takeRequested()
+	bh.elemMutex.Lock()
+	bh.paused++
+	bh.elemMutex.Unlock()
+}
+
+// Resume undoes a Pause. Once snapshots are no longer paused, it takes a
+// snapshot of the current state.
+func (bh *BufferHist) Resume(buff buffer.Buffer, mc cursor.MultiCursor) {
+	bh.elemMutex.Lock()
+	if bh.paused > 0 {
+		bh.paused--
+	}
+	paused := bh.paused > 0
+	bh.elemMutex.Unlock()
+
+	// Requests made while paused are dropped by snapshot, but the
+	// current state replaces them.
+	if paused {
+		return
+	}
+	bh.takeRequested()
+	current, _ := bh.Current()
+	if !current.Equals(&buff) {
+		bh.ForceSnapshot(buff, mc)
+	}
+}
+
+// Reset forgets the history, starting over with the current (saved) state.
+func (bh *BufferHist) Reset(buff buffer.Buffer, mc cursor.MultiCursor) {
+	bh.takeRequested()
+	state := NewBufferState(buff.Dup(), mc.Dup())
+	state.saved = true
+	bh.elemMutex.Lock()
+	bh.list.Init()
+	bh.element = bh.list.PushBack(state)
+	bh.elemMutex.Unlock()
+}
+
 func (bh *BufferHist) snapshot(buff buffer.Buffer, mc cursor.MultiCursor) {
 
+	bh.elemMutex.Lock()
+	paused := bh.paused > 0
+	bh.elemMutex.Unlock()
+	if paused {
+		return
+	}
+
 	curBuf, curMC := bh.Current()
 
 	curRow := curMC.GetRow(0)
@@ -174,6 +234,7 @@ func (bh *BufferHist) Current() (buffer.Buffer, cursor.MultiCursor) {
 
 // Next bumps the current pointer to the next state (redo).
 func (bh *BufferHist) Next() (buffer.Buffer, cursor.MultiCursor) {
+	bh.takeRequested()
 	next := bh.element.Next()
 	if next != nil {
 		bh.element = next
@@ -183,6 +244,7 @@ func (bh *BufferHist) Next() (buffer.Buffer, cursor.MultiCursor) {
 
 // Prev bumps the current pointer to the previous state (undo).
 func (bh *BufferHist) Prev() (buffer.Buffer, cursor.MultiCursor) {
+	bh.takeRequested()
 	bh.elemMutex.Lock()
 	prev := bh.element.Prev()
 	bh.elemMutex.Unlock()
@@ -196,6 +258,7 @@ func (bh *BufferHist) Prev() (buffer.Buffer, cursor.MultiCursor) {
 
 // NextSaved bumps the current pointer to the next saved state (macro redo).
 func (bh *BufferHist) NextSaved() (buffer.Buffer, cursor.MultiCursor) {
+	bh.takeRequested()
 	for el := bh.element.Next(); el != nil; el = el.Next() {
 		if el.Value.(*BufferState).saved {
 			bh.SnapshotSaved()
@@ -210,6 +273,7 @@ func (bh *BufferHist) NextSaved() (buffer.Buffer, cursor.MultiCursor) {
 
 // PrevSaved bumps the current pointer to the previous saved state (macro undo).
 func (bh *BufferHist) PrevSaved() (buffer.Buffer, cursor.MultiCursor) {
+	bh.takeRequested()
 	for el := bh.element.Prev(); el != nil; el = el.Prev() {
 		if el.Value.(*BufferState).saved {
 			bh.SnapshotSaved()
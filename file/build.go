@@ -0,0 +1,53 @@
+package file
+
+import (
+	"sync"
+
+	"github.com/wx13/sith/theme"
+)
+
+// buildErrors are the rows of the file with errors from the last build (or
+// test) command, mapped to their messages.
+type buildErrors struct {
+	rows  map[int]string
+	mutex sync.Mutex
+}
+
+// BuildCmd returns the command which builds the file's project (empty if
+// there is none).
+func (file *File) BuildCmd() string {
+	return file.buildCmd
+}
+
+// TestCmd returns the command which tests the file's project (empty if
+// there is none).
+func (file *File) TestCmd() string {
+	return file.testCmd
+}
+
+// ErrorFormat returns the regular expressions which find errors in the
+// output of the build and test commands (empty for the defaults).
+func (file *File) ErrorFormat() []string {
+	return file.errorFormat
+}
+
+// SetBuildErrors marks rows with errors from a build in the gutter. rows
+// maps each row to its error message; nil clears the markers.
+func (file *File) SetBuildErrors(rows map[int]string) {
+	file.buildErrs.mutex.Lock()
+	file.buildErrs.rows = rows
+	file.buildErrs.mutex.Unlock()
+	file.RequestFlush()
+}
+
+// buildErrorRows returns the rows with errors from the last build.
+func (file *File) buildErrorRows() map[int]string {
+	file.buildErrs.mutex.Lock()
+	defer file.buildErrs.mutex.Unlock()
+	return file.buildErrs.rows
+}
+
+// drawBuildError draws a build error indicator in the gutter.
+func (file *File) drawBuildError(row int) {
+	file.screen.DrawGutterSymbol(row, '✖', theme.Fg("gutter.error"))
+}
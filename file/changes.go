@@ -0,0 +1,125 @@
+package file
+
+import (
+	"sync"
+
+	"github.com/wx13/sith/file/buffer"
+)
+
+// syncDiffRows is the longest buffer whose diff is worked out while drawing
+// the screen. Diffs of longer buffers are worked out in the background.
+const syncDiffRows = 10000
+
+// changeDiff caches the diff between the buffer and an older version of it
+// (the saved version of the file, or the version in git), along with the
+// versions of the two it was worked out for. While drawing the screen, an
+// out of date diff is worked out again in the background, so that typing
+// never waits on it.
+type changeDiff struct {
+	buffer *buffer.Buffer
+	saved  *buffer.Buffer
+
+	diff         *buffer.Diff
+	result       buffer.DiffResult
+	hunks        []buffer.Hunk
+	version      int
+	savedVersion int
+	running      bool
+
+	mutex sync.Mutex
+}
+
+func newChangeDiff(buff, saved *buffer.Buffer) *changeDiff {
+	return &changeDiff{
+		buffer: buff,
+		saved:  saved,
+	}
+}
+
+// upToDate returns true if the cached diff is for the current versions.
+// The caller must hold the lock.
+func (cd *changeDiff) upToDate() bool {
+	return cd.diff != nil &&
+		cd.version == cd.buffer.Version() &&
+		cd.savedVersion == cd.saved.Version()
+}
+
+// compute works out the diff, and caches it.
+func (cd *changeDiff) compute() (*buffer.Diff, buffer.DiffResult) {
+	// Get the versions first: if there is an edit in between, the diff is
+	// just worked out again next time.
+	version, savedVersion := cd.buffer.Version(), cd.saved.Version()
+	buff, saved := cd.buffer.Dup(), cd.saved.Dup()
+	diff := buff.Diff(&saved)
+	result := diff.Result()
+	hunks := diff.Hunks()
+
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+	cd.diff, cd.result, cd.hunks = diff, result, hunks
+	cd.version, cd.savedVersion = version, savedVersion
+	return diff, result
+}
+
+// get returns the current diff, working it out if the cached one is out
+// of date.
+func (cd *changeDiff) get() (*buffer.Diff, buffer.DiffResult) {
+	cd.mutex.Lock()
+	if cd.upToDate() {
+		defer cd.mutex.Unlock()
+		return cd.diff, cd.result
+	}
+	cd.mutex.Unlock()
+	return cd.compute()
+}
+
+// getHunks returns the current diff as a list of hunks, working it out if
+// the cached one is out of date.
+func (cd *changeDiff) getHunks() []buffer.Hunk {
+	cd.get()
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+	return cd.hunks
+}
+
+// latest returns the diff result for drawing the screen. If the cached one
+// is out of date and the buffer is long, a new one is worked out in the
+// background, and flush is called when it is ready.
+func (cd *changeDiff) latest(flush func()) buffer.DiffResult {
+	if cd.buffer.Length() <= syncDiffRows {
+		_, result := cd.get()
+		return result
+	}
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+	cd.refresh(flush)
+	return cd.result
+}
+
+// latestHunks is like latest, but returns the diff as a list of hunks.
+func (cd *changeDiff) latestHunks(flush func()) []buffer.Hunk {
+	if cd.buffer.Length() <= syncDiffRows {
+		return cd.getHunks()
+	}
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+	cd.refresh(flush)
+	return cd.hunks
+}
+
+// refresh works out a new diff in the background, if the cached one is out
+// of date (and one isn't being worked out already), and calls flush when it
+// is ready. The caller must hold the lock.
+func (cd *changeDiff) refresh(flush func()) {
+	if cd.upToDate() || cd.running {
+		return
+	}
+	cd.running = true
+	go func() {
+		cd.compute()
+		cd.mutex.Lock()
+		cd.running = false
+		cd.mutex.Unlock()
+		flush()
+	}()
+}
@@ -7,8 +7,13 @@ import ()
 // and also the wanted column (colwant) of the cursor.
 // The wanted column is the column the cursor would like
 // to be in, if the line were long enough.
+// A cursor may also carry an anchor, which marks the other end of
+// a selection.
 type Cursor struct {
 	row, col, colwant int
+
+	anchorRow, anchorCol int
+	anchored             bool
 }
 
 // MakeCursor creates a new Cursor object.
@@ -40,9 +45,12 @@ func CursorFromSlice(s []int) Cursor {
 // Dup duplicates a cursor object.
 func (cursor Cursor) Dup() Cursor {
 	return Cursor{
-		row:     cursor.row,
-		col:     cursor.col,
-		colwant: cursor.colwant,
+		row:       cursor.row,
+		col:       cursor.col,
+		colwant:   cursor.colwant,
+		anchorRow: cursor.anchorRow,
+		anchorCol: cursor.anchorCol,
+		anchored:  cursor.anchored,
 	}
 }
 
@@ -68,3 +76,20 @@ func (cursor *Cursor) Set(row, col, colwant int) {
 	cursor.col = col
 	cursor.colwant = col
 }
+
+// Anchor returns the anchor position, and whether or not the anchor is set.
+func (cursor Cursor) Anchor() (int, int, bool) {
+	return cursor.anchorRow, cursor.anchorCol, cursor.anchored
+}
+
+// SetAnchor drops the anchor at the current cursor position.
+func (cursor *Cursor) SetAnchor() {
+	cursor.anchorRow = cursor.row
+	cursor.anchorCol = cursor.col
+	cursor.anchored = true
+}
+
+// ClearAnchor removes the anchor.
+func (cursor *Cursor) ClearAnchor() {
+	cursor.anchored = false
+}
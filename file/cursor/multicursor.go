@@ -157,6 +157,56 @@ func (mc *MultiCursor) SetColwant(idx, colwant int) {
 	}
 }
 
+// SetAnchors drops an anchor at each cursor position.
+func (mc *MultiCursor) SetAnchors() {
+	for idx := range mc.cursors {
+		mc.cursors[idx].SetAnchor()
+	}
+}
+
+// ShiftCols moves the cursors and anchors on each row in shifts along by
+// that many columns (but not past the start of the row).
+func (mc *MultiCursor) ShiftCols(shifts map[int]int) {
+	for idx := range mc.cursors {
+		cursor := &mc.cursors[idx]
+		if n, ok := shifts[cursor.row]; ok {
+			cursor.col = max(cursor.col+n, 0)
+			cursor.colwant = cursor.col
+		}
+		if n, ok := shifts[cursor.anchorRow]; ok && cursor.anchored {
+			cursor.anchorCol = max(cursor.anchorCol+n, 0)
+		}
+	}
+}
+
+// ShiftRows moves the cursors and anchors to new rows.
+func (mc *MultiCursor) ShiftRows(shift func(row int) int) {
+	for idx := range mc.cursors {
+		cursor := &mc.cursors[idx]
+		cursor.row = shift(cursor.row)
+		if cursor.anchored {
+			cursor.anchorRow = shift(cursor.anchorRow)
+		}
+	}
+}
+
+// ClearAnchors removes all the cursor anchors.
+func (mc *MultiCursor) ClearAnchors() {
+	for idx := range mc.cursors {
+		mc.cursors[idx].ClearAnchor()
+	}
+}
+
+// HasAnchors returns true if any cursor has an anchor set.
+func (mc MultiCursor) HasAnchors() bool {
+	for _, cursor := range mc.cursors {
+		if cursor.anchored {
+			return true
+		}
+	}
+	return false
+}
+
 // ReplaceMC sets the list of cursors to be the list of
 // cursors from another MC object.
 func (mc *MultiCursor) ReplaceMC(mc2 MultiCursor) {
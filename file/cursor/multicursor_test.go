@@ -62,3 +62,20 @@ func TestMCClear(t *testing.T) {
 		t.Error("SetColumn() failed:", mc.Length())
 	}
 }
+
+func TestMCAnchors(t *testing.T) {
+	mc := makeMC()
+	if mc.HasAnchors() {
+		t.Error("New cursors should not have anchors")
+	}
+	mc.SetAnchors()
+	mc.SetCursor(0, 20, 1, 1)
+	row, col, ok := mc.Dup().GetCursor(0).Anchor()
+	if !ok || row == 20 || col == 1 {
+		t.Errorf("Anchor should stay put: %d %d %v", row, col, ok)
+	}
+	mc.ClearAnchors()
+	if mc.HasAnchors() {
+		t.Error("Anchors should have been cleared")
+	}
+}
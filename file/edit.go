@@ -27,6 +27,9 @@ func sprintf(format string, a ...interface{}) string {
 // only on selected lines.
 func (file *File) Fmt(selection ...bool) error {
 
+	if !file.writable() {
+		return nil
+	}
 	ext := GetFileExt(file.Name)
 	if file.fmtCmd == "" && ext != "go" {
 		return nil
@@ -39,8 +42,10 @@ func (file *File) Fmt(selection ...bool) error {
 	startRow := 0
 	endRow := 0
 	if len(selection) > 0 {
-		file.MultiCursor.OuterMost()
-		startRow, endRow = file.MultiCursor.MinMaxRow()
+		if !file.HasSelection() {
+			file.MultiCursor.OuterMost()
+		}
+		startRow, endRow = file.selectedRows()
 		if (ext == "go") || (!hasLineBounds) {
 			subBuffer := file.buffer.InclSlice(startRow, endRow)
 			contents = subBuffer.ToString(file.newline)
@@ -138,6 +143,9 @@ func (file *File) goFmt(contents string) (string, error) {
 // FmtCodeBlock formats the code block that the cursor is currently in.
 // This is designed for markdown/quarto files with embedded code blocks.
 func (file *File) FmtCodeBlock() error {
+	if !file.writable() {
+		return nil
+	}
 	row := file.MultiCursor.GetRow(0)
 
 	// Find code block boundaries
@@ -216,7 +224,6 @@ func (file *File) FmtCodeBlock() error {
 	}
 
 	file.buffer.ReplaceLines(newLines, codeStartRow, codeEndRow)
-	file.InvalidateSyntaxCache(codeStartRow)
 	file.Snapshot()
 
 	return nil
@@ -406,8 +413,9 @@ func (file File) removeBlankLineCursors(rows map[int][]int) (map[int][]int, []in
 // Complete possibly runs autocompletion, depending on situation.
 func (file *File) complete(ch rune) bool {
 
-	// Only run autocompletion if user pressed tab.
-	if ch != '\t' {
+	// Only run autocompletion if user pressed tab (and the file isn't
+	// too big to search).
+	if ch != '\t' || file.Large() {
 		return false
 	}
 
@@ -447,6 +455,11 @@ func (file *File) complete(ch rune) bool {
 // InsertChar insters a character (rune) into the current cursor position.
 func (file *File) InsertChar(ch rune) {
 
+	if !file.writable() {
+		return
+	}
+	file.ClearSelection()
+
 	rate := file.timer.Tick()
 	// Don't even try autocomplete if text is being pasted.
 	if rate < file.maxRate {
@@ -468,19 +481,13 @@ func (file *File) InsertChar(ch rune) {
 }
 
 func (file *File) InsertStr(str string) {
+	if !file.writable() {
+		return
+	}
 	rows := file.MultiCursor.GetRowsCols()
 	var blankRows []int
 	rows, blankRows = file.removeBlankLineCursors(rows)
 
-	// Invalidate syntax cache from the first modified row
-	minRow := file.buffer.Length()
-	for row := range rows {
-		if row < minRow {
-			minRow = row
-		}
-	}
-	file.InvalidateSyntaxCache(minRow)
-
 	rows = file.buffer.InsertStr(str, rows)
 	for _, row := range blankRows {
 		rows[row] = []int{0}
@@ -502,6 +509,14 @@ func allColsZero(rows map[int][]int) bool {
 // Backspace removes the character before the cursor.
 func (file *File) Backspace() {
 
+	if !file.writable() {
+		return
+	}
+	if file.HasSelection() {
+		file.DeleteSelection()
+		return
+	}
+
 	indent := 0
 	if file.autoTab {
 		indent = len(file.tabString)
@@ -509,15 +524,6 @@ func (file *File) Backspace() {
 
 	rows := file.MultiCursor.GetRowsCols()
 
-	// Invalidate syntax cache from the first modified row
-	minRow := file.buffer.Length()
-	for row := range rows {
-		if row < minRow {
-			minRow = row
-		}
-	}
-	file.InvalidateSyntaxCache(minRow)
-
 	if allColsZero(rows) {
 		rows = file.buffer.DeleteNewlines(rows)
 	} else {
@@ -531,6 +537,13 @@ func (file *File) Backspace() {
 
 // Delete deletes the character under the cursor.
 func (file *File) Delete() {
+	if !file.writable() {
+		return
+	}
+	if file.HasSelection() {
+		file.DeleteSelection()
+		return
+	}
 	file.CursorRight()
 	file.Backspace()
 }
@@ -538,14 +551,16 @@ func (file *File) Delete() {
 // Newline breaks the current line into two.
 func (file *File) Newline() {
 
+	if !file.writable() {
+		return
+	}
+	file.ClearSelection()
+
 	// For a single cursor, do autoindent.
 	if len(file.MultiCursor.Cursors()) == 1 {
 		cursor := file.MultiCursor.Cursors()[0]
 		row, col := cursor.RowCol()
 
-		// Invalidate syntax cache from this row
-		file.InvalidateSyntaxCache(row)
-
 		lineStart := file.buffer.RowSlice(row, 0, col)
 		lineEnd := file.buffer.RowSlice(row, col, -1)
 		newLines := []buffer.Line{lineStart, lineEnd}
@@ -668,15 +683,22 @@ func (file *File) UnJustify() {
 }
 
 func (file *File) justify(lineLen int) {
-	minRow, maxRow := file.MultiCursor.MinMaxRow()
+	if !file.writable() {
+		return
+	}
+	minRow, maxRow := file.selectedRows()
 	file.buffer.Justify(minRow, maxRow, lineLen,
 		[]string{"//", "#", "%", ";", "\\*"})
+	file.ClearSelection()
 	file.MultiCursor.Clear()
 	file.Snapshot()
 }
 
 // Cut cuts the current line and adds to the copy buffer.
 func (file *File) Cut() []string {
+	if !file.writable() {
+		return nil
+	}
 	row := file.MultiCursor.GetRow(0)
 	cutLines := file.buffer.InclSlice(row, row).Dup()
 	strs := make([]string, cutLines.Length())
@@ -692,6 +714,9 @@ func (file *File) Cut() []string {
 
 // Paste inserts the copy buffer into buffer at the current line.
 func (file *File) Paste(strs []string) {
+	if !file.writable() {
+		return
+	}
 	row := file.MultiCursor.GetRow(0)
 	pasteLines := make([]buffer.Line, len(strs))
 	for idx, str := range strs {
@@ -706,6 +731,9 @@ func (file *File) Paste(strs []string) {
 
 // CutToStartOfLine cuts the text from the cursor to the start of the line.
 func (file *File) CutToStartOfLine() {
+	if !file.writable() {
+		return
+	}
 	for idx := range file.MultiCursor.Cursors() {
 		row, col := file.MultiCursor.GetRowCol(idx)
 		line := file.buffer.GetRow(row).Slice(col, -1)
@@ -717,6 +745,9 @@ func (file *File) CutToStartOfLine() {
 
 // CutToEndOfLine cuts the text from the cursor to the end of the line.
 func (file *File) CutToEndOfLine() {
+	if !file.writable() {
+		return
+	}
 	for idx := range file.MultiCursor.Cursors() {
 		row, col := file.MultiCursor.GetRowCol(idx)
 		line := file.buffer.GetRow(row).Slice(0, col)
@@ -727,6 +758,9 @@ func (file *File) CutToEndOfLine() {
 
 // CutToStartOfWord cuts the text from the cursor to the start of the word.
 func (file *File) CutWord(mode int) {
+	if !file.writable() {
+		return
+	}
 	for idx := range file.MultiCursor.Cursors() {
 		row, col := file.MultiCursor.GetRowCol(idx)
 		newCol := file.buffer.CutWord(row, col, mode)
@@ -738,6 +772,9 @@ func (file *File) CutWord(mode int) {
 // CursorAlign inserts spaces into each cursor position, in order to
 // align the cursors vertically.
 func (file *File) CursorAlign() {
+	if !file.writable() {
+		return
+	}
 	rows := file.MultiCursor.GetRowsCols()
 	rows = file.buffer.Align(rows)
 	file.MultiCursor.ResetCursors(rows)
@@ -747,6 +784,9 @@ func (file *File) CursorAlign() {
 // CursorUnalign removes whitespace (except for 1 space) immediately preceding
 // each cursor position. Effectively, it undoes a CursorAlign.
 func (file *File) CursorUnalign() {
+	if !file.writable() {
+		return
+	}
 	rows := file.MultiCursor.GetRowsCols()
 	rows = file.buffer.Unalign(rows)
 	file.MultiCursor.ResetCursors(rows)
@@ -8,21 +8,31 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/wx13/sith/config"
 	"github.com/wx13/sith/file/buffer"
 	"github.com/wx13/sith/file/cursor"
+	"github.com/wx13/sith/lsp"
 	"github.com/wx13/sith/syntaxcolor"
 	"github.com/wx13/sith/terminal"
 	"github.com/wx13/sith/ui"
 )
 
 // File contains all the details about a given file. This includes:
-// name, buffer, buffer history, file-specific settings, etc.
+// name, buffer, buffer history, file-specific settings, etc. The cursors
+// and scroll position are in its View; windows onto the same file share
+// everything else.
 type File struct {
+	*fileData
+	*View
+}
+
+// fileData is the part of a File which is shared by all of its views.
+type fileData struct {
 	buffer      buffer.Buffer
-	MultiCursor cursor.MultiCursor
 	savedBuffer buffer.Buffer
+	changes     *changeDiff
 
 	// Check for file system changes.
 	md5sum  [16]byte
@@ -40,7 +50,7 @@ type File struct {
 
 	Name        string
 	SyntaxRules *syntaxcolor.SyntaxRules
-	stateCache  *syntaxcolor.StateCache
+	syntax      *syntaxStates
 	fileMode    os.FileMode
 	autoIndent  bool
 
@@ -55,14 +65,52 @@ type File struct {
 
 	autoFmt    bool
 	fmtCmd     string
+	backup     string
 	fullConfig config.Config
 
-	rowOffset int
-	colOffset int
-	screen    *terminal.Screen
+	// Language server (nil if there is none).
+	lspCmd     string
+	lspDoc     *lsp.Document
+	lspVersion int
+	lspMutex   *sync.Mutex
+
+	// Build and test commands, and the errors from the last one.
+	buildCmd    string
+	testCmd     string
+	errorFormat []string
+	buildErrs   *buildErrors
+
+	// The file's version in git.
+	gitInfo *gitState
+
+	softWrap  bool
+	wrapWidth int
 	flushChan chan struct{}
 	saveChan  chan struct{}
 
+	// lineNumbers is the line number mode (see LineNumbers).
+	lineNumbers string
+
+	// Swap (autosave/lock) file.
+	swapPath     string
+	swapStop     chan struct{}
+	swapMutex    *sync.Mutex
+	swapWritten  bool
+	swapModified bool
+	swapText     string
+	swapState    swapState
+	foundSwap    *Swap
+
+	// loaded is closed once the file has been read in.
+	loaded chan struct{}
+
+	// Large and binary files (see safeMode).
+	safe      *safeMode
+	largeFile int64
+
+	// scratch is true for a file which isn't on disk (see NewScratch).
+	scratch bool
+
 	notification      string
 	clearNotification bool
 
@@ -75,36 +123,53 @@ func NewFile(name string, flushChan chan struct{}, screen *terminal.Screen,
 	cfg config.Config, wg *sync.WaitGroup) *File {
 
 	file := &File{
-		Name:        name,
-		screen:      screen,
-		fileMode:    os.FileMode(int(0644)),
-		buffer:      buffer.MakeBuffer([]string{""}),
-		savedBuffer: buffer.MakeBuffer([]string{""}),
-		MultiCursor: cursor.MakeMultiCursor(),
-		flushChan:   flushChan,
-		saveChan:    make(chan struct{}, 1),
-		autoIndent:  true,
-		autoTab:     true,
-		tabDetect:   true,
-		tabString:   "\t",
-		tabWidth:    4,
-		lineLen:     80,
-		newline:     "\n",
-		tabHealth:   true,
-		timer:       MakeTimer(),
-		maxRate:     100.0,
-		statusMutex: &sync.Mutex{},
-		modTime:     time.Now(),
-		md5sum:      md5.Sum([]byte("")),
-		autoFmt:     true,
+		fileData: &fileData{
+			Name:        name,
+			fileMode:    os.FileMode(int(0644)),
+			buffer:      buffer.MakeBuffer([]string{""}),
+			savedBuffer: buffer.MakeBuffer([]string{""}),
+			flushChan:   flushChan,
+			saveChan:    make(chan struct{}, 1),
+			autoIndent:  true,
+			autoTab:     true,
+			tabDetect:   true,
+			tabString:   "\t",
+			tabWidth:    4,
+			lineLen:     80,
+			newline:     "\n",
+			tabHealth:   true,
+			timer:       MakeTimer(),
+			maxRate:     100.0,
+			statusMutex: &sync.Mutex{},
+			lspMutex:    &sync.Mutex{},
+			buildErrs:   &buildErrors{},
+			swapMutex:   &sync.Mutex{},
+			loaded:      make(chan struct{}),
+			safe:        &safeMode{},
+			largeFile:   defaultLargeFile,
+			modTime:     time.Now(),
+			md5sum:      md5.Sum([]byte("")),
+			autoFmt:     true,
+		},
+		View: &View{
+			screen:      screen,
+			MultiCursor: cursor.MakeMultiCursor(),
+		},
 	}
+	file.changes = newChangeDiff(&file.buffer, &file.savedBuffer)
+	file.gitInfo = newGitState(&file.buffer)
 	file.ingestConfig(cfg)
 	go file.processSaveRequests()
 	go func() {
 		// Read file async, so we don't have to wait for it.
-		file.ReadFile(name, wg)
-		// Once the file is read, initialize the buffer history.
+		file.ReadFile(name)
+		// Once the file is read, initialize the buffer history (restoring
+		// the persisted history, if it matches the file).
 		file.buffHist = NewBufferHist(file.buffer, file.MultiCursor)
+		file.LoadHistory()
+		file.openSwap()
+		close(file.loaded)
+		wg.Done()
 	}()
 	return file
 }
@@ -134,18 +199,47 @@ func (file *File) ingestConfig(cfg config.Config) {
 	file.tabDetect = extCfg.TabDetect
 	file.tabWidth = extCfg.TabWidth
 	file.tabString = extCfg.TabString
+	file.softWrap = extCfg.SoftWrap
+	file.lineNumbers = extCfg.LineNumbers
 	if extCfg.LineLen_set && extCfg.LineLen > 0 {
 		file.lineLen = extCfg.LineLen
 	}
-	file.SyntaxRules = syntaxcolor.NewSyntaxRulesWithFullConfig(extCfg, cfg)
-	file.SyntaxRules.SetupForLanguage(ext)
-	file.stateCache = syntaxcolor.NewStateCache()
-	file.fmtCmd = extCfg.FmtCmd
+	if extCfg.LargeFile_set && extCfg.LargeFile > 0 {
+		file.largeFile = int64(extCfg.LargeFile) << 20
+	}
 	file.fullConfig = cfg
+	file.RefreshSyntax()
+	file.fmtCmd = extCfg.FmtCmd
+	file.lspCmd = extCfg.LspCmd
+	file.buildCmd = extCfg.BuildCmd
+	file.testCmd = extCfg.TestCmd
+	file.errorFormat = extCfg.ErrorFormat
+	file.backup = extCfg.Backup
+}
+
+// RefreshSyntax rebuilds the syntax highlighting rules (e.g. after the
+// color theme changes).
+func (file *File) RefreshSyntax() {
+	ext := GetFileExt(file.Name)
+	rules := syntaxcolor.NewSyntaxRulesWithFullConfig(file.fullConfig.ForExt(ext), file.fullConfig)
+	rules.SetupForLanguage(ext)
+	file.SyntaxRules = rules
+	if file.syntax == nil {
+		file.syntax = newSyntaxStates(&file.buffer)
+	}
+	file.syntax.reset(rules)
 }
 
 // Reload re-reads a file from disk.
 func (file *File) Reload(wgs ...*sync.WaitGroup) {
+	if file.scratch {
+		file.NotifyUser("Nothing to reload")
+		return
+	}
+	if file.Loading() {
+		file.NotifyUser("Still loading")
+		return
+	}
 	if file.IsModified() {
 		prompt := ui.MakePrompt(file.screen, file.newKeyboard())
 		ok, _ := prompt.AskYesNo("Changes will be lost. Reload anyway?")
@@ -170,14 +264,6 @@ func (file *File) Close() bool {
 	return true
 }
 
-// InvalidateSyntaxCache invalidates syntax highlighting state from a given row onwards.
-// This should be called whenever the buffer is modified.
-func (file *File) InvalidateSyntaxCache(fromRow int) {
-	if file.stateCache != nil {
-		file.stateCache.Invalidate(fromRow)
-	}
-}
-
 // ToggleMCMode cycles among the available multicursor navigation modes.
 func (file *File) ToggleMCMode() {
 	file.MultiCursor.CycleNavMode()
@@ -248,6 +334,9 @@ func (file *File) UnsetTabStr() {
 
 // ComputeIndent sets the tabString and tabHealth based on the current indentation.
 func (file *File) ComputeIndent() {
+	if file.Large() {
+		return
+	}
 	if file.tabDetect {
 		file.tabString, file.tabHealth = file.buffer.GetIndent()
 	} else if file.autoTab {
@@ -290,7 +379,12 @@ func (file *File) ToCorpus(cursors map[int][]int) string {
 // Slice returns a 2D slice of the buffer.
 func (file *File) Slice(nRows, nCols int) []string {
 
+	// Leave room for the cursor at the end of a full row.
+	file.wrapWidth = nCols - 1
 	file.updateOffsets(nRows, nCols)
+	if file.wrapping() {
+		return file.wrappedSlice(nRows, nCols)
+	}
 
 	startRow := file.rowOffset
 	endRow := nRows + file.rowOffset
@@ -322,6 +416,29 @@ func (file *File) ForceSnapshot() {
 	}
 }
 
+// FlushHistory takes any snapshot which has been requested (see Snapshot)
+// but not yet taken.
+func (file *File) FlushHistory() {
+	if file.buffHist != nil {
+		file.buffHist.takeRequested()
+	}
+}
+
+// PauseHistory stops edits from being recorded in the undo history until
+// ResumeHistory is called, so that they are undone as a single step.
+func (file *File) PauseHistory() {
+	if file.buffHist != nil {
+		file.buffHist.Pause()
+	}
+}
+
+// ResumeHistory resumes recording edits in the undo history.
+func (file *File) ResumeHistory() {
+	if file.buffHist != nil {
+		file.buffHist.Resume(file.buffer, file.MultiCursor)
+	}
+}
+
 // SnapshotSaved saves a special "saved" snapshot when the user saves (or opens)
 // a file.
 func (file *File) SnapshotSaved() {
@@ -332,6 +449,9 @@ func (file *File) SnapshotSaved() {
 
 // Undo reverts the buffer state to the last snapshot.
 func (file *File) Undo() {
+	if !file.writable() {
+		return
+	}
 	if file.buffHist == nil {
 		return
 	}
@@ -342,6 +462,9 @@ func (file *File) Undo() {
 
 // Redo sets the buffer state ahead one in the buffer history.
 func (file *File) Redo() {
+	if !file.writable() {
+		return
+	}
 	if file.buffHist == nil {
 		return
 	}
@@ -352,6 +475,9 @@ func (file *File) Redo() {
 
 // UndoSaved reverts the buffer state to the last *saved* snapshot.
 func (file *File) UndoSaved() {
+	if !file.writable() {
+		return
+	}
 	if file.buffHist == nil {
 		return
 	}
@@ -362,6 +488,9 @@ func (file *File) UndoSaved() {
 
 // RedoSaved is like UndoSaved, but the other direction in time.
 func (file *File) RedoSaved() {
+	if !file.writable() {
+		return
+	}
 	if file.buffHist == nil {
 		return
 	}
@@ -374,9 +503,10 @@ func (file *File) RedoSaved() {
 // the user for confirmation each time.
 func (file *File) AskReplace(searchTerm, replaceTerm string, row, col int, replaceAll bool) error {
 
+	if !file.writable() {
+		return errReadOnly
+	}
 	file.CursorGoTo(row, col)
-	_, screenCol := file.GetCursor(0)
-	startColOffset := screenCol - col
 
 	var doReplace bool
 	var err error
@@ -384,34 +514,72 @@ func (file *File) AskReplace(searchTerm, replaceTerm string, row, col int, repla
 		doReplace = true
 	} else {
 		file.Flush()
+		screenRow, screenCol := file.GetCursor(0)
+		startColOffset := screenCol - col
 		var startCol, endCol int
 		startCol, endCol = file.buffer.GetRow(row).Search(searchTerm, col, -1)
 		for c := startCol + startColOffset; c < endCol+startColOffset; c++ {
-			file.screen.Highlight(row-file.rowOffset, c)
+			file.screen.Highlight(screenRow, c)
 		}
 		prompt := ui.MakePrompt(file.screen, file.newKeyboard())
 		doReplace, err = prompt.AskYesNo("Replace this instance?")
 		for c := startCol; c < endCol; c++ {
-			file.screen.Highlight(row-file.rowOffset, c)
+			file.screen.Highlight(screenRow, c)
 		}
 		if err != nil {
 			return err
 		}
 	}
 	if doReplace {
-		file.buffer.ReplaceWord(searchTerm, replaceTerm, row, col)
+		replacement := file.buffer.ReplaceWord(searchTerm, replaceTerm, row, col)
 		file.screen.WriteString(row, 0, file.buffer.GetRow(row).ToString())
-		file.CursorGoTo(row, col+len(replaceTerm))
+		file.CursorGoTo(row, col+utf8.RuneCountInString(replacement))
 	}
 	return nil
 
 }
 
+// ReplaceAll replaces every instance of searchTerm with replaceTerm, without
+// asking. It returns the number of replacements.
+func (file *File) ReplaceAll(searchTerm, replaceTerm string) int {
+	if !file.writable() {
+		return 0
+	}
+	count := 0
+	for row := 0; row < file.buffer.Length(); row++ {
+		line, n := file.buffer.GetRowDirect(row).ReplaceAll(searchTerm, replaceTerm)
+		if n > 0 {
+			file.buffer.SetRow(row, line)
+			count += n
+		}
+	}
+	if count > 0 {
+		file.enforceColBounds()
+		file.Snapshot()
+	}
+	return count
+}
+
 // Length returns the number of lines in the buffer.
 func (file *File) Length() int {
 	return file.buffer.Length()
 }
 
+// Version returns a number which goes up every time the buffer changes.
+func (file *File) Version() int {
+	return file.buffer.Version()
+}
+
+// RowLength returns the number of characters in a row.
+func (file *File) RowLength(row int) int {
+	return file.buffer.RowLength(row)
+}
+
+// GetLine returns the text of the specified row.
+func (file *File) GetLine(row int) string {
+	return file.buffer.GetRowDirect(row).ToString()
+}
+
 // MarkedSearch searches for searchTerm in the text limited by the muticursor
 // extent.
 func (file *File) MarkedSearch(searchTerm string, loop bool) (row, col int, err error) {
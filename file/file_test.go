@@ -1,13 +1,34 @@
 package file_test
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/wx13/sith/config"
 	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/terminal"
+	"github.com/wx13/sith/theme"
 )
 
+// TestMain keeps the tests' swap and history files out of the user's config
+// directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "sith_state")
+	if err != nil {
+		panic(err)
+	}
+	file.SetStateDir(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestNewFile(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -162,3 +183,755 @@ func TestFindCodeBlockBoundsQuarto(t *testing.T) {
 		t.Errorf("Quarto syntax: Expected (1, 3, python), got (%d, %d, %s)", start, end, lang)
 	}
 }
+
+func TestSelection(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("hello")
+	f.Newline()
+	f.InsertStr("world")
+
+	f.MultiCursor.Set(0, 3, 3)
+	f.ExtendSelection()
+	f.CursorDown(1)
+	if !f.HasSelection() {
+		t.Fatal("Should have a selection")
+	}
+	strs := f.CutSelection()
+	if len(strs) != 2 || strs[0] != "lo" || strs[1] != "wor" {
+		t.Errorf("Wrong selected text: %q", strs)
+	}
+	CheckBuffer(t, f, "helld", "CutSelection")
+	if f.HasSelection() {
+		t.Error("Selection should be gone after the cut")
+	}
+
+	f.PasteText(strs)
+	CheckBuffer(t, f, "hello\nworld", "PasteText")
+
+	// A plain movement ends a shift-selection, but not a marked one.
+	f.ExtendSelection()
+	f.CursorLeft()
+	f.EndShiftSelection()
+	if f.HasSelection() {
+		t.Error("Shift-selection should have been cleared")
+	}
+	f.ToggleMark()
+	f.CursorLeft()
+	f.EndShiftSelection()
+	if !f.HasSelection() {
+		t.Error("Marked selection should remain")
+	}
+}
+
+func TestIndentSelection(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	cfg := config.Config{TabWidth: 4, TabWidth_set: true, TabString: "\t", TabString_set: true}
+	f := file.NewFile("", make(chan struct{}), nil, cfg, &wg)
+	wg.Wait()
+	f.InsertPasted("\t\tfoo\n      bar\nbaz")
+
+	// Unindenting takes off one level, and the selection moves with the
+	// text.
+	f.MultiCursor.Set(0, 3, 3)
+	f.ToggleMark()
+	f.CursorDown(1)
+	f.IndentSelection(-1)
+	CheckBuffer(t, f, "\tfoo\n  bar\nbaz", "unindent")
+	if regions := f.Selections(); fmt.Sprint(regions) != "[{0 2 1 0}]" {
+		t.Error("selection should move with the text:", regions)
+	}
+
+	f.CursorDown(1)
+	f.EndOfLine()
+	f.IndentSelection(1)
+	CheckBuffer(t, f, "\t\tfoo\n\t  bar\n\tbaz", "indent")
+	if regions := f.Selections(); fmt.Sprint(regions) != "[{0 3 2 4}]" {
+		t.Error("selection should move with the text:", regions)
+	}
+}
+
+func TestMultiCursorSelection(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("foo = 1")
+	f.Newline()
+	f.InsertStr("bar = 2")
+
+	f.MultiCursor.Set(0, 0, 0)
+	f.AddCursor()
+	f.MultiCursor.Set(1, 0, 0)
+	f.ToggleMark()
+	f.CursorRight()
+	f.CursorRight()
+	f.CursorRight()
+	strs := f.CutSelection()
+	if len(strs) != 2 || strs[0] != "foo" || strs[1] != "bar" {
+		t.Errorf("Wrong selected text: %q", strs)
+	}
+	CheckBuffer(t, f, " = 1\n = 2", "CutSelection")
+
+	// One line per cursor goes to each cursor.
+	f.PasteText([]string{"bar", "foo"})
+	CheckBuffer(t, f, "bar = 1\nfoo = 2", "PasteText")
+}
+
+func TestInsertPasted(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("ab")
+	f.Newline()
+	f.InsertStr("cd")
+	f.ForceSnapshot()
+
+	// The text goes in as is at each cursor, with no auto-indent.
+	f.MultiCursor.Set(0, 1, 1)
+	f.AddCursor()
+	f.MultiCursor.Set(1, 1, 1)
+	f.InsertPasted("x\n\ty")
+	CheckBuffer(t, f, "ax\n\tyb\ncx\n\tyd", "InsertPasted")
+	rows := f.GetRowsCols()
+	if len(rows) != 2 || fmt.Sprint(rows[1], rows[3]) != "[2] [2]" {
+		t.Error("cursors should be after the pasted text:", rows)
+	}
+
+	// It is undone in one step.
+	f.Undo()
+	CheckBuffer(t, f, "ab\ncd", "undo InsertPasted")
+}
+
+func TestReplaceAll(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("a=1, b=2")
+	f.Newline()
+	f.InsertStr("c=3")
+	n := f.ReplaceAll(`/(\w)=(\d)/`, "$2:$1")
+	CheckBuffer(t, f, "1:a, 2:b\n3:c", "ReplaceAll")
+	if n != 3 {
+		t.Error("ReplaceAll should report 3 replacements, got", n)
+	}
+}
+
+func TestView(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("hello")
+	f.Newline()
+	f.InsertStr("world")
+
+	// Two views onto one file have separate cursors, but share the text.
+	view := f.NewView()
+	f.MultiCursor.Set(0, 2, 2)
+	other := f.WithView(view)
+	if r, c := other.GetRowCol(0); r != 1 || c != 5 {
+		t.Error("WithView should show the other view's cursor:", r, c)
+	}
+	if r, c := f.GetRowCol(0); r != 0 || c != 2 {
+		t.Error("WithView should not change the file's cursor:", r, c)
+	}
+	f.InsertChar('X')
+	if other.ToString() != "heXllo\nworld" {
+		t.Error("Views should share the buffer:", other.ToString())
+	}
+
+	// Lines added above the other view's cursor push it down.
+	f.MultiCursor.Set(0, 0, 0)
+	f.Newline()
+	f.Newline()
+	if r, c := f.WithView(view).GetRowCol(0); r != 3 || c != 5 {
+		t.Error("The other view's cursor should move with the text:", r, c)
+	}
+
+	// And removing them brings it back up.
+	f.Backspace()
+	f.Backspace()
+	first := f.GetView()
+	f.SetView(view)
+	if r, c := f.GetRowCol(0); r != 1 || c != 5 {
+		t.Error("SetView should restore the cursor:", r, c)
+	}
+	if r, _ := f.WithView(first).GetRowCol(0); r != 0 {
+		t.Error("The first view should keep its cursor:", r)
+	}
+}
+
+func TestSoftWrap(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	cfg := config.Config{SoftWrap: true, SoftWrap_set: true}
+	f := file.NewFile("", make(chan struct{}), terminal.NewSimulationScreen(12, 5), cfg, &wg)
+	wg.Wait()
+	f.InsertStr("aaaa bbbb cccc dddd")
+	f.Newline()
+	f.InsertStr("  x")
+
+	// Long lines break after whitespace.
+	slice := f.Slice(4, 10)
+	expected := []string{"aaaa ", "bbbb ", "cccc dddd", "  x"}
+	if fmt.Sprint(slice) != fmt.Sprint(expected) {
+		t.Errorf("expected %q, got %q", expected, slice)
+	}
+
+	// The cursor moves by screen rows.
+	f.MultiCursor.Set(0, 7, 7)
+	if r, c := f.GetCursor(0); r != 1 || c != 2 {
+		t.Error("wrong screen position:", r, c)
+	}
+	f.CursorDown(1)
+	if r, c := f.GetRowCol(0); r != 0 || c != 12 {
+		t.Error("CursorDown should stay in the line:", r, c)
+	}
+	f.CursorDown(1)
+	if r, c := f.GetRowCol(0); r != 1 || c != 2 {
+		t.Error("CursorDown should move to the next line:", r, c)
+	}
+	f.CursorUp(2)
+	if r, c := f.GetRowCol(0); r != 0 || c != 7 {
+		t.Error("CursorUp should keep the screen column:", r, c)
+	}
+
+	f.Flush()
+	f.ToggleSoftWrap()
+	slice = f.Slice(4, 10)
+	if len(slice) != 2 || slice[0] != "aaaa bbbb " {
+		t.Errorf("expected unwrapped lines, got %q", slice)
+	}
+
+	// Positions count characters, not bytes.
+	wg.Add(1)
+	f = file.NewFile("", make(chan struct{}), terminal.NewSimulationScreen(12, 5), cfg, &wg)
+	wg.Wait()
+	f.InsertStr("éééé ßßßß cccc dddd")
+	slice = f.Slice(4, 10)
+	expected = []string{"éééé ", "ßßßß ", "cccc dddd"}
+	if fmt.Sprint(slice) != fmt.Sprint(expected) {
+		t.Errorf("expected %q, got %q", expected, slice)
+	}
+	f.MultiCursor.Set(0, 7, 7)
+	if r, c := f.GetCursor(0); r != 1 || c != 2 {
+		t.Error("wrong screen position for non-ASCII text:", r, c)
+	}
+	f.CursorDown(1)
+	if r, c := f.GetRowCol(0); r != 0 || c != 12 {
+		t.Error("CursorDown should stay in the non-ASCII line:", r, c)
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	cfg := config.Config{LineNumbers: "hybrid", LineNumbers_set: true}
+	screen := terminal.NewSimulationScreen(20, 5)
+	f := file.NewFile("", make(chan struct{}), screen, cfg, &wg)
+	wg.Wait()
+	for k := 0; k < 11; k++ {
+		f.InsertStr("line")
+		f.Newline()
+	}
+	f.CursorGoTo(2, 0)
+
+	// Two digits, a space and the indicator columns (showing unsaved
+	// changes).
+	gutter := func(row int) string {
+		str := ""
+		for col := 0; col < screen.GutterWidth(); col++ {
+			r, _, _, _ := screen.GetTcell().GetContent(col, row)
+			str += string(r)
+		}
+		return str
+	}
+	f.Flush()
+	if screen.GutterWidth() != 5 {
+		t.Fatal("wrong gutter width:", screen.GutterWidth())
+	}
+	expected := []string{" 2 ▸ ", " 1 ▸ ", " 3 ▸ ", " 1 ▸ "}
+	for row, exp := range expected {
+		if gutter(row) != exp {
+			t.Errorf("row %d: expected %q, got %q", row, exp, gutter(row))
+		}
+	}
+
+	f.CycleLineNumbers()
+	if f.LineNumbers() != "none" {
+		t.Fatal("expected no line numbers, got", f.LineNumbers())
+	}
+	f.Flush()
+	if screen.GutterWidth() != 2 {
+		t.Error("wrong gutter width:", screen.GutterWidth())
+	}
+}
+
+func TestMouse(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	cfg := config.Config{TabWidth: 4, TabWidth_set: true}
+	f := file.NewFile("", make(chan struct{}), terminal.NewSimulationScreen(20, 5), cfg, &wg)
+	wg.Wait()
+	for k := 0; k < 10; k++ {
+		f.InsertStr(fmt.Sprintf("line%d", k))
+		f.Newline()
+	}
+	for k := 0; k < 10; k++ {
+		f.CursorGoTo(k, 0)
+		f.InsertChar('\t')
+	}
+	f.CursorGoTo(0, 0)
+	f.Flush()
+
+	// Scrolling takes the cursor along, so that it stays on the screen.
+	f.ScrollUp()
+	f.ScrollUp()
+	if r, c := f.GetRowCol(0); r != 2 || c != 0 {
+		t.Error("cursor should follow the scroll:", r, c)
+	}
+
+	// Clicks allow for the row offset and expanded tabs.
+	clicks := []struct{ row, col, bufRow, bufCol int }{
+		{1, 5, 3, 2},
+		{1, 2, 3, 0},
+		{0, -2, 2, 0},
+		{0, 50, 2, 6},
+		{10, 0, 6, 0},
+	}
+	for _, click := range clicks {
+		f.Click(click.row, click.col)
+		if r, c := f.GetRowCol(0); r != click.bufRow || c != click.bufCol {
+			t.Errorf("click at %d, %d: expected %d, %d, got %d, %d", click.row, click.col,
+				click.bufRow, click.bufCol, r, c)
+		}
+	}
+
+	f.Click(0, 4)
+	f.DragTo(1, 6)
+	if text := f.SelectedText(); fmt.Sprint(text) != fmt.Sprint([]string{"line2", "\tli"}) {
+		t.Errorf("wrong selection: %q", text)
+	}
+	f.Click(1, 4)
+	if f.HasSelection() {
+		t.Error("click should clear the selection")
+	}
+
+	f.AddCursorAt(2, 4)
+	if f.MultiCursor.Length() != 3 {
+		t.Error("expected cursors at both positions:", f.MultiCursor.Length())
+	}
+	if r, c := f.GetRowCol(0); r != 4 || c != 1 {
+		t.Error("wrong cursor position:", r, c)
+	}
+
+	for k := 0; k < 3; k++ {
+		f.ScrollDown()
+	}
+	if r := f.MultiCursor.GetRow(0); r != 3 {
+		t.Error("cursor should follow the scroll:", r)
+	}
+}
+
+func TestSyntaxStates(t *testing.T) {
+	lines := []string{"/*"}
+	for k := 0; k < 10000; k++ {
+		lines = append(lines, "x := 1")
+	}
+	name := filepath.Join(t.TempDir(), "a.go")
+	os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	flushChan := make(chan struct{}, 1)
+	screen := terminal.NewSimulationScreen(20, 5)
+	f := file.NewFile(name, flushChan, screen, config.Config{}, &wg)
+	wg.Wait()
+	select {
+	case <-flushChan:
+	default:
+	}
+
+	fg := func(row int) tcell.Color {
+		_, _, style, _ := screen.GetTcell().GetContent(screen.GutterWidth(), row)
+		fg, _, _ := style.Decompose()
+		return fg
+	}
+
+	// Jumping far down draws straight away, and again once the syntax
+	// states have been worked out in the background. Other flush requests
+	// (e.g. from git) may come first, so redraw until the screen is right.
+	redraw := func(ok func() bool, msg string) {
+		timeout := time.After(5 * time.Second)
+		for f.Flush(); !ok(); f.Flush() {
+			select {
+			case <-flushChan:
+			case <-timeout:
+				t.Fatal(msg, fg(0))
+			}
+		}
+	}
+	f.CursorGoTo(10000, 0)
+	redraw(func() bool { return fg(0) == theme.Fg("comment") },
+		"expected the end of the file to be in a comment, got")
+
+	// Edits invalidate the states.
+	f.CursorGoTo(0, 2)
+	f.InsertStr("*/")
+	f.CursorGoTo(10000, 0)
+	redraw(func() bool { return fg(0) != theme.Fg("comment") },
+		"expected the comment to be closed, got")
+}
+
+func TestChanges(t *testing.T) {
+	lines := []string{}
+	for k := 0; k < 20000; k++ {
+		lines = append(lines, fmt.Sprint("line ", k))
+	}
+	name := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	flushChan := make(chan struct{}, 1)
+	screen := terminal.NewSimulationScreen(20, 5)
+	f := file.NewFile(name, flushChan, screen, config.Config{}, &wg)
+	wg.Wait()
+	select {
+	case <-flushChan:
+	default:
+	}
+
+	// The diff of a long file is worked out in the background.
+	f.CursorGoTo(15000, 0)
+	f.InsertStr("x")
+	f.CursorGoTo(100, 0)
+	f.InsertStr("x")
+	f.Flush()
+	select {
+	case <-flushChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a flush request")
+	}
+
+	f.CursorGoTo(0, 0)
+	f.NextChange()
+	if row := f.MultiCursor.GetRow(0); row != 100 {
+		t.Error("expected the first change at row 100, got", row)
+	}
+	f.NextChange()
+	if row := f.MultiCursor.GetRow(0); row != 15000 {
+		t.Error("expected the next change at row 15000, got", row)
+	}
+	f.PrevChange()
+	if row := f.MultiCursor.GetRow(0); row != 100 {
+		t.Error("expected the previous change at row 100, got", row)
+	}
+}
+
+func TestPersistentHistory(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	path := filepath.Join(dir, "history.json.gz")
+	os.WriteFile(name, []byte("one\ntwo"), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.MultiCursor.Set(0, 3, 3)
+	f.InsertStr("!")
+	f.ForceSnapshot()
+	f.InsertStr("?")
+	f.ForceSnapshot()
+	if err := f.WriteHistory(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening the (unchanged) file brings back the undo history.
+	wg.Add(1)
+	f = file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	if err := f.ReadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	CheckBuffer(t, f, "one\ntwo", "reopened file")
+	for k := 0; k < 10; k++ {
+		f.Redo()
+	}
+	CheckBuffer(t, f, "one!?\ntwo", "redo after reopening")
+	f.UndoSaved()
+	CheckBuffer(t, f, "one\ntwo", "undo to saved state")
+
+	// A history for other contents is not used.
+	os.WriteFile(name, []byte("one\nthree"), 0644)
+	wg.Add(1)
+	f = file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	if err := f.ReadHistory(path); err == nil {
+		t.Error("history should not match changed file")
+	}
+	f.Redo()
+	CheckBuffer(t, f, "one\nthree", "changed file")
+}
+
+func TestPauseHistory(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile("", make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.InsertStr("a")
+	f.ForceSnapshot()
+
+	// Edits made while paused are undone in one step.
+	f.PauseHistory()
+	f.InsertStr("b")
+	f.ForceSnapshot()
+	f.InsertStr("c")
+	f.Snapshot()
+	f.FlushHistory()
+	f.ResumeHistory()
+	CheckBuffer(t, f, "abc", "paused edits")
+	f.Undo()
+	CheckBuffer(t, f, "a", "undo paused edits")
+	f.Redo()
+	CheckBuffer(t, f, "abc", "redo paused edits")
+}
+
+func TestSwap(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("hello"), 0644)
+	path := file.SwapPath(name)
+
+	// An open file has a swap file, which holds unsaved changes.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	swap, err := file.ReadSwap(path)
+	if err != nil || swap.PID != os.Getpid() || swap.Modified || swap.Locked() {
+		t.Fatalf("bad swap file: %+v, %v", swap, err)
+	}
+	f.InsertStr("X")
+	f.WriteSwap()
+	swap, _ = file.ReadSwap(path)
+	if !swap.Modified || swap.Text != "Xhello" {
+		t.Errorf("swap file should hold the changes: %+v", swap)
+	}
+
+	// After a crash (the owner is gone), the changes can be recovered.
+	swap.PID = 1 << 30
+	swap.Write(path)
+	wg.Add(1)
+	f = file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	found := f.FoundSwap()
+	if found == nil || found.Locked() {
+		t.Fatalf("should find a recoverable swap file: %+v", found)
+	}
+	CheckBuffer(t, f, "hello", "before recovery")
+	f.RecoverSwap()
+	CheckBuffer(t, f, "Xhello", "after recovery")
+	if !f.IsModified() {
+		t.Error("recovered file should be modified")
+	}
+	f.RemoveSwap()
+	if _, err := os.Stat(path); err == nil {
+		t.Error("swap file should be removed")
+	}
+
+	// A swap file owned by another running process is a lock.
+	swap.PID = os.Getppid()
+	swap.Modified = false
+	swap.Write(path)
+	wg.Add(1)
+	f = file.NewFile(name, make(chan struct{}), nil, config.Config{}, &wg)
+	wg.Wait()
+	if found := f.FoundSwap(); found == nil || !found.Locked() {
+		t.Errorf("swap file should be locked: %+v", found)
+	}
+	f.RemoveSwap()
+	if _, err := os.Stat(path); err != nil {
+		t.Error("another process's swap file should be left alone")
+	}
+}
+
+// BenchmarkLargeFile jumps to the end of a 200k-line file, after an edit at
+// the top (which invalidates all the syntax states), and types there. The
+// edit at the top is taken back out again, so that only the syntax states
+// (and not the diff against the saved file) are affected by it.
+func BenchmarkLargeFile(b *testing.B) {
+	lines := []string{}
+	for k := 0; k < 20000; k++ {
+		lines = append(lines,
+			"/* A comment",
+			"   spanning lines. */",
+			"func f(x int) string {",
+			"\tif x > 0 {",
+			"\t\treturn `raw`",
+			"\t}",
+			"\treturn \"string\" // comment",
+			"}",
+			"",
+			"var y = 42",
+		)
+	}
+	name := filepath.Join(b.TempDir(), "large.go")
+	os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile(name, make(chan struct{}, 1), terminal.NewSimulationScreen(80, 40), config.Config{}, &wg)
+	wg.Wait()
+	end := len(lines) - 1
+
+	b.Run("jump", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f.CursorGoTo(0, 0)
+			f.InsertChar('x')
+			f.Backspace()
+			f.Flush()
+			f.CursorGoTo(end, 0)
+			f.Flush()
+		}
+	})
+	b.Run("type", func(b *testing.B) {
+		f.CursorGoTo(end, 0)
+		f.Flush()
+		for i := 0; i < b.N; i++ {
+			f.InsertChar('x')
+			f.Flush()
+		}
+	})
+}
+
+// statusLine returns the text on a row of the screen.
+func statusLine(screen *terminal.Screen, row int) string {
+	cols, _ := screen.Size()
+	str := ""
+	for col := 0; col < cols; col++ {
+		r, _, _, _ := screen.GetTcell().GetContent(col, row)
+		str += string(r)
+	}
+	return str
+}
+
+func TestLargeFile(t *testing.T) {
+	lines := []string{}
+	for k := 0; k < 200000; k++ {
+		lines = append(lines, fmt.Sprint("line ", k))
+	}
+	name := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	flushChan := make(chan struct{}, 1)
+	screen := terminal.NewSimulationScreen(40, 5)
+	cfg := config.Config{LargeFile: 1, LargeFile_set: true}
+	f := file.NewFile(name, flushChan, screen, cfg, &wg)
+	wg.Wait()
+	if !f.Large() || !f.ReadOnly() {
+		t.Fatal("expected a large, read-only file")
+	}
+
+	// The rest of the file is read in the background.
+	deadline := time.After(10 * time.Second)
+	for f.Loading() {
+		select {
+		case <-flushChan:
+		case <-deadline:
+			t.Fatal("file did not finish loading")
+		}
+	}
+	if f.Length() != len(lines) || f.GetLine(len(lines)-1) != lines[len(lines)-1] {
+		t.Fatal("file not read in:", f.Length())
+	}
+	if f.IsModified() {
+		t.Error("file should not be modified")
+	}
+	f.WriteStatus(4, 40)
+	if status := statusLine(screen, 4); !strings.Contains(status, "LARGE RO") {
+		t.Errorf("expected the mode in the status line, got %q", status)
+	}
+
+	f.InsertStr("x")
+	if f.GetLine(0) != "line 0" {
+		t.Error("read-only file was edited")
+	}
+	f.ToggleReadOnly()
+	f.InsertStr("x")
+	if f.GetLine(0) != "xline 0" || !f.IsModified() {
+		t.Error("expected an editable file")
+	}
+	f.ForceSnapshot()
+	f.Undo()
+	if f.IsModified() {
+		t.Error("undo should go back to the file as read in")
+	}
+}
+
+func TestBinaryFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.bin")
+	os.WriteFile(name, []byte("abc\x00\x01\x02"), 0644)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	screen := terminal.NewSimulationScreen(40, 5)
+	f := file.NewFile(name, make(chan struct{}, 1), screen, config.Config{}, &wg)
+	wg.Wait()
+	if !f.HexView() || !f.ReadOnly() {
+		t.Fatal("expected a read-only hex view")
+	}
+	expected := "00000000  61 62 63 00 01 02"
+	if line := f.GetLine(0); !strings.HasPrefix(line, expected) || !strings.HasSuffix(line, "|abc...|") {
+		t.Errorf("wrong hex dump: %q", line)
+	}
+
+	f.ToggleReadOnly()
+	f.InsertStr("x")
+	if !f.ReadOnly() || strings.HasPrefix(f.GetLine(0), "x") {
+		t.Error("hex view should stay read-only")
+	}
+	f.WriteStatus(4, 40)
+	if status := statusLine(screen, 4); !strings.Contains(status, "HEX RO") {
+		t.Errorf("expected the mode in the status line, got %q", status)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	cfg := config.Config{TabWidth: 4, TabWidth_set: true, BuildCmd: "make", BuildCmd_set: true}
+	screen := terminal.NewSimulationScreen(20, 5)
+	f := file.NewFile("", make(chan struct{}, 1), screen, cfg, &wg)
+	wg.Wait()
+	if f.BuildCmd() != "make" || f.TestCmd() != "" {
+		t.Errorf("wrong commands: %q, %q", f.BuildCmd(), f.TestCmd())
+	}
+	f.InsertStr("one")
+	f.Newline()
+	f.InsertStr("two")
+
+	marker := func(row int) rune {
+		r, _, _, _ := screen.GetTcell().GetContent(0, row)
+		return r
+	}
+	f.SetBuildErrors(map[int]string{1: "oops"})
+	f.Flush()
+	if marker(0) == '✖' || marker(1) != '✖' {
+		t.Errorf("expected a marker on the second row only: %q, %q", marker(0), marker(1))
+	}
+	f.SetBuildErrors(nil)
+	f.Flush()
+	if marker(1) == '✖' {
+		t.Error("expected the marker to be cleared")
+	}
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/terminal"
+)
+
+// RunShell runs a shell command with input on its standard input, and
+// returns its standard output, along with the first line of its standard
+// error. If the command fails, the error says why: the exit status and
+// standard error, or that it timed out or was cancelled.
+func RunShell(ctx context.Context, command, input string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait long for any background processes holding the output open.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = errors.New("timed out")
+	case errors.Is(ctx.Err(), context.Canceled):
+		err = errors.New("cancelled")
+	case err != nil && msg != "":
+		err = fmt.Errorf("%v: %s", err, msg)
+	}
+	return stdout.String(), msg, err
+}
+
+// Filter pipes text through a command, and replaces it with the output:
+// the selected lines, or each cursor's line (if there are several
+// cursors), or else the whole buffer. run runs the command on a piece of
+// text. If the command fails on any piece, nothing is changed.
+func (file *File) Filter(run func(input string) (string, error)) error {
+	if !file.writable() {
+		return errReadOnly
+	}
+
+	// Spans of rows (inclusive), in order.
+	spans := [][2]int{}
+	switch {
+	case file.HasSelection():
+		start, end := file.selectedRows()
+		spans = append(spans, [2]int{start, end})
+	case file.MultiCursor.Length() > 1:
+		for row := range file.MultiCursor.GetRowsCols() {
+			spans = append(spans, [2]int{row, row})
+		}
+		sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	default:
+		spans = append(spans, [2]int{0, file.buffer.Length() - 1})
+	}
+
+	outputs := make([][]buffer.Line, len(spans))
+	for k, span := range spans {
+		strs := []string{}
+		for row := span[0]; row <= span[1]; row++ {
+			strs = append(strs, file.buffer.GetRowDirect(row).ToString())
+		}
+		out, err := run(strings.Join(strs, "\n") + "\n")
+		if err != nil {
+			return err
+		}
+		out = strings.TrimSuffix(strings.ReplaceAll(out, "\r\n", "\n"), "\n")
+		for _, str := range strings.Split(out, "\n") {
+			outputs[k] = append(outputs[k], buffer.MakeLine(str))
+		}
+	}
+
+	// Work from the bottom up, so that the rows above stay put.
+	for k := len(spans) - 1; k >= 0; k-- {
+		file.buffer.ReplaceLines(outputs[k], spans[k][0], spans[k][1])
+	}
+	file.ClearSelection()
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	file.Snapshot()
+	return nil
+}
+
+// NewScratch creates a read-only file holding some text (such as the output
+// of a command). It isn't read from or saved to disk, and the title stands
+// in for its name.
+func NewScratch(title, text string, flushChan chan struct{}, screen *terminal.Screen,
+	cfg config.Config) *File {
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	file := NewFile("", flushChan, screen, cfg, &wg)
+	wg.Wait()
+	file.Name = title
+	file.scratch = true
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(lines))
+	file.savedBuffer.ReplaceBuffer(file.buffer.Dup())
+	file.buffHist.Reset(file.buffer, file.MultiCursor)
+	file.safe.mutex.Lock()
+	file.safe.readOnly = true
+	file.safe.mutex.Unlock()
+	return file
+}
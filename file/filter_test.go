@@ -0,0 +1,98 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/terminal"
+)
+
+func TestRunShell(t *testing.T) {
+	ctx := context.Background()
+	out, stderr, err := file.RunShell(ctx, "tr a-z A-Z; echo note >&2", "abc\n")
+	if err != nil || out != "ABC\n" || stderr != "note" {
+		t.Errorf("unexpected result: %q, %q, %v", out, stderr, err)
+	}
+
+	_, _, err = file.RunShell(ctx, "echo oops >&2; exit 3", "")
+	if err == nil || err.Error() != "exit status 3: oops" {
+		t.Error("expected the exit status and stderr, got", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = file.RunShell(ctx, "sleep 10", "")
+	if err == nil || err.Error() != "timed out" || time.Since(start) > 5*time.Second {
+		t.Error("expected a timeout, got", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	screen := terminal.NewSimulationScreen(20, 10)
+	f := file.NewFile("", make(chan struct{}), screen, config.Config{}, &wg)
+	wg.Wait()
+	for _, str := range []string{"c", "b", "a", "x"} {
+		f.InsertStr(str)
+		f.Newline()
+	}
+	upper := func(input string) (string, error) {
+		return strings.ToUpper(input), nil
+	}
+
+	// The selected lines.
+	f.CursorGoTo(0, 0)
+	f.ToggleMark()
+	f.CursorDown(2)
+	if err := f.Filter(upper); err != nil {
+		t.Fatal(err)
+	}
+	CheckBuffer(t, f, "C\nB\na\nx\n", "filter the selection")
+
+	// Each cursor's line.
+	f.MultiCursor.Set(2, 0, 0)
+	f.AddCursor()
+	f.MultiCursor.Set(0, 0, 0)
+	f.AddCursor()
+	f.Filter(func(input string) (string, error) {
+		return input + input, nil
+	})
+	CheckBuffer(t, f, "C\nC\nB\na\na\nx\n", "filter each cursor's line")
+	f.ClearCursors()
+
+	// The whole buffer.
+	f.Filter(func(input string) (string, error) {
+		out, _, err := file.RunShell(context.Background(), "LC_ALL=C sort -u", input)
+		return out, err
+	})
+	CheckBuffer(t, f, "\nB\nC\na\nx", "filter the buffer")
+}
+
+func TestScratch(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 5)
+	f := file.NewScratch("[ls]", "one\ntwo\n", make(chan struct{}, 1), screen, config.Config{})
+	CheckBuffer(t, f, "one\ntwo", "scratch buffer")
+	if !f.ReadOnly() || f.IsModified() {
+		t.Error("scratch buffer should be read-only and unmodified")
+	}
+	f.InsertStr("x")
+	CheckBuffer(t, f, "one\ntwo", "edit a scratch buffer")
+
+	// It stays read-only, and is never saved.
+	f.ToggleReadOnly()
+	f.InsertStr("x")
+	CheckBuffer(t, f, "one\ntwo", "edit after toggling read-only")
+	f.Save()
+	if _, err := os.Stat("[ls]"); err == nil {
+		os.Remove("[ls]")
+		t.Error("scratch buffer should not be saved")
+	}
+}
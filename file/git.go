@@ -0,0 +1,242 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/git"
+	"github.com/wx13/sith/terminal"
+	"github.com/wx13/sith/theme"
+)
+
+// gitState is the file's version in git, from HEAD or the index, and the
+// diff from it to the buffer.
+type gitState struct {
+	base    buffer.Buffer
+	tracked bool
+	index   bool
+	diff    *changeDiff
+	mutex   sync.Mutex
+}
+
+// newGitState creates the (untracked) git state for a buffer.
+func newGitState(buff *buffer.Buffer) *gitState {
+	gs := &gitState{base: buffer.MakeBuffer([]string{""})}
+	gs.diff = newChangeDiff(buff, &gs.base)
+	return gs
+}
+
+// RefreshGit re-reads the file's version in git (from HEAD, or from the
+// index), which the gutter markers and hunks are relative to. Files which
+// are not tracked by git have no markers.
+func (file *File) RefreshGit() {
+	file.gitInfo.mutex.Lock()
+	index := file.gitInfo.index
+	file.gitInfo.mutex.Unlock()
+
+	tracked := false
+	if file.Name != "" && !file.Large() && !file.scratch {
+		text, err := git.Show(file.Name, index)
+		if err == nil {
+			// Replacing the lines moves the base on to a new version, so
+			// the cached diff is worked out again.
+			file.gitInfo.base.ReplaceBuffer(buffer.MakeBuffer(strings.Split(text, file.newline)))
+			tracked = true
+		}
+	}
+
+	file.gitInfo.mutex.Lock()
+	file.gitInfo.tracked = tracked
+	file.gitInfo.mutex.Unlock()
+	file.RequestFlush()
+}
+
+// ToggleGitBase switches the gutter markers and hunks between being
+// relative to HEAD and relative to the index.
+func (file *File) ToggleGitBase() {
+	file.gitInfo.mutex.Lock()
+	file.gitInfo.index = !file.gitInfo.index
+	index := file.gitInfo.index
+	file.gitInfo.mutex.Unlock()
+	file.RefreshGit()
+	if index {
+		file.NotifyUser("Diffing against the git index")
+	} else {
+		file.NotifyUser("Diffing against git HEAD")
+	}
+}
+
+// GitHunks returns the changes from the git version of the file to the
+// buffer, or nil if the file is not tracked by git. The hunks are cached
+// until either changes.
+func (file *File) GitHunks() []buffer.Hunk {
+	if !file.gitTracked() {
+		return nil
+	}
+	return file.gitInfo.diff.getHunks()
+}
+
+// gitTracked returns true if the file has a version in git.
+func (file *File) gitTracked() bool {
+	file.gitInfo.mutex.Lock()
+	defer file.gitInfo.mutex.Unlock()
+	return file.gitInfo.tracked
+}
+
+// gitHunkAt returns the hunk containing the cursor.
+func (file *File) gitHunkAt(hunks []buffer.Hunk) (buffer.Hunk, bool) {
+	row := file.MultiCursor.GetRow(0)
+	for _, hunk := range hunks {
+		if hunk.Contains(row) {
+			return hunk, true
+		}
+	}
+	return buffer.Hunk{}, false
+}
+
+// gitMarkers maps rows to gutter markers for the git hunks. It is called
+// while drawing the screen, so it uses the cached hunks (see
+// changeDiff.latest).
+func (file *File) gitMarkers() map[int]rune {
+	markers := map[int]rune{}
+	if !file.gitTracked() {
+		return markers
+	}
+	for _, hunk := range file.gitInfo.diff.latestHunks(file.RequestFlush) {
+		switch {
+		case hunk.Count == 0:
+			row := hunk.Start
+			if row >= file.buffer.Length() {
+				row = file.buffer.Length() - 1
+			}
+			markers[row] = '-'
+		case len(hunk.OldLines) == 0:
+			for row := hunk.Start; row < hunk.Start+hunk.Count; row++ {
+				markers[row] = '+'
+			}
+		default:
+			for row := hunk.Start; row < hunk.Start+hunk.Count; row++ {
+				markers[row] = '~'
+			}
+		}
+	}
+	return markers
+}
+
+// drawGitMarker draws a git change marker in the gutter.
+func (file *File) drawGitMarker(row int, marker rune) {
+	switch marker {
+	case '+':
+		file.screen.DrawGutterSymbol(row, '+', theme.Fg("gutter.added"))
+	case '-':
+		file.screen.DrawGutterSymbol(row, '-', theme.Fg("gutter.deleted"))
+	default:
+		file.screen.DrawGutterSymbol(row, '~', theme.Fg("gutter.modified"))
+	}
+}
+
+// NextHunk moves the cursor to the next git hunk.
+func (file *File) NextHunk() {
+	file.gotoHunk(1)
+}
+
+// PrevHunk moves the cursor to the previous git hunk.
+func (file *File) PrevHunk() {
+	file.gotoHunk(-1)
+}
+
+func (file *File) gotoHunk(direction int) {
+	hunks := file.GitHunks()
+	if len(hunks) == 0 {
+		file.NotifyUser("No git changes")
+		return
+	}
+	starts := make([]int, len(hunks))
+	for i, hunk := range hunks {
+		starts[i] = hunk.Start
+	}
+	currentRow := file.MultiCursor.GetRow(0)
+	file.CursorGoTo(nextPoint(starts, currentRow, direction), 0)
+}
+
+// RevertHunk replaces the git hunk at the cursor with the git version.
+func (file *File) RevertHunk() {
+	if !file.writable() {
+		return
+	}
+	hunk, ok := file.gitHunkAt(file.GitHunks())
+	if !ok {
+		file.NotifyUser("No git changes at cursor")
+		return
+	}
+	lines := file.buffer.Strings()
+	reverted := append([]string{}, lines[:hunk.Start]...)
+	reverted = append(reverted, hunk.OldLines...)
+	reverted = append(reverted, lines[hunk.Start+hunk.Count:]...)
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(reverted))
+	file.MultiCursor.Set(hunk.Start, 0, 0)
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	file.Snapshot()
+}
+
+// StageHunk stages the change at the cursor (relative to the index), as it
+// is in the buffer, whether or not the buffer has been saved.
+func (file *File) StageHunk() {
+	text, err := git.Show(file.Name, true)
+	if err != nil {
+		file.NotifyUser(err.Error())
+		return
+	}
+	indexLines := strings.Split(text, file.newline)
+	index := buffer.MakeBuffer(indexLines)
+	hunk, ok := file.gitHunkAt(file.buffer.DiffHunks(&index))
+	if !ok {
+		file.NotifyUser("No unstaged changes at cursor")
+		return
+	}
+	lines := file.buffer.Strings()
+	staged := append([]string{}, indexLines[:hunk.OldStart]...)
+	staged = append(staged, lines[hunk.Start:hunk.Start+hunk.Count]...)
+	staged = append(staged, indexLines[hunk.OldStart+len(hunk.OldLines):]...)
+	if err := git.Stage(file.Name, strings.Join(staged, file.newline)); err != nil {
+		file.NotifyUser("Stage failed: " + err.Error())
+		return
+	}
+	file.NotifyUser("Staged hunk")
+	file.RefreshGit()
+}
+
+// Blame returns the commit which last changed the line at the cursor.
+func (file *File) Blame() (git.BlameInfo, error) {
+	row := file.MultiCursor.GetRow(0)
+	return git.Blame(file.Name, row, file.ToString())
+}
+
+// ShowBlame shows, in a popup, the commit which last changed the line at
+// the cursor.
+func (file *File) ShowBlame() {
+	info, err := file.Blame()
+	if err != nil {
+		file.NotifyUser(err.Error())
+		return
+	}
+	row := file.MultiCursor.GetRow(0)
+	heading, _ := terminal.RoleColors("popup.heading")
+	lines := []string{info.String()}
+	colors := []terminal.Attribute{heading}
+	if !info.Uncommitted() {
+		lines = []string{
+			"commit " + info.Commit,
+			"Author: " + info.Author,
+			"Date:   " + info.Time.Format("Mon Jan 2 15:04:05 2006"),
+			"",
+			"    " + info.Summary,
+		}
+		colors = []terminal.Attribute{heading, terminal.ColorDefault,
+			terminal.ColorDefault, terminal.ColorDefault, terminal.ColorDefault}
+	}
+	file.showBox(fmt.Sprintf(" Blame: line %d ", row+1), lines, colors)
+}
@@ -0,0 +1,78 @@
+package file_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file"
+	"github.com/wx13/sith/git"
+)
+
+func TestGitHunks(t *testing.T) {
+	if !git.Available() {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	gitCmd := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	gitCmd("init", "-q")
+	gitCmd("config", "user.name", "Tester")
+	gitCmd("config", "user.email", "tester@example.com")
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("one\ntwo\nthree\nfour\n"), 0644)
+	gitCmd("add", "a.txt")
+	gitCmd("commit", "-q", "-m", "Initial commit")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile(name, make(chan struct{}, 1), nil, config.Config{}, &wg)
+	wg.Wait()
+	f.RefreshGit()
+	if hunks := f.GitHunks(); len(hunks) != 0 {
+		t.Fatalf("expected no hunks, got %+v", hunks)
+	}
+
+	// Change two separate lines.
+	f.MultiCursor.Set(0, 0, 0)
+	f.InsertStr("1")
+	f.MultiCursor.Set(2, 0, 0)
+	f.InsertStr("3")
+	if hunks := f.GitHunks(); len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %+v", hunks)
+	}
+
+	// Stage the first, and revert the second.
+	f.MultiCursor.Set(0, 0, 0)
+	f.StageHunk()
+	index, _ := git.Show(name, true)
+	if index != "1one\ntwo\nthree\nfour\n" {
+		t.Errorf("wrong staged contents: %q", index)
+	}
+	f.MultiCursor.Set(2, 0, 0)
+	f.RevertHunk()
+	CheckBuffer(t, f, "1one\ntwo\nthree\nfour\n", "RevertHunk")
+
+	// The remaining (staged) change is only relative to HEAD.
+	if hunks := f.GitHunks(); len(hunks) != 1 || hunks[0].Start != 0 {
+		t.Errorf("expected one hunk, got %+v", hunks)
+	}
+	f.ToggleGitBase()
+	if hunks := f.GitHunks(); len(hunks) != 0 {
+		t.Errorf("expected no hunks relative to the index, got %+v", hunks)
+	}
+
+	f.MultiCursor.Set(0, 0, 0)
+	info, err := f.Blame()
+	if err != nil || !info.Uncommitted() {
+		t.Errorf("line 1 should be uncommitted: %+v, %v", info, err)
+	}
+}
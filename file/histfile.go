@@ -0,0 +1,321 @@
+package file
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/file/cursor"
+)
+
+const (
+	historyDir      = "undo"
+	maxHistoryAge   = 90 * 24 * time.Hour // 90 days
+	maxHistoryCount = 500
+)
+
+// histDiff is a buffer state stored as a change to the previous state:
+// Delete lines starting at Start are replaced by Insert.
+type histDiff struct {
+	Start     int       `json:"s"`
+	Delete    int       `json:"d"`
+	Insert    []string  `json:"i,omitempty"`
+	Row       int       `json:"r"`
+	Col       int       `json:"c"`
+	Saved     bool      `json:"saved,omitempty"`
+	Timestamp time.Time `json:"t"`
+}
+
+// histFile is the on-disk form of a buffer history.
+type histFile struct {
+	Path    string     `json:"path"`
+	MD5     string     `json:"md5"`
+	Current int        `json:"current"`
+	States  []histDiff `json:"states"`
+}
+
+// diffLines computes the change from one list of lines to another, by
+// trimming the common prefix and suffix.
+func diffLines(from, to []string) histDiff {
+	start := 0
+	for start < len(from) && start < len(to) && from[start] == to[start] {
+		start++
+	}
+	end := 0
+	for end < len(from)-start && end < len(to)-start &&
+		from[len(from)-1-end] == to[len(to)-1-end] {
+		end++
+	}
+	return histDiff{
+		Start:  start,
+		Delete: len(from) - start - end,
+		Insert: append([]string{}, to[start:len(to)-end]...),
+	}
+}
+
+// apply applies the change to a list of lines.
+func (diff histDiff) apply(lines []string) ([]string, error) {
+	if diff.Start < 0 || diff.Delete < 0 || diff.Start+diff.Delete > len(lines) {
+		return nil, errors.New("corrupt history")
+	}
+	result := append([]string{}, lines[:diff.Start]...)
+	result = append(result, diff.Insert...)
+	return append(result, lines[diff.Start+diff.Delete:]...), nil
+}
+
+// bufferLines returns the lines of a buffer as strings.
+func bufferLines(buff buffer.Buffer) []string {
+	lines := []string{}
+	for _, line := range buff.Lines() {
+		lines = append(lines, line.ToString())
+	}
+	return lines
+}
+
+// encode converts the history into a list of diffs, and returns the index
+// of the current state.
+func (bh *BufferHist) encode() ([]histDiff, int) {
+	bh.elemMutex.Lock()
+	defer bh.elemMutex.Unlock()
+	diffs := []histDiff{}
+	current := 0
+	prev := []string{}
+	for el := bh.list.Front(); el != nil; el = el.Next() {
+		state := el.Value.(*BufferState)
+		lines := bufferLines(state.buff)
+		diff := diffLines(prev, lines)
+		diff.Row, diff.Col = state.mc.GetRowCol(0)
+		diff.Saved = state.saved
+		diff.Timestamp = state.timestamp
+		if el == bh.element {
+			current = len(diffs)
+		}
+		diffs = append(diffs, diff)
+		prev = lines
+	}
+	return diffs, current
+}
+
+// decode replaces the history with a list of diffs. The current state will
+// be the requested one if its contents match, or else the last saved
+// state whose contents match.
+func (bh *BufferHist) decode(diffs []histDiff, current int, match func([]string) bool) error {
+	states := []*BufferState{}
+	lines := []string{}
+	for _, diff := range diffs {
+		var err error
+		lines, err = diff.apply(lines)
+		if err != nil {
+			return err
+		}
+		mc := cursor.MakeMultiCursor()
+		mc.Set(diff.Row, diff.Col, diff.Col)
+		states = append(states, &BufferState{
+			buff:      buffer.MakeBuffer(lines),
+			mc:        mc,
+			saved:     diff.Saved,
+			timestamp: diff.Timestamp,
+		})
+	}
+
+	matchIdx := -1
+	if current >= 0 && current < len(states) && match(bufferLines(states[current].buff)) {
+		matchIdx = current
+	} else {
+		for idx := len(states) - 1; idx >= 0; idx-- {
+			if states[idx].saved && match(bufferLines(states[idx].buff)) {
+				matchIdx = idx
+				break
+			}
+		}
+	}
+	if matchIdx < 0 {
+		return errors.New("history does not match file")
+	}
+
+	bh.elemMutex.Lock()
+	defer bh.elemMutex.Unlock()
+	bh.list.Init()
+	for idx, state := range states {
+		el := bh.list.PushBack(state)
+		if idx == matchIdx {
+			bh.element = el
+		}
+	}
+	return nil
+}
+
+// stateDirOverride replaces the config directory as the home of swap and
+// undo history files (for testing).
+var stateDirOverride string
+
+// SetStateDir sets the directory for swap and undo history files, which
+// by default is config.ConfigDir().
+func SetStateDir(dir string) {
+	stateDirOverride = dir
+}
+
+// stateDir returns the directory for swap and undo history files.
+func stateDir() string {
+	if stateDirOverride != "" {
+		return stateDirOverride
+	}
+	return config.ConfigDir()
+}
+
+// HistoryPath returns where the history of a file is stored, given the
+// checksum of its contents on disk. It returns an empty string if there is
+// no config directory.
+func HistoryPath(name string, md5sum [16]byte) string {
+	dir := stateDir()
+	if dir == "" || name == "" {
+		return ""
+	}
+	return filepath.Join(dir, historyDir, historyPrefix(name)+hex.EncodeToString(md5sum[:])+".json.gz")
+}
+
+// historyPrefix returns the history file prefix for a file path.
+func historyPrefix(name string) string {
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		absName = name
+	}
+	hash := sha256.Sum256([]byte(absName))
+	return hex.EncodeToString(hash[:8]) + "-"
+}
+
+// WriteHistory writes the buffer history to path.
+func (file *File) WriteHistory(path string) error {
+	if file.buffHist == nil {
+		return errors.New("no history")
+	}
+	file.buffHist.takeRequested()
+	absName, _ := filepath.Abs(file.Name)
+	diffs, current := file.buffHist.encode()
+	hf := histFile{
+		Path:    absName,
+		MD5:     hex.EncodeToString(file.md5sum[:]),
+		Current: current,
+		States:  diffs,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(hf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadHistory replaces the buffer history with one read from path. The
+// history must belong to this file, and must have a state which matches
+// the file's contents on disk.
+func (file *File) ReadHistory(path string) error {
+	if file.buffHist == nil {
+		return errors.New("no history")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	hf := histFile{}
+	if err := json.NewDecoder(zr).Decode(&hf); err != nil {
+		return err
+	}
+
+	absName, _ := filepath.Abs(file.Name)
+	if hf.Path != absName || hf.MD5 != hex.EncodeToString(file.md5sum[:]) {
+		return errors.New("history does not match file")
+	}
+	match := func(lines []string) bool {
+		return md5.Sum([]byte(strings.Join(lines, file.newline))) == file.md5sum
+	}
+	return file.buffHist.decode(hf.States, hf.Current, match)
+}
+
+// SaveHistory persists the buffer history, so that it survives restarts.
+// Histories for older versions of the file are removed.
+func (file *File) SaveHistory() {
+	path := HistoryPath(file.Name, file.md5sum)
+	if path == "" || file.Large() || file.scratch {
+		return
+	}
+	old, _ := filepath.Glob(filepath.Join(filepath.Dir(path), historyPrefix(file.Name)+"*"))
+	for _, p := range old {
+		if p != path {
+			os.Remove(p)
+		}
+	}
+	if file.WriteHistory(path) == nil {
+		cleanupHistories(filepath.Dir(path))
+	}
+}
+
+// LoadHistory restores the persisted buffer history, if there is one for
+// the file's current contents.
+func (file *File) LoadHistory() bool {
+	path := HistoryPath(file.Name, file.md5sum)
+	if path == "" || file.Large() {
+		return false
+	}
+	return file.ReadHistory(path) == nil
+}
+
+// cleanupHistories removes old history files.
+func cleanupHistories(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type histEntry struct {
+		path    string
+		modTime time.Time
+	}
+
+	var hists []histEntry
+	now := time.Now()
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if now.Sub(info.ModTime()) > maxHistoryAge {
+			os.Remove(path)
+			continue
+		}
+		hists = append(hists, histEntry{path: path, modTime: info.ModTime()})
+	}
+
+	if len(hists) > maxHistoryCount {
+		sort.Slice(hists, func(i, j int) bool {
+			return hists[i].modTime.Before(hists[j].modTime)
+		})
+		for i := 0; i < len(hists)-maxHistoryCount; i++ {
+			os.Remove(hists[i].path)
+		}
+	}
+}
@@ -138,6 +138,8 @@ func (file *File) showDiffPreview(state StateInfo, keyboard *terminal.Keyboard)
 	// Build diff lines
 	diffLines := []string{}
 	diffColors := []terminal.Attribute{}
+	removed, _ := terminal.RoleColors("diff.removed")
+	added, _ := terminal.RoleColors("diff.added")
 
 	if len(removals) == 0 && len(additions) == 0 {
 		diffLines = append(diffLines, "(no line changes)")
@@ -150,7 +152,7 @@ func (file *File) showDiffPreview(state StateInfo, keyboard *terminal.Keyboard)
 			display = display[:57] + "..."
 		}
 		diffLines = append(diffLines, display)
-		diffColors = append(diffColors, terminal.ColorRed)
+		diffColors = append(diffColors, removed)
 	}
 
 	for _, line := range additions {
@@ -159,7 +161,7 @@ func (file *File) showDiffPreview(state StateInfo, keyboard *terminal.Keyboard)
 			display = display[:57] + "..."
 		}
 		diffLines = append(diffLines, display)
-		diffColors = append(diffColors, terminal.ColorGreen)
+		diffColors = append(diffColors, added)
 	}
 
 	// Display parameters
@@ -221,7 +223,8 @@ func (file *File) showDiffPreview(state StateInfo, keyboard *terminal.Keyboard)
 func (file *File) drawDiffBox(row0, col0, height, width int, state StateInfo,
 	lines []string, colors []terminal.Attribute, scroll int) {
 
-	borderColor := terminal.ColorBlue
+	borderColor, _ := terminal.RoleColors("border")
+	titleColor, _ := terminal.RoleColors("title")
 
 	// Title
 	title := fmt.Sprintf(" Preview: revert to %s ", file.formatTimestamp(state.Timestamp))
@@ -232,7 +235,7 @@ func (file *File) drawDiffBox(row0, col0, height, width int, state StateInfo,
 	for len(topBorder) < width {
 		topBorder += "-"
 	}
-	file.screen.WriteStringColor(row0, col0, topBorder, terminal.ColorWhite|terminal.AttrBold, borderColor)
+	file.screen.WriteStringColor(row0, col0, topBorder, titleColor, borderColor)
 
 	// Content area
 	contentHeight := height - 4
@@ -275,7 +278,7 @@ func (file *File) drawDiffBox(row0, col0, height, width int, state StateInfo,
 	for len(bottomBorder) < width {
 		bottomBorder += "-"
 	}
-	file.screen.WriteStringColor(row0+height-3, col0, bottomBorder, terminal.ColorWhite, borderColor)
+	file.screen.WriteStringColor(row0+height-3, col0, bottomBorder, titleColor, borderColor)
 }
 
 // clearBox clears the diff preview box area.
@@ -289,8 +292,12 @@ func (file *File) clearBox(row0, col0, height, width int) {
 
 // ShowLineDiff shows a diff popup for the changed region around the cursor.
 func (file *File) ShowLineDiff() {
+	if file.Large() {
+		file.NotifyUser("No change tracking for large files")
+		return
+	}
 	// Get the set of changed lines
-	diffResult := file.buffer.DiffLinesFull(&file.savedBuffer)
+	diff, diffResult := file.changes.get()
 
 	changedLines := make(map[int]bool)
 	for lineNum := range diffResult.Changes {
@@ -322,7 +329,7 @@ func (file *File) ShowLineDiff() {
 	}
 
 	// Get the diff for this region
-	diffLines := file.buffer.GetRegionDiff(&file.savedBuffer, startRow, endRow)
+	diffLines := diff.Region(startRow, endRow)
 
 	if len(diffLines) == 0 {
 		file.NotifyUser("No diff to show")
@@ -341,15 +348,27 @@ func (file *File) ShowLineDiff() {
 			displayLines[i] = line
 			switch line[0] {
 			case '-':
-				lineColors[i] = terminal.ColorRed
+				lineColors[i], _ = terminal.RoleColors("diff.removed")
 			case '+':
-				lineColors[i] = terminal.ColorGreen
+				lineColors[i], _ = terminal.RoleColors("diff.added")
 			default:
 				lineColors[i] = terminal.ColorDefault
 			}
 		}
 	}
 
+	var title string
+	if startRow == endRow {
+		title = fmt.Sprintf(" Diff: line %d ", startRow+1)
+	} else {
+		title = fmt.Sprintf(" Diff: lines %d-%d ", startRow+1, endRow+1)
+	}
+	file.showBox(title, displayLines, lineColors)
+}
+
+// showBox shows lines of text in a scrollable popup box, until a key is
+// pressed.
+func (file *File) showBox(title string, displayLines []string, lineColors []terminal.Attribute) {
 	// Display in a popup box
 	keyboard := file.newKeyboard()
 
@@ -373,7 +392,7 @@ func (file *File) ShowLineDiff() {
 
 	for {
 		// Draw box
-		file.drawLineDiffBox(row0, col0, boxHeight, boxWidth, startRow, endRow, displayLines, lineColors, scroll)
+		file.drawLineDiffBox(row0, col0, boxHeight, boxWidth, title, displayLines, lineColors, scroll)
 		file.screen.Flush()
 
 		// Get input
@@ -406,18 +425,13 @@ func (file *File) ShowLineDiff() {
 }
 
 // drawLineDiffBox draws the line diff popup box.
-func (file *File) drawLineDiffBox(row0, col0, height, width int, startLine, endLine int,
+func (file *File) drawLineDiffBox(row0, col0, height, width int, title string,
 	lines []string, colors []terminal.Attribute, scroll int) {
 
-	borderColor := terminal.ColorBlue
+	borderColor, _ := terminal.RoleColors("border")
+	titleColor, _ := terminal.RoleColors("title")
 
 	// Title
-	var title string
-	if startLine == endLine {
-		title = fmt.Sprintf(" Diff: line %d ", startLine+1)
-	} else {
-		title = fmt.Sprintf(" Diff: lines %d-%d ", startLine+1, endLine+1)
-	}
 	if len(title) > width-2 {
 		title = title[:width-2]
 	}
@@ -425,7 +439,7 @@ func (file *File) drawLineDiffBox(row0, col0, height, width int, startLine, endL
 	for len(topBorder) < width {
 		topBorder += "-"
 	}
-	file.screen.WriteStringColor(row0, col0, topBorder, terminal.ColorWhite|terminal.AttrBold, borderColor)
+	file.screen.WriteStringColor(row0, col0, topBorder, titleColor, borderColor)
 
 	// Content area
 	contentHeight := height - 4
@@ -468,5 +482,5 @@ func (file *File) drawLineDiffBox(row0, col0, height, width int, startLine, endL
 	for len(bottomBorder) < width {
 		bottomBorder += "-"
 	}
-	file.screen.WriteStringColor(row0+height-3, col0, bottomBorder, terminal.ColorWhite, borderColor)
+	file.screen.WriteStringColor(row0+height-3, col0, bottomBorder, titleColor, borderColor)
 }
@@ -7,20 +7,29 @@ import (
 	"sync"
 	"time"
 
-	"github.com/gdamore/tcell/v2"
 	"github.com/wx13/sith/file/buffer"
 	"github.com/wx13/sith/syntaxcolor"
+	"github.com/wx13/sith/theme"
 )
 
 // Flush writes the buffer contents to the screen.
 func (file *File) Flush() {
 	file.ComputeIndent()
+	file.screen.SetLineNumberWidth(file.lineNumberWidth())
 	cols, rows := file.screen.Size()
 	slice := file.Slice(rows-1, cols)
 	file.screen.Clear()
 
+	// Large files go without syntax coloring and change markers.
+	large := file.Large()
+
 	// Compute diff - any line that's changed or adjacent to a deletion gets marked
-	diffResult := file.buffer.DiffLinesFull(&file.savedBuffer)
+	var diffResult buffer.DiffResult
+	gitMarkers := map[int]rune{}
+	if !large {
+		diffResult = file.changes.latest(file.RequestFlush)
+		gitMarkers = file.gitMarkers()
+	}
 
 	// Build set of lines that have changes (added, modified, or adjacent to deletion)
 	changedLines := make(map[int]bool)
@@ -35,35 +44,69 @@ func (file *File) Flush() {
 		changedLines[delPoint+1] = true // line after deletion
 	}
 
-	// Ensure states are calculated for all lines before the visible area
-	file.ensureSyntaxStates(file.rowOffset)
+	diagnostics := file.diagnosticRows()
+	buildErrs := file.buildErrorRows()
 
+	// With soft wrapping, a buffer row can span several screen rows.
+	screenRows := file.screenRows(rows-1, cols)
+	bottom := file.rowOffset
+	if len(screenRows) > 0 {
+		bottom = screenRows[len(screenRows)-1].row
+	}
+	if !large {
+		file.syntax.prepare(file.rowOffset, bottom, file.tabWidth, file.RequestFlush)
+	}
+	var startState, endState syntaxcolor.LineState
+	var colors []syntaxcolor.LineColor
 	for row, str := range slice {
 		file.screen.WriteString(row, 0, str)
-		bufferRow := row + file.rowOffset
-		fullStr := file.buffer.GetRowDirect(bufferRow).Tabs2spaces(file.tabWidth).ToString()
+		sr := screenRows[row]
+		bufferRow := sr.row
+		first := row == 0 || screenRows[row-1].row != bufferRow
+
+		if first && !large {
+			fullStr := file.buffer.GetRowDirect(bufferRow).Tabs2spaces(file.tabWidth).ToString()
+
+			// If the start state is not known yet, carry on from the
+			// previous row.
+			var result syntaxcolor.ColorResult
+			result, startState = file.syntax.colorize(bufferRow, fullStr, endState)
+			endState = result.EndState
+			colors = result.Colors
+		}
 
-		// Get the start state for this line
-		startState := file.stateCache.GetState(bufferRow)
-		result := file.SyntaxRules.ColorizeWithState(fullStr, startState)
+		file.screen.Colorize(row, clipColors(colors, sr.start, sr.end), sr.start-sr.indent)
 
-		// Cache the end state
-		file.stateCache.SetEndState(bufferRow, result.EndState)
+		// Draw vertical bar for code blocks in gutter column 1 (for markdown files)
+		if file.SyntaxRules.IsMarkdown() {
+			if startState.IsCodeBlock() || endState.IsCodeBlock() {
+				file.screen.DrawLeftBar(row, theme.Fg("gutter.code"))
+			} else if startState.IsBlockEquation() || endState.IsBlockEquation() {
+				file.screen.DrawLeftBar(row, theme.Fg("gutter.math"))
+			}
+		}
 
-		file.screen.Colorize(row, result.Colors, file.colOffset)
+		if !first {
+			continue
+		}
+		file.drawLineNumber(row, bufferRow)
 
-		// Draw change indicator in gutter column 0
+		// Draw change indicators in gutter column 0. Unsaved changes take
+		// precedence over changes relative to git.
+		if marker, ok := gitMarkers[bufferRow]; ok {
+			file.drawGitMarker(row, marker)
+		}
 		if changedLines[bufferRow] {
-			file.screen.DrawGutterSymbol(row, '▸', tcell.ColorYellow)
+			file.screen.DrawGutterSymbol(row, '▸', theme.Fg("gutter.unsaved"))
 		}
 
-		// Draw vertical bar for code blocks in gutter column 1 (for markdown files)
-		if file.SyntaxRules.IsMarkdown() {
-			if startState.IsCodeBlock() || result.EndState.IsCodeBlock() {
-				file.screen.DrawLeftBar(row, tcell.ColorBlue)
-			} else if startState.IsBlockEquation() || result.EndState.IsBlockEquation() {
-				file.screen.DrawLeftBar(row, tcell.ColorPurple)
-			}
+		// Language server diagnostics take precedence over change
+		// indicators, and build errors over both.
+		if diag, ok := diagnostics[bufferRow]; ok {
+			file.drawDiagnostic(row, diag)
+		}
+		if _, ok := buildErrs[bufferRow]; ok {
+			file.drawBuildError(row)
 		}
 	}
 	for row := len(slice); row < rows-1; row++ {
@@ -73,28 +116,6 @@ func (file *File) Flush() {
 	// file.HighlightCurrentWord()
 }
 
-// ensureSyntaxStates calculates syntax states for all lines up to (but not including) lineNum.
-func (file *File) ensureSyntaxStates(lineNum int) {
-	for i := 0; i < lineNum && i < file.buffer.Length(); i++ {
-		// Check if we already have the state cached
-		if file.stateCache.GetState(i+1) != syntaxcolor.StateNormal || i == 0 {
-			// State might be cached, but we need to verify by checking if it was calculated
-			// For simplicity, just recalculate if we don't have enough cached states
-		}
-
-		startState := file.stateCache.GetState(i)
-		fullStr := file.buffer.GetRowDirect(i).Tabs2spaces(file.tabWidth).ToString()
-		result := file.SyntaxRules.ColorizeWithState(fullStr, startState)
-		stateChanged := file.stateCache.SetEndState(i, result.EndState)
-
-		// If state didn't change, and we have more states cached, we can stop
-		if !stateChanged && file.stateCache.GetState(i+1) != syntaxcolor.StateNormal {
-			// The rest should still be valid
-			break
-		}
-	}
-}
-
 // ColorBracketMatch colorizes a matching bracket character.
 func (file *File) ColorBracketMatch(rows int) {
 	cursor := file.MultiCursor.GetCursor(0)
@@ -103,16 +124,16 @@ func (file *File) ColorBracketMatch(rows int) {
 	if err != nil {
 		return
 	}
-	col = file.buffer.GetRowDirect(row).TabCursorPos(col, file.tabWidth)
+	row, col = file.screenPosition(row, col)
 	lc := []syntaxcolor.LineColor{
 		{
-			Fg:    tcell.ColorRed,
+			Fg:    theme.Fg("bracket.match"),
 			Start: col,
 			End:   col + 1,
 		},
 	}
 
-	file.screen.Colorize(row-file.rowOffset, lc, file.colOffset)
+	file.screen.Colorize(row, lc, 0)
 }
 
 // HightlightCurrentWord highlights the word currently under the cursor.
@@ -167,23 +188,16 @@ func (file *File) ReadFile(name string, wgs ...*sync.WaitGroup) {
 
 	file.md5sum = md5.Sum([]byte(""))
 
+	var readRest func()
 	fileInfo, err := os.Stat(name)
 	if err != nil {
+		file.safe.set(false, false, false)
 		file.buffer.ReplaceBuffer(buffer.MakeBuffer([]string{""}))
 		file.modTime = time.Now()
 	} else {
 		file.fileMode = fileInfo.Mode()
 		file.modTime = fileInfo.ModTime()
-		stringBuf := []string{""}
-
-		byteBuf, err := os.ReadFile(name)
-		if err == nil {
-			file.setNewline(string(byteBuf))
-			stringBuf = strings.Split(string(byteBuf), file.newline)
-			file.md5sum = md5.Sum(byteBuf)
-		}
-
-		file.buffer.ReplaceBuffer(buffer.MakeBuffer(stringBuf))
+		readRest = file.readContents(name, fileInfo.Size())
 	}
 
 	file.ForceSnapshot()
@@ -191,6 +205,10 @@ func (file *File) ReadFile(name string, wgs ...*sync.WaitGroup) {
 	file.savedBuffer.ReplaceBuffer(file.buffer.DeepDup())
 
 	file.RequestFlush()
+	go file.RefreshGit()
+	if readRest != nil {
+		go readRest()
+	}
 
 }
 
@@ -217,17 +235,27 @@ func (file *File) processSaveRequests() {
 	}
 }
 
-// Save saves a file.
+// Save saves a file. Scratch buffers (see NewScratch) are not saved.
 func (file *File) Save() {
+	if file.scratch {
+		file.NotifyUser("Scratch buffers can't be saved")
+		return
+	}
+	if !file.writable() {
+		return
+	}
 	if file.autoFmt {
 		err := file.Fmt()
 		if err != nil {
 			file.NotifyUser(err.Error())
 		}
 	}
+	// Snapshot now (rather than on request), so that the saved state
+	// matches what is written to disk.
+	file.ForceSnapshot()
 	file.SnapshotSaved()
 	contents := []byte(file.ToString())
-	err := os.WriteFile(file.Name, contents, file.fileMode)
+	err := WriteFileAtomic(file.Name, contents, file.fileMode, file.backup)
 	if err != nil {
 		file.NotifyUser("Save Failed: " + err.Error())
 	} else {
@@ -235,5 +263,12 @@ func (file *File) Save() {
 		file.NotifyUser("Saved.")
 		file.modTime = time.Now()
 		file.md5sum = md5.Sum(contents)
+		file.SaveHistory()
+		file.WriteSwap()
+		go file.RefreshGit()
+		if doc := file.LspDoc(); doc != nil {
+			file.SyncLsp()
+			doc.Save()
+		}
 	}
 }
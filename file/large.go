@@ -0,0 +1,285 @@
+package file
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/wx13/sith/file/buffer"
+)
+
+const (
+	// defaultLargeFile is the size (in bytes) over which a file is large.
+	defaultLargeFile = 20 << 20
+
+	// sniffLen is how much of a file is checked for binary content.
+	sniffLen = 8000
+
+	// loadChunk is how much of a large file is read in at a time.
+	loadChunk = 1 << 20
+
+	// maxHexBytes is the most of a binary file shown in the hex view.
+	maxHexBytes = 1 << 20
+)
+
+var errReadOnly = errors.New("read only")
+
+// safeMode protects the editor from files which are too big, or are not
+// text. Large files open read-only, with syntax coloring, change markers,
+// completion and the like turned off, and are read in the background.
+// Binary files are shown as a hex dump, which can't be edited. The mode is
+// shared by views of the file.
+type safeMode struct {
+	large    bool
+	hex      bool
+	readOnly bool
+	loading  bool
+	mutex    sync.Mutex
+}
+
+// set resets the mode for a newly read file.
+func (mode *safeMode) set(large, hex, loading bool) {
+	mode.mutex.Lock()
+	mode.large = large
+	mode.hex = hex
+	mode.readOnly = large || hex
+	mode.loading = loading
+	mode.mutex.Unlock()
+}
+
+// Large returns true if the file is too big (or is binary) for the
+// features which work on the whole buffer.
+func (file *File) Large() bool {
+	file.safe.mutex.Lock()
+	defer file.safe.mutex.Unlock()
+	return file.safe.large || file.safe.hex
+}
+
+// HexView returns true if the file is binary, and shown as a hex dump.
+func (file *File) HexView() bool {
+	file.safe.mutex.Lock()
+	defer file.safe.mutex.Unlock()
+	return file.safe.hex
+}
+
+// Loading returns true while a large file is still being read in.
+func (file *File) Loading() bool {
+	file.safe.mutex.Lock()
+	defer file.safe.mutex.Unlock()
+	return file.safe.loading
+}
+
+// ReadOnly returns true if the buffer can't be edited.
+func (file *File) ReadOnly() bool {
+	file.safe.mutex.Lock()
+	defer file.safe.mutex.Unlock()
+	return file.safe.readOnly || file.safe.hex || file.safe.loading
+}
+
+// safeModeStatus returns the safe mode flags for the status line (empty
+// for an ordinary file).
+func (file *File) safeModeStatus() string {
+	file.safe.mutex.Lock()
+	defer file.safe.mutex.Unlock()
+	flags := []string{}
+	switch {
+	case file.safe.hex:
+		flags = append(flags, "HEX")
+	case file.safe.large:
+		flags = append(flags, "LARGE")
+	}
+	if file.safe.loading {
+		flags = append(flags, "LOADING")
+	}
+	if file.safe.readOnly || file.safe.hex || file.safe.loading {
+		flags = append(flags, "RO")
+	}
+	return strings.Join(flags, " ")
+}
+
+// writable returns true if the buffer can be edited. Otherwise it tells
+// the user why not.
+func (file *File) writable() bool {
+	file.safe.mutex.Lock()
+	binary, loading, readOnly := file.safe.hex, file.safe.loading, file.safe.readOnly
+	file.safe.mutex.Unlock()
+	switch {
+	case file.scratch:
+		file.NotifyUser("Read only (scratch buffer)")
+	case binary:
+		file.NotifyUser("Read only (hex view)")
+	case loading:
+		file.NotifyUser("Read only (still loading)")
+	case readOnly:
+		file.NotifyUser("Read only")
+	default:
+		return true
+	}
+	return false
+}
+
+// ToggleReadOnly makes a read-only file editable, or the other way around.
+// A file in the hex view, a scratch buffer, or one still loading, stays
+// read-only.
+func (file *File) ToggleReadOnly() {
+	file.safe.mutex.Lock()
+	locked := file.safe.hex || file.safe.loading || file.scratch
+	if !locked {
+		file.safe.readOnly = !file.safe.readOnly
+	}
+	readOnly := file.safe.readOnly
+	file.safe.mutex.Unlock()
+	switch {
+	case locked:
+		file.writable()
+	case readOnly:
+		file.NotifyUser("Read only")
+	default:
+		file.NotifyUser("Editable")
+	}
+}
+
+// readContents reads a file into the buffer. Binary files are read as a hex
+// dump. Large files are read a chunk at a time: the first chunk now, and
+// the rest by the returned function (which is nil for other files).
+func (file *File) readContents(name string, size int64) func() {
+	f, err := os.Open(name)
+	if err != nil {
+		file.safe.set(false, false, false)
+		file.buffer.ReplaceBuffer(buffer.MakeBuffer([]string{""}))
+		return nil
+	}
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	r := io.MultiReader(bytes.NewReader(head), f)
+
+	switch {
+	case bytes.IndexByte(head, 0) >= 0:
+		// Binary, going by the same test as git.
+		defer f.Close()
+		file.safe.set(false, true, false)
+		file.readHex(r, size)
+		return nil
+	case size > file.largeFile:
+		file.safe.set(true, false, true)
+		file.setNewline(string(head))
+		return file.readLarge(f, r)
+	}
+
+	defer f.Close()
+	file.safe.set(false, false, false)
+	stringBuf := []string{""}
+	byteBuf, err := io.ReadAll(r)
+	if err == nil {
+		file.setNewline(string(byteBuf))
+		stringBuf = strings.Split(string(byteBuf), file.newline)
+		file.md5sum = md5.Sum(byteBuf)
+	}
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(stringBuf))
+	return nil
+}
+
+// readHex reads (the start of) a binary file into the buffer as a hex
+// dump, in the style of "hexdump -C".
+func (file *File) readHex(r io.Reader, size int64) {
+	data, _ := io.ReadAll(io.LimitReader(r, maxHexBytes))
+	lines := []string{}
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(hex.Dump(data), "\n"), "\n")
+	}
+	if more := size - int64(len(data)); more > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more bytes", more))
+	}
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	file.newline = "\n"
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(lines))
+}
+
+// readLarge reads the first chunk of a large file into the buffer, so that
+// there is something to show. The returned function reads in the rest,
+// keeping the saved buffer in step, and clears the loading flag at the end.
+func (file *File) readLarge(f *os.File, r io.Reader) func() {
+	lr := &lineReader{r: r, hash: md5.New(), newline: []byte(file.newline)}
+	lines := lr.next()
+	for len(lines) == 0 {
+		lines = lr.next()
+	}
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(lines))
+
+	return func() {
+		defer f.Close()
+		for !lr.done {
+			strs := lr.next()
+			lines := make([]buffer.Line, len(strs))
+			for k, str := range strs {
+				lines[k] = buffer.MakeLine(str)
+			}
+			file.buffer.Append(lines...)
+			file.savedBuffer.ReplaceBuffer(file.buffer.Dup())
+			file.RequestFlush()
+		}
+		if lr.err != nil {
+			file.NotifyUser("Read failed: " + lr.err.Error())
+		}
+		copy(file.md5sum[:], lr.hash.Sum(nil))
+
+		// The history so far holds partly read versions of the file.
+		<-file.loaded
+		file.buffHist.Reset(file.buffer, file.MultiCursor)
+		file.safe.mutex.Lock()
+		file.safe.loading = false
+		file.safe.mutex.Unlock()
+		file.RequestFlush()
+	}
+}
+
+// lineReader reads text a chunk of lines at a time.
+type lineReader struct {
+	r       io.Reader
+	hash    hash.Hash
+	newline []byte
+	// partial is the start of a line which isn't yet complete.
+	partial []byte
+	done    bool
+	err     error
+}
+
+// next returns the complete lines in the next chunk of text. At the end of
+// the text, the last line is included, and done is set.
+func (lr *lineReader) next() []string {
+	chunk := make([]byte, loadChunk)
+	n, err := io.ReadFull(lr.r, chunk)
+	chunk = chunk[:n]
+	lr.hash.Write(chunk)
+
+	// Only search the new text (and the end of the partial line, in case
+	// a newline spans the two).
+	from := max(0, len(lr.partial)-len(lr.newline)+1)
+	data := append(lr.partial, chunk...)
+	lines := []string{}
+	if end := bytes.LastIndex(data[from:], lr.newline); end >= 0 {
+		end += from
+		lines = strings.Split(string(data[:end]), string(lr.newline))
+		data = append([]byte{}, data[end+len(lr.newline):]...)
+	}
+	lr.partial = data
+
+	if err != nil {
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			lr.err = err
+		}
+		lines = append(lines, string(lr.partial))
+		lr.done = true
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package file
+
+import (
+	"strconv"
+
+	"github.com/wx13/sith/theme"
+)
+
+// Line number modes (see config.Config.LineNumbers).
+const (
+	LineNumbersNone     = "none"
+	LineNumbersAbsolute = "absolute"
+	LineNumbersRelative = "relative"
+	LineNumbersHybrid   = "hybrid"
+)
+
+var lineNumberModes = []string{
+	LineNumbersNone,
+	LineNumbersAbsolute,
+	LineNumbersRelative,
+	LineNumbersHybrid,
+}
+
+// LineNumbers returns the line number mode.
+func (file *File) LineNumbers() string {
+	switch file.lineNumbers {
+	case LineNumbersAbsolute, LineNumbersRelative, LineNumbersHybrid:
+		return file.lineNumbers
+	}
+	return LineNumbersNone
+}
+
+// CycleLineNumbers switches to the next line number mode: none, absolute,
+// relative, hybrid.
+func (file *File) CycleLineNumbers() {
+	mode := file.LineNumbers()
+	for k := range lineNumberModes {
+		if lineNumberModes[k] == mode {
+			file.lineNumbers = lineNumberModes[(k+1)%len(lineNumberModes)]
+			break
+		}
+	}
+	file.NotifyUser("Line numbers: " + file.lineNumbers)
+}
+
+// lineNumberWidth returns the number of digits needed for the line numbers
+// (zero if they are off).
+func (file *File) lineNumberWidth() int {
+	if file.LineNumbers() == LineNumbersNone {
+		return 0
+	}
+	return len(strconv.Itoa(file.buffer.Length()))
+}
+
+// lineNumber returns the line number to show for a row.
+func (file *File) lineNumber(row int) string {
+	cursorRow := file.MultiCursor.GetRow(0)
+	mode := file.LineNumbers()
+	if mode == LineNumbersAbsolute || (mode == LineNumbersHybrid && row == cursorRow) {
+		return strconv.Itoa(row + 1)
+	}
+	if row < cursorRow {
+		return strconv.Itoa(cursorRow - row)
+	}
+	return strconv.Itoa(row - cursorRow)
+}
+
+// drawLineNumber draws the line number for a buffer row on a screen row.
+func (file *File) drawLineNumber(screenRow, row int) {
+	if file.LineNumbers() == LineNumbersNone {
+		return
+	}
+	color := theme.Fg("linenumber")
+	if row == file.MultiCursor.GetRow(0) {
+		color = theme.Fg("linenumber.current")
+	}
+	file.screen.DrawLineNumber(screenRow, file.lineNumber(row), color)
+}
@@ -0,0 +1,134 @@
+package file
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wx13/sith/lsp"
+	"github.com/wx13/sith/theme"
+)
+
+// lspCompleteTimeout is how long completion waits for the language server.
+// Completion happens as tab is pressed, so it can't wait long.
+const lspCompleteTimeout = time.Second
+
+// LspCmd returns the language server command for the file (empty if there
+// is none).
+func (file *File) LspCmd() string {
+	return file.lspCmd
+}
+
+// SetLspDoc connects the file to a language server document.
+func (file *File) SetLspDoc(doc *lsp.Document) {
+	file.lspMutex.Lock()
+	defer file.lspMutex.Unlock()
+	file.lspDoc = doc
+	file.lspVersion = -1
+}
+
+// LspDoc returns the file's language server document, or nil if the file
+// is not connected to a language server.
+func (file *File) LspDoc() *lsp.Document {
+	file.lspMutex.Lock()
+	defer file.lspMutex.Unlock()
+	return file.lspDoc
+}
+
+// SyncLsp sends the buffer contents to the language server, if they have
+// changed since the last sync.
+func (file *File) SyncLsp() {
+	version := file.buffer.Version()
+	file.lspMutex.Lock()
+	doc := file.lspDoc
+	synced := file.lspVersion == version
+	file.lspVersion = version
+	file.lspMutex.Unlock()
+	if doc == nil || synced {
+		return
+	}
+	doc.Change(file.ToString())
+}
+
+// LspComplete asks the language server for completions at the cursor, and
+// returns the suffixes which would complete the word before the cursor.
+func (file *File) LspComplete(prefix string) []string {
+	doc := file.LspDoc()
+	if doc == nil {
+		return nil
+	}
+	file.SyncLsp()
+	row, col := file.MultiCursor.GetRowCol(0)
+	ctx, cancel := context.WithTimeout(context.Background(), lspCompleteTimeout)
+	defer cancel()
+	items, err := doc.Completion(ctx, row, col)
+	if err != nil {
+		return nil
+	}
+
+	// Find the identifier being completed.
+	word := []rune(prefix)
+	start := len(word)
+	for start > 0 && (unicode.IsLetter(word[start-1]) || unicode.IsDigit(word[start-1]) || word[start-1] == '_') {
+		start--
+	}
+	tail := string(word[start:])
+
+	seen := map[string]bool{}
+	suffixes := []string{}
+	for _, item := range items {
+		text := item.Text()
+		if !strings.HasPrefix(text, tail) || len(text) == len(tail) {
+			continue
+		}
+		suffix := text[len(tail):]
+		if !seen[suffix] {
+			seen[suffix] = true
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	return suffixes
+}
+
+// Diagnostics returns the language server's diagnostics for the file.
+func (file *File) Diagnostics() []lsp.Diagnostic {
+	doc := file.LspDoc()
+	if doc == nil {
+		return nil
+	}
+	return doc.Diagnostics()
+}
+
+// diagnosticRows maps each row with diagnostics to the most severe one.
+func (file *File) diagnosticRows() map[int]lsp.Diagnostic {
+	rows := map[int]lsp.Diagnostic{}
+	for _, diag := range file.Diagnostics() {
+		row := diag.Range.Start.Line
+		other, ok := rows[row]
+		if !ok || severity(diag) < severity(other) {
+			rows[row] = diag
+		}
+	}
+	return rows
+}
+
+// severity returns the diagnostic's severity (errors if unspecified).
+func severity(diag lsp.Diagnostic) int {
+	if diag.Severity == 0 {
+		return lsp.SeverityError
+	}
+	return diag.Severity
+}
+
+// drawDiagnostic draws a diagnostic indicator in the gutter.
+func (file *File) drawDiagnostic(row int, diag lsp.Diagnostic) {
+	switch severity(diag) {
+	case lsp.SeverityError:
+		file.screen.DrawGutterSymbol(row, '●', theme.Fg("gutter.error"))
+	case lsp.SeverityWarning:
+		file.screen.DrawGutterSymbol(row, '●', theme.Fg("gutter.warning"))
+	default:
+		file.screen.DrawGutterSymbol(row, '•', theme.Fg("gutter.info"))
+	}
+}
@@ -0,0 +1,73 @@
+package file
+
+// BufferPos returns the buffer position shown at a screen position (a row,
+// and a column in the text area). Positions past the end of a line are at
+// the end of the line, and positions in the gutter are at its start. Above
+// the screen is the row before the first one shown, and below the screen
+// is the row after the last one shown, so that dragging past the edge
+// scrolls.
+func (file *File) BufferPos(row, col int) (int, int) {
+	cols, rows := file.screen.Size()
+	srs := file.screenRows(rows-1, cols)
+	if len(srs) == 0 {
+		return 0, 0
+	}
+	first, last := srs[0], srs[len(srs)-1]
+	switch {
+	case row < 0:
+		r := max(first.row-1, 0)
+		return r, file.colAt(r, file.colOffset+col)
+	case row >= len(srs):
+		r := last.row
+		if len(srs) >= rows-1 {
+			r = min(r+1, file.buffer.Length()-1)
+		}
+		return r, file.colAt(r, file.colOffset+col)
+	}
+
+	sr := srs[row]
+	pos := max(sr.start+col-sr.indent, sr.start)
+	if file.wrapping() && pos >= sr.end {
+		// Past the end of a wrapped segment, but not the last one.
+		line := file.buffer.GetRowDirect(sr.row)
+		if sr.end < line.TabRunePos(line.Length(), file.tabWidth) {
+			pos = sr.end - 1
+		}
+	}
+	return sr.row, file.colAt(sr.row, pos)
+}
+
+// colAt returns the column of a row at a position in the line (with tabs
+// expanded).
+func (file *File) colAt(row, pos int) int {
+	return runeCol(file.buffer.GetRowDirect(row), max(pos, 0), file.tabWidth)
+}
+
+// Click moves the cursor to a screen position, dropping any other cursors
+// and the selection.
+func (file *File) Click(row, col int) {
+	row, col = file.BufferPos(row, col)
+	file.ClearSelection()
+	file.MultiCursor.Clear()
+	file.MultiCursor.Set(row, col, col)
+}
+
+// AddCursorAt adds a cursor at a screen position, keeping the existing
+// cursors. If there is a cursor there already, it is removed instead.
+func (file *File) AddCursorAt(row, col int) {
+	row, col = file.BufferPos(row, col)
+	file.ClearSelection()
+	if file.MultiCursor.Length() == 1 {
+		file.MultiCursor.Snapshot()
+	}
+	file.MultiCursor.Set(row, col, col)
+	file.MultiCursor.Snapshot()
+}
+
+// DragTo moves the cursor to a screen position, selecting the text from
+// where the drag started.
+func (file *File) DragTo(row, col int) {
+	row, col = file.BufferPos(row, col)
+	file.ExtendSelection()
+	file.MultiCursor.Set(row, col, col)
+}
@@ -78,6 +78,14 @@ func (file *File) CursorUp(n int) {
 	if file.MultiCursor.NavModeIsDetached() || file.MultiCursor.NavModeIsColumn() {
 		cursors = cursors[:1]
 	}
+	if file.wrapping() {
+		for idx := range cursors {
+			file.moveWrapped(idx, -n)
+		}
+		file.enforceRowBounds()
+		file.enforceColBounds()
+		return
+	}
 	for idx := range cursors {
 		row, _, colwant := file.MultiCursor.GetCursorRCC(idx)
 		row -= n
@@ -96,6 +104,14 @@ func (file *File) CursorDown(n int) {
 	if file.MultiCursor.NavModeIsDetached() || file.MultiCursor.NavModeIsColumn() {
 		cursors = cursors[:1]
 	}
+	if file.wrapping() {
+		for idx := range cursors {
+			file.moveWrapped(idx, n)
+		}
+		file.enforceRowBounds()
+		file.enforceColBounds()
+		return
+	}
 	for idx := range cursors {
 		row, _, colwant := file.MultiCursor.GetCursorRCC(idx)
 		row += n
@@ -162,6 +178,15 @@ func (file *File) GetCursor(idx int) (int, int) {
 	file.enforceRowBounds(idx)
 	file.enforceColBounds(idx)
 	row, col, _ := file.MultiCursor.GetCursorRCC(idx)
+	return file.screenPosition(row, col)
+}
+
+// screenPosition returns the screen position of a (row, col) position in
+// the buffer.
+func (file *File) screenPosition(row, col int) (int, int) {
+	if file.wrapping() {
+		return file.wrappedCursor(row, col)
+	}
 	line := file.buffer.GetRowDirect(row).Slice(0, col).Tabs2spaces(file.tabWidth)
 	n := file.screen.StringDispLen(line.ToString())
 	return row - file.rowOffset, n - file.colOffset
@@ -195,6 +220,7 @@ func (file *File) ScrollUp() {
 	if file.rowOffset < file.buffer.Length()-1 {
 		file.rowOffset++
 	}
+	file.followScroll()
 }
 
 // ScrollDown shifts the screen down one row.
@@ -202,10 +228,44 @@ func (file *File) ScrollDown() {
 	if file.rowOffset > 0 {
 		file.rowOffset--
 	}
+	file.followScroll()
+}
+
+// followScroll moves the cursor back onto the screen after scrolling (or
+// else the screen would scroll straight back to the cursor).
+func (file *File) followScroll() {
+	row, _, colwant := file.MultiCursor.GetCursorRCC(0)
+	cols, rows := file.screen.Size()
+	bottom := file.rowOffset + rows - 2
+	if file.wrapping() {
+		// The bottom row must fit on the screen in full.
+		srs := file.screenRows(rows-1, cols)
+		last := srs[len(srs)-1]
+		bottom = last.row
+		segs := file.wrapRow(last.row)
+		if last.row > file.rowOffset && last.start < segs[len(segs)-1].start {
+			bottom--
+		}
+	}
+	switch {
+	case row < file.rowOffset:
+		row = file.rowOffset
+	case row > bottom:
+		row = bottom
+	default:
+		return
+	}
+	file.MultiCursor.SetCursor(0, row, colwant, colwant)
+	file.enforceColBounds(0)
 }
 
 func (file *File) updateOffsets(nRows, nCols int) {
 
+	if file.wrapping() {
+		file.updateWrappedOffsets(nRows)
+		return
+	}
+
 	row := file.MultiCursor.GetRow(0)
 	if row < file.rowOffset {
 		file.rowOffset = row
@@ -335,7 +395,11 @@ func (file *File) PrevChange() {
 }
 
 func (file *File) gotoChange(direction int) {
-	diffResult := file.buffer.DiffLinesFull(&file.savedBuffer)
+	if file.Large() {
+		file.NotifyUser("No change tracking for large files")
+		return
+	}
+	_, diffResult := file.changes.get()
 
 	// Build sorted list of all change points:
 	// - Changed/added lines (navigate to that line)
@@ -373,41 +437,28 @@ func (file *File) gotoChange(direction int) {
 	}
 
 	currentRow := file.MultiCursor.GetRow(0)
+	file.CursorGoTo(nextPoint(changePoints, currentRow, direction), 0)
+}
 
-	var targetRow int
-	found := false
-
+// nextPoint returns the first of the sorted points after (direction > 0) or
+// before (direction < 0) the current row, wrapping around at the ends.
+func nextPoint(points []int, currentRow, direction int) int {
 	if direction > 0 {
-		// Find next change after current row
-		for _, line := range changePoints {
+		// Find next point after current row
+		for _, line := range points {
 			if line > currentRow {
-				targetRow = line
-				found = true
-				break
-			}
-		}
-		// Wrap around to first change
-		if !found {
-			targetRow = changePoints[0]
-			found = true
-		}
-	} else {
-		// Find previous change before current row
-		for i := len(changePoints) - 1; i >= 0; i-- {
-			if changePoints[i] < currentRow {
-				targetRow = changePoints[i]
-				found = true
-				break
+				return line
 			}
 		}
-		// Wrap around to last change
-		if !found {
-			targetRow = changePoints[len(changePoints)-1]
-			found = true
-		}
+		// Wrap around to first point
+		return points[0]
 	}
-
-	if found {
-		file.CursorGoTo(targetRow, 0)
+	// Find previous point before current row
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i] < currentRow {
+			return points[i]
+		}
 	}
+	// Wrap around to last point
+	return points[len(points)-1]
 }
@@ -0,0 +1,148 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backup kinds (see config.Config.Backup).
+const (
+	BackupNone      = "none"
+	BackupTilde     = "tilde"
+	BackupTimestamp = "timestamp"
+)
+
+// WriteFileAtomic writes data to the named file, so that a failure part way
+// through leaves the original file intact: it writes a temporary file in
+// the same directory, syncs it, and renames it into place.
+//
+// Symlinks are followed, so the link target gets written. An existing
+// file's mode and ownership are kept. Files with multiple hard links (or
+// whose ownership can't be kept) are written in place instead, since
+// replacing them would break the links (or change the owner).
+//
+// If backup is BackupTilde or BackupTimestamp, the old contents are first
+// copied to a backup file.
+func WriteFileAtomic(name string, data []byte, mode os.FileMode, backup string) error {
+	target := name
+	if resolved, err := filepath.EvalSymlinks(name); err == nil {
+		target = resolved
+	} else if link, err := os.Readlink(name); err == nil {
+		// A dangling symlink: create its target.
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(name), link)
+		}
+		target = link
+	}
+
+	info, err := os.Stat(target)
+	exists := err == nil
+	if exists {
+		if !info.Mode().IsRegular() {
+			return errors.New("not a regular file")
+		}
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := makeBackup(target, backup); err != nil {
+			return err
+		}
+	}
+
+	if exists && linkCount(info) > 1 {
+		return writeInPlace(target, data, mode)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".sith-")
+	if err != nil {
+		// We may be able to write the file, but not the directory.
+		return writeInPlace(target, data, mode)
+	}
+	tmpName := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if exists {
+		if err := keepOwner(tmp, info); err != nil {
+			fail(err)
+			return writeInPlace(target, data, mode)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	syncDir(filepath.Dir(target))
+	return nil
+}
+
+// writeInPlace overwrites a file's contents.
+func writeInPlace(name string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BackupPath returns the name of the backup file for a file, or an empty
+// string if there is no backup.
+func BackupPath(name, backup string, now time.Time) string {
+	switch backup {
+	case BackupTilde:
+		return name + "~"
+	case BackupTimestamp:
+		return name + "." + now.Format("20060102-150405") + "~"
+	}
+	return ""
+}
+
+// makeBackup copies a file to its backup file.
+func makeBackup(name, backup string) error {
+	backupName := BackupPath(name, backup, time.Now())
+	if backupName == "" {
+		return nil
+	}
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	os.Remove(backupName)
+	dst, err := os.OpenFile(backupName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,103 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/file"
+)
+
+func checkFile(t *testing.T, name, expected string) {
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != expected {
+		t.Errorf("%s: expected %q, got %q (%v)", name, expected, data, err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+
+	// New files get the requested mode.
+	if err := file.WriteFileAtomic(name, []byte("one"), 0640, file.BackupNone); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, name, "one")
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0640 {
+		t.Errorf("new file mode: %v", info.Mode())
+	}
+
+	// Existing files keep their mode.
+	os.Chmod(name, 0600)
+	file.WriteFileAtomic(name, []byte("two"), 0644, file.BackupTilde)
+	checkFile(t, name, "two")
+	checkFile(t, name+"~", "one")
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0600 {
+		t.Errorf("mode should be kept: %v", info.Mode())
+	}
+
+	// Timestamped backups.
+	file.WriteFileAtomic(name, []byte("three"), 0644, file.BackupTimestamp)
+	backups, _ := filepath.Glob(name + ".*~")
+	if len(backups) != 1 {
+		t.Fatalf("expected one timestamped backup, got %v", backups)
+	}
+	checkFile(t, backups[0], "two")
+
+	// No temporary files are left behind.
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("unexpected files: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicLinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	os.WriteFile(target, []byte("old"), 0644)
+
+	// Writing through a symlink writes the target, and keeps the link.
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	file.WriteFileAtomic(link, []byte("new"), 0644, file.BackupNone)
+	checkFile(t, target, "new")
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced")
+	}
+
+	// Hard links keep sharing the contents.
+	hard := filepath.Join(dir, "hard.txt")
+	if err := os.Link(target, hard); err != nil {
+		t.Skip("hard links not supported")
+	}
+	file.WriteFileAtomic(hard, []byte("newer"), 0644, file.BackupNone)
+	checkFile(t, target, "newer")
+}
+
+func TestSaveBackup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("hello"), 0644)
+	cfg := config.Config{
+		FileConfigs: map[string]config.Config{
+			"txt": {Backup: file.BackupTilde, Backup_set: true},
+		},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	f := file.NewFile(name, make(chan struct{}), nil, cfg, &wg)
+	wg.Wait()
+	f.InsertStr("X")
+	f.Save()
+	checkFile(t, name, "Xhello")
+	checkFile(t, name+"~", "hello")
+	if f.IsModified() {
+		t.Error("saved file should not be modified")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to a file.
+func linkCount(info os.FileInfo) int {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Nlink)
+	}
+	return 1
+}
+
+// keepOwner gives a new file the owner and group of an existing one. It
+// fails (unless we are root) if the existing file belongs to someone else.
+func keepOwner(f *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	newInfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	newStat, ok := newInfo.Sys().(*syscall.Stat_t)
+	if ok && newStat.Uid == stat.Uid && newStat.Gid == stat.Gid {
+		return nil
+	}
+	return f.Chown(int(stat.Uid), int(stat.Gid))
+}
+
+// syncDir syncs a directory, so that a rename within it is durable.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
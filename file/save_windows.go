@@ -0,0 +1,19 @@
+package file
+
+import "os"
+
+// linkCount returns the number of hard links to a file. Windows doesn't
+// tell us, so assume one.
+func linkCount(info os.FileInfo) int {
+	return 1
+}
+
+// keepOwner does nothing on windows, where new files inherit permissions
+// from their directory.
+func keepOwner(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing on windows, which can't sync directories.
+func syncDir(dir string) {
+}
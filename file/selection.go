@@ -0,0 +1,418 @@
+package file
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wx13/sith/file/buffer"
+)
+
+// Region is a span of selected text. The end position is exclusive.
+type Region struct {
+	StartRow, StartCol int
+	EndRow, EndCol     int
+}
+
+// Contains returns true if the row, col position lies within the region.
+func (region Region) Contains(row, col int) bool {
+	if row < region.StartRow || row > region.EndRow {
+		return false
+	}
+	if row == region.StartRow && col < region.StartCol {
+		return false
+	}
+	if row == region.EndRow && col >= region.EndCol {
+		return false
+	}
+	return true
+}
+
+func posLess(r1, c1, r2, c2 int) bool {
+	return r1 < r2 || (r1 == r2 && c1 < c2)
+}
+
+// ToggleMark starts a selection at each cursor, or clears the current
+// selection. While the mark is set, cursor movement extends the selection.
+func (file *File) ToggleMark() {
+	if file.HasSelection() {
+		file.ClearSelection()
+		return
+	}
+	file.MultiCursor.SetAnchors()
+	file.marking = true
+}
+
+// ExtendSelection starts a selection at each cursor, unless one already
+// exists. Call it before moving the cursor to extend the selection
+// (e.g. shift+arrow).
+func (file *File) ExtendSelection() {
+	if !file.MultiCursor.HasAnchors() {
+		file.MultiCursor.SetAnchors()
+		file.marking = false
+	}
+}
+
+// EndShiftSelection clears a selection made by ExtendSelection. A selection
+// started with ToggleMark is kept.
+func (file *File) EndShiftSelection() {
+	if !file.marking {
+		file.MultiCursor.ClearAnchors()
+	}
+}
+
+// ClearSelection removes the selection.
+func (file *File) ClearSelection() {
+	file.MultiCursor.ClearAnchors()
+	file.marking = false
+}
+
+// HasSelection returns true if any text is selected.
+func (file *File) HasSelection() bool {
+	return len(file.Selections()) > 0
+}
+
+// Selections returns the selected regions, sorted by position, with
+// overlapping regions merged.
+func (file *File) Selections() []Region {
+	regions := []Region{}
+	for _, cursor := range file.MultiCursor.Cursors() {
+		aRow, aCol, ok := cursor.Anchor()
+		if !ok {
+			continue
+		}
+		row, col := cursor.RowCol()
+		aRow, aCol = file.clampPos(aRow, aCol)
+		row, col = file.clampPos(row, col)
+		if aRow == row && aCol == col {
+			continue
+		}
+		if posLess(row, col, aRow, aCol) {
+			regions = append(regions, Region{row, col, aRow, aCol})
+		} else {
+			regions = append(regions, Region{aRow, aCol, row, col})
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return posLess(regions[i].StartRow, regions[i].StartCol,
+			regions[j].StartRow, regions[j].StartCol)
+	})
+	merged := []Region{}
+	for _, region := range regions {
+		n := len(merged)
+		if n > 0 && !posLess(merged[n-1].EndRow, merged[n-1].EndCol,
+			region.StartRow, region.StartCol) {
+			if posLess(merged[n-1].EndRow, merged[n-1].EndCol, region.EndRow, region.EndCol) {
+				merged[n-1].EndRow = region.EndRow
+				merged[n-1].EndCol = region.EndCol
+			}
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+func (file *File) clampPos(row, col int) (int, int) {
+	if row >= file.buffer.Length() {
+		row = file.buffer.Length() - 1
+		col = file.buffer.RowLength(row)
+	}
+	if row < 0 {
+		row, col = 0, 0
+	}
+	if col > file.buffer.RowLength(row) {
+		col = file.buffer.RowLength(row)
+	}
+	if col < 0 {
+		col = 0
+	}
+	return row, col
+}
+
+// selectedRows returns the range of rows to operate on: the selection if
+// there is one, or else the rows spanned by the multi-cursor.
+func (file *File) selectedRows() (int, int) {
+	regions := file.Selections()
+	if len(regions) == 0 {
+		return file.MultiCursor.MinMaxRow()
+	}
+	first := regions[0]
+	last := regions[len(regions)-1]
+	endRow := last.EndRow
+	if last.EndCol == 0 && endRow > last.StartRow {
+		endRow--
+	}
+	return first.StartRow, endRow
+}
+
+// SelectedText returns the selected text. Multiple regions are separated
+// by newlines.
+func (file *File) SelectedText() []string {
+	strs := []string{}
+	for _, region := range file.Selections() {
+		strs = append(strs, file.buffer.RegionText(region.StartRow, region.StartCol,
+			region.EndRow, region.EndCol)...)
+	}
+	return strs
+}
+
+// CutSelection removes the selected text and returns it.
+func (file *File) CutSelection() []string {
+	if !file.writable() {
+		return nil
+	}
+	strs := file.SelectedText()
+	file.DeleteSelection()
+	return strs
+}
+
+// DeleteSelection removes the selected text, leaving a cursor at the start
+// of each region.
+func (file *File) DeleteSelection() {
+	if !file.writable() {
+		return
+	}
+	regions := file.Selections()
+	if len(regions) == 0 {
+		return
+	}
+	// Work backwards, so that earlier positions remain valid.
+	rows := map[int][]int{}
+	for k := len(regions) - 1; k >= 0; k-- {
+		region := regions[k]
+		file.buffer.DeleteRegion(region.StartRow, region.StartCol,
+			region.EndRow, region.EndCol)
+		// Shift the cursors already placed on later rows.
+		shifted := map[int][]int{}
+		dRows := region.EndRow - region.StartRow
+		for row, cols := range rows {
+			if row == region.EndRow {
+				for _, col := range cols {
+					col = col - region.EndCol + region.StartCol
+					shifted[region.StartRow] = append(shifted[region.StartRow], col)
+				}
+			} else {
+				shifted[row-dRows] = append(shifted[row-dRows], cols...)
+			}
+		}
+		rows = shifted
+		rows[region.StartRow] = append(rows[region.StartRow], region.StartCol)
+	}
+	file.marking = false
+	file.MultiCursor.ResetCursors(rows)
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	file.Snapshot()
+}
+
+// PasteText inserts text at the cursor(s). If there is one line of text
+// per cursor, each cursor gets its own line; otherwise the text is inserted
+// at the primary cursor only.
+func (file *File) PasteText(strs []string) {
+	if !file.writable() {
+		return
+	}
+	if len(strs) == 0 {
+		return
+	}
+	if file.HasSelection() {
+		file.DeleteSelection()
+	}
+	cursors := file.MultiCursor.Cursors()
+	if len(cursors) > 1 && len(cursors) == len(strs) {
+		// Sort the cursors by position, and insert from the end backwards.
+		type pos struct{ row, col int }
+		positions := make([]pos, len(cursors))
+		for k, cursor := range cursors {
+			positions[k] = pos{cursor.Row(), cursor.Col()}
+		}
+		sort.Slice(positions, func(i, j int) bool {
+			return posLess(positions[i].row, positions[i].col,
+				positions[j].row, positions[j].col)
+		})
+		for k := len(positions) - 1; k >= 0; k-- {
+			file.buffer.InsertText(positions[k].row, positions[k].col, strs[k:k+1])
+		}
+		// Each cursor moves past its own text, plus the text inserted
+		// before it on the same row.
+		rows := map[int][]int{}
+		shift := 0
+		for k, p := range positions {
+			if k > 0 && positions[k-1].row != p.row {
+				shift = 0
+			}
+			shift += len([]rune(strs[k]))
+			rows[p.row] = append(rows[p.row], p.col+shift)
+		}
+		file.MultiCursor.ResetCursors(rows)
+	} else {
+		row, col := file.MultiCursor.GetRowCol(0)
+		row, col = file.buffer.InsertText(row, col, strs)
+		file.MultiCursor.Clear()
+		file.MultiCursor.SetCursor(0, row, col, col)
+	}
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	file.Snapshot()
+}
+
+// InsertPasted inserts text pasted into the terminal at every cursor (in
+// place of any selection), as a single undo step. Unlike typed text, it
+// gets no auto-indent, auto-tab or completion.
+func (file *File) InsertPasted(text string) {
+	if !file.writable() || text == "" {
+		return
+	}
+	if file.HasSelection() {
+		file.DeleteSelection()
+	}
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	lines := strings.Split(text, "\n")
+
+	// Insert at each cursor in order, keeping track of how the earlier
+	// insertions have moved the later cursors.
+	type pos struct{ row, col int }
+	positions := []pos{}
+	for _, cursor := range file.MultiCursor.Cursors() {
+		positions = append(positions, pos{cursor.Row(), cursor.Col()})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return posLess(positions[i].row, positions[i].col,
+			positions[j].row, positions[j].col)
+	})
+	rows := map[int][]int{}
+	rowShift, colShift, prevRow := 0, 0, -1
+	for _, p := range positions {
+		if p.row != prevRow {
+			colShift = 0
+		}
+		row, col := p.row+rowShift, p.col+colShift
+		endRow, endCol := file.buffer.InsertText(row, col, lines)
+		rowShift += endRow - row
+		colShift = endCol - p.col
+		prevRow = p.row
+		rows[endRow] = append(rows[endRow], endCol)
+	}
+	file.MultiCursor.ResetCursors(rows)
+	file.Snapshot()
+}
+
+// IndentSelection indents (dir > 0) or unindents (dir < 0) the selected
+// rows by one indentation string. The cursors and anchors move with the
+// text.
+func (file *File) IndentSelection(dir int) {
+	if !file.writable() {
+		return
+	}
+	startRow, endRow := file.selectedRows()
+	shifts := map[int]int{}
+	for row := startRow; row <= endRow; row++ {
+		str := file.buffer.GetRowDirect(row).ToString()
+		if dir > 0 {
+			if len(str) == 0 {
+				continue
+			}
+			str = file.tabString + str
+			shifts[row] = utf8.RuneCountInString(file.tabString)
+		} else {
+			n := file.indentLen(str)
+			if n == 0 {
+				continue
+			}
+			str = str[n:]
+			shifts[row] = -n
+		}
+		file.buffer.SetRow(row, buffer.MakeLine(str))
+	}
+	file.MultiCursor.ShiftCols(shifts)
+	file.enforceColBounds()
+	file.Snapshot()
+}
+
+// indentLen returns the length of one level of indentation at the start
+// of str: the indentation string, or else up to a tab width of spaces
+// (or a single tab).
+func (file *File) indentLen(str string) int {
+	if strings.HasPrefix(str, file.tabString) {
+		return len(file.tabString)
+	}
+	n, width := 0, 0
+	for n < len(str) && width < max(file.tabWidth, 1) {
+		switch str[n] {
+		case ' ':
+			width++
+		case '\t':
+			width = max(file.tabWidth, 1)
+		default:
+			return n
+		}
+		n++
+	}
+	return n
+}
+
+// HighlightSelection highlights the selected regions on the screen.
+func (file *File) HighlightSelection() {
+	cols, rows := file.screen.Size()
+	// Each buffer row takes up at least one screen row, so only these can
+	// be on screen.
+	top, bottom := file.rowOffset, file.rowOffset+rows-1
+	for _, region := range file.Selections() {
+		for row := max(region.StartRow, top); row <= min(region.EndRow, bottom); row++ {
+			line := file.buffer.GetRowDirect(row)
+			startCol := 0
+			if row == region.StartRow {
+				startCol = region.StartCol
+			}
+			// Include the end-of-line, so that selected blank lines show up.
+			endCol := line.TabRunePos(line.Length(), file.tabWidth) + 1
+			if row == region.EndRow {
+				endCol = line.TabRunePos(region.EndCol, file.tabWidth)
+			}
+			startCol = line.TabRunePos(startCol, file.tabWidth)
+
+			firstRow, segments := file.rowSegments(row, cols)
+			for k, seg := range segments {
+				screenRow := firstRow + k
+				if screenRow < 0 || screenRow >= rows-1 {
+					continue
+				}
+				segEnd := seg.end
+				if k == len(segments)-1 {
+					segEnd = endCol
+				}
+				start := max(startCol, seg.start) - seg.start + seg.indent
+				end := min(endCol, segEnd) - seg.start + seg.indent
+				if start < 0 {
+					start = 0
+				}
+				if end > cols {
+					end = cols
+				}
+				if end <= start {
+					continue
+				}
+				file.screen.HighlightRole(screenRow, start, end-1, "selection")
+			}
+		}
+	}
+}
+
+// SearchRegion searches for searchTerm within a region, starting from
+// (row, col).
+func (file *File) SearchRegion(searchTerm string, region Region, row, col int) (int, int, error) {
+	if posLess(row, col, region.StartRow, region.StartCol) {
+		row, col = region.StartRow, region.StartCol
+	}
+	for ; row <= region.EndRow; row++ {
+		start, end := file.buffer.GetRowDirect(row).Search(searchTerm, col, -1)
+		if start >= 0 && (row < region.EndRow || end <= region.EndCol) {
+			return row, start, nil
+		}
+		col = 0
+	}
+	return region.EndRow, region.EndCol, errors.New("Not Found")
+}
@@ -23,6 +23,10 @@ func (file *File) FileChanged() (bool, error) {
 	if !fileInfo.ModTime().After(file.modTime) {
 		return false, nil
 	}
+	// Large files aren't read again just to check.
+	if file.Large() {
+		return true, nil
+	}
 	byteBuf, err := os.ReadFile(file.Name)
 	if err != nil {
 		return false, err
@@ -34,21 +38,22 @@ func (file *File) FileChanged() (bool, error) {
 // WriteStatus writes the status line.
 func (file *File) WriteStatus(row, col int) {
 
+	flag, flagBg := terminal.RoleColors("status.flag")
+
 	if file.MultiCursor.Length() > 1 {
 		status := fmt.Sprintf("%d%s", file.MultiCursor.Length(), file.MultiCursor.GetNavModeShort())
-		file.addToStatus(status, row, &col,
-			terminal.ColorGreen|terminal.AttrReverse|terminal.AttrBold,
-			terminal.ColorDefault|terminal.AttrReverse)
+		fg, bg := terminal.RoleColors("status.cursors")
+		file.addToStatus(status, row, &col, fg, bg)
 	}
 
 	if file.autoIndent {
-		file.addToStatus("->", row, &col, terminal.ColorGreen, terminal.ColorDefault)
+		file.addToStatus("->", row, &col, flag, flagBg)
 	}
 
 	if file.autoFmt {
 		ext := GetFileExt(file.Name)
 		if file.fmtCmd != "" || ext == "go" {
-			file.addToStatus("f", row, &col, terminal.ColorGreen, terminal.ColorDefault)
+			file.addToStatus("f", row, &col, flag, flagBg)
 		}
 	}
 
@@ -62,23 +67,31 @@ func (file *File) WriteStatus(row, col int) {
 		if !file.tabDetect {
 			status += "*"
 		}
-		file.addToStatus(status, row, &col, terminal.ColorGreen, terminal.ColorDefault)
+		file.addToStatus(status, row, &col, flag, flagBg)
 	}
 
 	if !file.tabHealth {
-		file.addToStatus("MixedIndent", row, &col, terminal.ColorRed, terminal.ColorDefault)
+		fg, bg := terminal.RoleColors("status.error")
+		file.addToStatus("MixedIndent", row, &col, fg, bg)
+	}
+
+	if mode := file.safeModeStatus(); mode != "" {
+		fg, bg := terminal.RoleColors("status.warning")
+		file.addToStatus(mode, row, &col, fg, bg)
 	}
 
 	if file.newline != "\n" {
 		status := strings.Replace(file.newline, "\n", "\\n", -1)
 		status = strings.Replace(status, "\r", "\\r", -1)
-		file.addToStatus(status, row, &col, terminal.ColorYellow, terminal.ColorDefault)
+		fg, bg := terminal.RoleColors("status.warning")
+		file.addToStatus(status, row, &col, fg, bg)
 	}
 
 	file.statusMutex.Lock()
 
 	if file.notification != "" {
-		file.addToStatus(file.notification, row, &col, terminal.ColorCyan, terminal.ColorDefault)
+		fg, bg := terminal.RoleColors("status.notification")
+		file.addToStatus(file.notification, row, &col, fg, bg)
 	}
 
 	if file.clearNotification {
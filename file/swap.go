@@ -0,0 +1,292 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/ui"
+)
+
+// swapInterval is how often modified buffers are written to swap files.
+const swapInterval = 5 * time.Second
+
+// Swap is the contents of a swap file. A swap file exists for as long as a
+// file is open, and so doubles as a lock. It holds the buffer contents
+// while the buffer is modified.
+type Swap struct {
+	Path     string    `json:"path"`
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	Time     time.Time `json:"time"`
+	Modified bool      `json:"modified"`
+	Text     string    `json:"text,omitempty"`
+}
+
+// SwapPath returns the swap file path for a file, or an empty string if
+// there is nowhere to put it.
+func SwapPath(name string) string {
+	dir := stateDir()
+	if dir == "" || name == "" {
+		return ""
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		absName = name
+	}
+	hash := sha256.Sum256([]byte(absName))
+	return filepath.Join(dir, "swap", hex.EncodeToString(hash[:8])+".swp")
+}
+
+// ReadSwap reads a swap file.
+func ReadSwap(path string) (Swap, error) {
+	swap := Swap{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return swap, err
+	}
+	err = json.Unmarshal(data, &swap)
+	return swap, err
+}
+
+// Write writes the swap file. It writes to a temporary file first, so that
+// a crash can't leave a half-written swap file.
+func (swap Swap) Write(path string) error {
+	data, err := json.Marshal(swap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Locked returns true if the swap file belongs to another running sith.
+// Processes on other hosts can't be checked, so are assumed to be running.
+func (swap Swap) Locked() bool {
+	host, _ := os.Hostname()
+	if swap.Host != host {
+		return true
+	}
+	return swap.PID != os.Getpid() && processAlive(swap.PID)
+}
+
+// openSwap looks for an existing swap file. If there is none (or it is
+// stale and has nothing to recover), it starts swapping. Otherwise the
+// swap waits for CheckSwap.
+func (file *File) openSwap() {
+	if file.Name == "" || file.Large() {
+		return
+	}
+	file.swapPath = SwapPath(file.Name)
+	if file.swapPath == "" {
+		return
+	}
+	swap, err := ReadSwap(file.swapPath)
+	if err == nil && (swap.Locked() || file.recoverable(swap)) {
+		file.foundSwap = &swap
+		return
+	}
+	file.startSwap()
+}
+
+// recoverable returns true if the swap holds changes that were never saved.
+func (file *File) recoverable(swap Swap) bool {
+	return swap.Modified && swap.Time.After(file.modTime) && swap.Text != file.ToString()
+}
+
+// swapState is what goes into the swap file. It is taken on the goroutine
+// which edits the file (see SyncSwap), and handed to the swap writer.
+type swapState struct {
+	name    string
+	newline string
+	buffer  buffer.Buffer
+	saved   buffer.Buffer
+}
+
+// startSwap writes the swap file, and keeps it up to date in the
+// background.
+func (file *File) startSwap() {
+	file.foundSwap = nil
+	file.swapStop = make(chan struct{})
+	file.WriteSwap()
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(swapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				file.writeSwap()
+			case <-stop:
+				return
+			}
+		}
+	}(file.swapStop)
+}
+
+// SyncSwap hands the file's current contents to the swap writer, which
+// writes them out in the background. It takes constant time, since the
+// buffers share their structure.
+func (file *File) SyncSwap() {
+	state := swapState{
+		name:    file.Name,
+		newline: file.newline,
+		buffer:  file.buffer.Dup(),
+		saved:   file.savedBuffer.Dup(),
+	}
+	file.swapMutex.Lock()
+	file.swapState = state
+	file.swapMutex.Unlock()
+}
+
+// WriteSwap writes the swap file now, if it has changed.
+func (file *File) WriteSwap() {
+	file.SyncSwap()
+	file.writeSwap()
+}
+
+// writeSwap writes the contents last handed over by SyncSwap to the swap
+// file, if they have changed.
+func (file *File) writeSwap() {
+	file.swapMutex.Lock()
+	defer file.swapMutex.Unlock()
+	if file.swapPath == "" || file.swapStop == nil {
+		return
+	}
+	state := file.swapState
+	host, _ := os.Hostname()
+	swap := Swap{
+		Path:     state.name,
+		PID:      os.Getpid(),
+		Host:     host,
+		Time:     time.Now(),
+		Modified: !state.buffer.Equals(&state.saved),
+	}
+	if swap.Modified {
+		swap.Text = state.buffer.ToString(state.newline)
+	}
+	if file.swapWritten && swap.Modified == file.swapModified && swap.Text == file.swapText {
+		return
+	}
+	if swap.Write(file.swapPath) == nil {
+		file.swapWritten = true
+		file.swapModified = swap.Modified
+		file.swapText = swap.Text
+	}
+}
+
+// RemoveSwap stops swapping, and removes the swap file. It is called when
+// a file is closed.
+func (file *File) RemoveSwap() {
+	file.swapMutex.Lock()
+	defer file.swapMutex.Unlock()
+	if file.swapStop == nil {
+		return
+	}
+	close(file.swapStop)
+	file.swapStop = nil
+	if file.swapWritten {
+		os.Remove(file.swapPath)
+		file.swapWritten = false
+	}
+}
+
+// FoundSwap returns a swap file which was found when the file was opened,
+// and which needs attention (see CheckSwap), or nil if there is none.
+func (file *File) FoundSwap() *Swap {
+	return file.foundSwap
+}
+
+// RecoverSwap replaces the buffer contents with those of the found swap
+// file. The file is left modified (i.e. not saved).
+func (file *File) RecoverSwap() {
+	if file.foundSwap == nil {
+		return
+	}
+	text := file.foundSwap.Text
+	file.buffer.ReplaceBuffer(buffer.MakeBuffer(strings.Split(text, file.newline)))
+	file.enforceRowBounds()
+	file.enforceColBounds()
+	file.ForceSnapshot()
+	file.startSwap()
+}
+
+// DiscardSwap discards the found swap file, and starts swapping.
+func (file *File) DiscardSwap() {
+	if file.foundSwap == nil {
+		return
+	}
+	file.startSwap()
+}
+
+// CheckSwap asks the user what to do about a swap file found when the file
+// was opened: either warns that the file is open in another sith, or
+// offers to recover unsaved changes.
+func (file *File) CheckSwap() {
+	<-file.loaded
+	swap := file.foundSwap
+	if swap == nil {
+		return
+	}
+	prompt := ui.MakePrompt(file.screen, file.newKeyboard())
+	if swap.Locked() {
+		question := fmt.Sprintf("%s is open in another sith (pid %d on %s). Edit anyway?",
+			file.Name, swap.PID, swap.Host)
+		ok, _ := prompt.AskYesNo(question)
+		if !ok {
+			// Leave the other sith's swap file alone.
+			file.foundSwap = nil
+			return
+		}
+		if !file.recoverable(*swap) {
+			file.DiscardSwap()
+			return
+		}
+	}
+	for {
+		question := fmt.Sprintf("%s has unsaved changes from %s: (r)ecover, (d)iff, or (x) discard?",
+			file.Name, swap.Time.Format("Jan 2 15:04"))
+		switch prompt.GetRune(question) {
+		case 'r':
+			file.RecoverSwap()
+			file.NotifyUser("Recovered unsaved changes")
+			return
+		case 'd':
+			file.showSwapDiff(*swap)
+		case 'x':
+			file.DiscardSwap()
+			file.NotifyUser("Discarded unsaved changes")
+			return
+		default:
+			file.foundSwap = nil
+			file.NotifyUser("Swap file kept; not swapping " + file.Name)
+			return
+		}
+	}
+}
+
+// showSwapDiff shows how the swap file differs from the file on disk.
+func (file *File) showSwapDiff(swap Swap) {
+	diff := diffLines(bufferLines(file.buffer), strings.Split(swap.Text, file.newline))
+	choices := []string{fmt.Sprintf("@@ line %d @@", diff.Start+1)}
+	for _, line := range bufferLines(file.buffer)[diff.Start : diff.Start+diff.Delete] {
+		choices = append(choices, "- "+line)
+	}
+	for _, line := range diff.Insert {
+		choices = append(choices, "+ "+line)
+	}
+	menu := ui.NewMenu(file.screen, file.newKeyboard())
+	menu.Choose(choices, 0, "")
+	menu.Clear()
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package file
+
+import "syscall"
+
+// processAlive returns true if a process is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,14 @@
+package file
+
+import "os"
+
+// processAlive returns true if a process is running. On windows, finding
+// a process fails if it does not exist.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
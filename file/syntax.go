@@ -0,0 +1,153 @@
+package file
+
+import (
+	"sync"
+
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/syntaxcolor"
+)
+
+const (
+	// syncRows is the most rows whose syntax states are worked out while
+	// drawing the screen. Any more are worked out in the background, and
+	// the screen is redrawn once they are ready.
+	syncRows = 2000
+
+	// aheadRows is how far past the bottom of the screen the states are
+	// worked out (in the background), so that scrolling doesn't wait.
+	aheadRows = 1000
+
+	// chunkRows is how many rows the background work does between
+	// releasing the lock.
+	chunkRows = 500
+)
+
+// syntaxStates holds the syntax state at the start of each row (e.g. inside
+// a block comment). The states are kept up to date incrementally: changes to
+// the buffer invalidate the states from the first changed row on, and states
+// beyond the screen are worked out in the background. It also serializes use
+// of the syntax rules, which are not safe for concurrent use.
+type syntaxStates struct {
+	buffer   *buffer.Buffer
+	rules    *syntaxcolor.SyntaxRules
+	cache    *syntaxcolor.StateCache
+	tabWidth int
+
+	// version is the buffer version the cache is up to date with.
+	version int
+
+	// target is the row the background work goes up to, and waiting is the
+	// row the screen is waiting for (or -1).
+	target  int
+	waiting int
+	running bool
+
+	mutex sync.Mutex
+}
+
+func newSyntaxStates(buff *buffer.Buffer) *syntaxStates {
+	return &syntaxStates{
+		buffer:  buff,
+		cache:   syntaxcolor.NewStateCache(),
+		waiting: -1,
+	}
+}
+
+// reset starts over with new syntax rules.
+func (st *syntaxStates) reset(rules *syntaxcolor.SyntaxRules) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.rules = rules
+	st.cache.Clear()
+	st.version = st.buffer.Version()
+}
+
+// sync invalidates the states made stale by changes to the buffer. The
+// caller must hold the lock.
+func (st *syntaxStates) sync() {
+	row, version := st.buffer.ChangesSince(st.version)
+	if row >= 0 {
+		st.cache.Invalidate(row)
+	}
+	st.version = version
+}
+
+// advance works out the states of up to n more rows, but not past row end.
+// It returns the number of rows whose states are known. The caller must
+// hold the lock.
+func (st *syntaxStates) advance(end, n int) int {
+	if length := st.buffer.Length(); end > length {
+		end = length
+	}
+	for row := st.cache.Len(); row < end && n > 0; row, n = row+1, n-1 {
+		str := st.buffer.GetRowDirect(row).Tabs2spaces(st.tabWidth).ToString()
+		result := st.rules.ColorizeWithState(str, st.cache.GetState(row))
+		st.cache.SetEndState(row, result.EndState)
+	}
+	return st.cache.Len()
+}
+
+// prepare gets the states ready for drawing the rows from top to bottom.
+// If the state at the top of the screen is too far off to work out right
+// away, it is worked out in the background, and flush is called when it is
+// ready.
+func (st *syntaxStates) prepare(top, bottom, tabWidth int, flush func()) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.tabWidth = tabWidth
+	st.sync()
+	if top-st.cache.Len() <= syncRows {
+		st.advance(top, syncRows)
+	}
+	if st.cache.Len() < top {
+		st.waiting = top
+	}
+	st.target = bottom + aheadRows
+	if st.cache.Len() < st.target && st.cache.Len() < st.buffer.Length() && !st.running {
+		st.running = true
+		go st.background(flush)
+	}
+}
+
+// background works out states up to the target, a chunk at a time.
+func (st *syntaxStates) background(flush func()) {
+	for {
+		st.mutex.Lock()
+		st.sync()
+		known := st.advance(st.target, chunkRows)
+		done := known >= st.target || known >= st.buffer.Length()
+		ready := st.waiting >= 0 && (known >= st.waiting || done)
+		if ready {
+			st.waiting = -1
+		}
+		if done {
+			st.running = false
+		}
+		st.mutex.Unlock()
+
+		if ready {
+			flush()
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// colorize colors a row. The start state is taken from the cache if it is
+// known, and is guessed otherwise. Rows which follow on from the known
+// states extend the cache.
+func (st *syntaxStates) colorize(row int, str string, guess syntaxcolor.LineState) (syntaxcolor.ColorResult, syntaxcolor.LineState) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	start := guess
+	known := row <= st.cache.Len()
+	if known {
+		start = st.cache.GetState(row)
+	}
+	result := st.rules.ColorizeWithState(str, start)
+	if known {
+		st.cache.SetEndState(row, result.EndState)
+	}
+	return result, start
+}
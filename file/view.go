@@ -0,0 +1,87 @@
+package file
+
+import (
+	"github.com/wx13/sith/file/cursor"
+	"github.com/wx13/sith/terminal"
+)
+
+// View is the part of a file's state that belongs to a window onto the
+// file: the screen it draws to, the scroll offsets, and the cursors. Two
+// windows onto the same file share the rest of the file, but each has its
+// own view.
+type View struct {
+	screen      *terminal.Screen
+	MultiCursor cursor.MultiCursor
+	rowOffset   int
+	colOffset   int
+
+	// marking is true while a selection mark is set.
+	marking bool
+
+	// version is the buffer version the view was last brought up to date
+	// with (see syncView).
+	version int
+}
+
+// SetScreen sets the screen that the view draws to.
+func (view *View) SetScreen(screen *terminal.Screen) {
+	view.screen = screen
+}
+
+// GetView returns the file's current view.
+func (file *File) GetView() *View {
+	return file.View
+}
+
+// NewView returns a new view of the file, starting out as a copy of the
+// current one.
+func (file *File) NewView() *View {
+	view := *file.View
+	view.MultiCursor = file.MultiCursor.Dup()
+	view.version = file.buffer.Version()
+	return &view
+}
+
+// SetView makes the view the file's current view. The view catches up with
+// any edits made through the other views.
+func (file *File) SetView(view *View) {
+	if view == file.View {
+		return
+	}
+	file.View.version = file.buffer.Version()
+	file.syncView(view)
+	file.View = view
+}
+
+// WithView returns the file, showing the view, for display purposes. Only
+// the current view may be used for editing.
+func (file *File) WithView(view *View) *File {
+	if view == file.View {
+		return file
+	}
+	file.syncView(view)
+	return &File{fileData: file.fileData, View: view}
+}
+
+// syncView moves a view's cursors and scroll position past the edits made
+// to the buffer since the view was last current.
+func (file *File) syncView(view *View) {
+	shifts, version, ok := file.buffer.ShiftsSince(view.version)
+	if ok && len(shifts) == 0 {
+		return
+	}
+	view.version = version
+	for _, shift := range shifts {
+		view.MultiCursor.ShiftRows(shift.Apply)
+		view.rowOffset = shift.Apply(view.rowOffset)
+	}
+	f := &File{fileData: file.fileData, View: view}
+	f.enforceRowBounds()
+	f.enforceColBounds()
+	view.rowOffset = min(view.rowOffset, max(file.buffer.Length()-1, 0))
+}
+
+// SetScreen changes the screen that the file draws to.
+func (file *File) SetScreen(screen *terminal.Screen) {
+	file.screen = screen
+}
@@ -0,0 +1,259 @@
+package file
+
+import (
+	"strings"
+
+	"github.com/wx13/sith/file/buffer"
+	"github.com/wx13/sith/syntaxcolor"
+)
+
+// screenRow is one row of the file as shown on the screen: the part of a
+// buffer row from start to end (positions in the line with tabs expanded),
+// shown indent columns in. Without soft wrapping, each buffer row is one
+// screen row.
+type screenRow struct {
+	row    int
+	start  int
+	end    int
+	indent int
+}
+
+// SoftWrap returns true if long lines are wrapped on screen.
+func (file *File) SoftWrap() bool {
+	return file.softWrap
+}
+
+// ToggleSoftWrap turns soft wrapping on or off.
+func (file *File) ToggleSoftWrap() {
+	file.softWrap = !file.softWrap
+	file.colOffset = 0
+	if file.softWrap {
+		file.NotifyUser("Soft wrap on")
+	} else {
+		file.NotifyUser("Soft wrap off")
+	}
+}
+
+// wrapping returns true if the file is soft wrapped, and has been drawn
+// (so that the wrap width is known).
+func (file *File) wrapping() bool {
+	return file.softWrap && file.wrapWidth > 0
+}
+
+// wrapRow splits a buffer row into screen rows.
+func (file *File) wrapRow(row int) []screenRow {
+	line := file.buffer.GetRowDirect(row)
+	length := line.TabRunePos(line.Length(), file.tabWidth)
+	starts, indent := line.Wrap(file.wrapWidth, file.tabWidth)
+	rows := make([]screenRow, len(starts))
+	for k, start := range starts {
+		rows[k] = screenRow{row: row, start: start, end: length}
+		if k > 0 {
+			rows[k].indent = indent
+			rows[k-1].end = start
+		}
+	}
+	return rows
+}
+
+// screenRows returns the screen rows which fit on the screen, starting at
+// the row offset.
+func (file *File) screenRows(nRows, nCols int) []screenRow {
+	rows := []screenRow{}
+	for row := file.rowOffset; row < file.buffer.Length() && len(rows) < nRows; row++ {
+		if !file.wrapping() {
+			rows = append(rows, screenRow{
+				row:   row,
+				start: file.colOffset,
+				end:   file.colOffset + nCols,
+			})
+			continue
+		}
+		rows = append(rows, file.wrapRow(row)...)
+	}
+	if len(rows) > nRows {
+		rows = rows[:nRows]
+	}
+	return rows
+}
+
+// wrapIndex returns the index of the screen row which contains a position
+// (in the line with tabs expanded). A position at a wrap point belongs to
+// the start of the next screen row.
+func wrapIndex(rows []screenRow, pos int) int {
+	k := 0
+	for k+1 < len(rows) && rows[k+1].start <= pos {
+		k++
+	}
+	return k
+}
+
+// wrappedCursor returns the screen position of a (row, col) position in a
+// soft wrapped file.
+func (file *File) wrappedCursor(row, col int) (int, int) {
+	line := file.buffer.GetRowDirect(row)
+	pos := line.TabRunePos(col, file.tabWidth)
+	if row < file.rowOffset {
+		return row - file.rowOffset, pos
+	}
+
+	// Count the screen rows above the cursor (but don't bother counting
+	// far past the bottom of the screen).
+	_, maxRows := file.screen.Size()
+	screenRow := 0
+	for r := file.rowOffset; r < row; r++ {
+		if screenRow > maxRows {
+			return screenRow + row - r, pos
+		}
+		screenRow += len(file.wrapRow(r))
+	}
+
+	rows := file.wrapRow(row)
+	k := wrapIndex(rows, pos)
+	text := line.Tabs2spaces(file.tabWidth).Slice(rows[k].start, pos).ToString()
+	return screenRow + k, rows[k].indent + file.screen.StringDispLen(text)
+}
+
+// updateWrappedOffsets scrolls a soft wrapped file so that the cursor is on
+// the screen.
+func (file *File) updateWrappedOffsets(nRows int) {
+	file.colOffset = 0
+	row, col := file.MultiCursor.GetRowCol(0)
+	if row < file.rowOffset {
+		file.rowOffset = row
+		return
+	}
+
+	// Work up from the cursor, to find the top row which keeps it on the
+	// screen.
+	line := file.buffer.GetRowDirect(row)
+	height := wrapIndex(file.wrapRow(row), line.TabRunePos(col, file.tabWidth)) + 1
+	top := row
+	for top > file.rowOffset {
+		h := len(file.wrapRow(top - 1))
+		if height+h > nRows {
+			break
+		}
+		height += h
+		top--
+	}
+	file.rowOffset = top
+}
+
+// runeCol converts a position in a line with tabs expanded to a column in
+// the line.
+func runeCol(line buffer.Line, pos, tabwidth int) int {
+	width := 0
+	for col, r := range line.Chars() {
+		if r == '\t' {
+			width += tabwidth
+		} else {
+			width++
+		}
+		if width > pos {
+			return col
+		}
+	}
+	return line.Length()
+}
+
+// moveWrapped moves a cursor n screen rows down (or up, if n is negative)
+// in a soft wrapped file, keeping to the wanted column where possible.
+func (file *File) moveWrapped(idx, n int) {
+	row, col, colwant := file.MultiCursor.GetCursorRCC(idx)
+	line := file.buffer.GetRowDirect(row)
+
+	// The wanted column can be past the end of the line.
+	want := line.TabRunePos(line.Length(), file.tabWidth) + colwant - line.Length()
+	if colwant <= line.Length() {
+		want = line.TabRunePos(colwant, file.tabWidth)
+	}
+	rows := file.wrapRow(row)
+	k := wrapIndex(rows, line.TabRunePos(col, file.tabWidth))
+	screenCol := want - rows[k].start + rows[k].indent
+	if want < rows[k].start {
+		screenCol = line.TabRunePos(col, file.tabWidth) - rows[k].start + rows[k].indent
+	}
+
+	for ; n > 0; n-- {
+		if k+1 < len(rows) {
+			k++
+		} else if row+1 < file.buffer.Length() {
+			row++
+			rows = file.wrapRow(row)
+			k = 0
+		} else {
+			break
+		}
+	}
+	for ; n < 0; n++ {
+		if k > 0 {
+			k--
+		} else if row > 0 {
+			row--
+			rows = file.wrapRow(row)
+			k = len(rows) - 1
+		} else {
+			break
+		}
+	}
+
+	line = file.buffer.GetRowDirect(row)
+	pos := rows[k].start + screenCol - rows[k].indent
+	if pos < rows[k].start {
+		pos = rows[k].start
+	}
+	overflow := 0
+	if k+1 < len(rows) {
+		// Stay on this screen row, rather than the start of the next.
+		if pos >= rows[k].end {
+			pos = rows[k].end - 1
+		}
+	} else if pos > rows[k].end {
+		overflow = pos - rows[k].end
+		pos = rows[k].end
+	}
+	col = runeCol(line, pos, file.tabWidth)
+	file.MultiCursor.SetCursor(idx, row, col, col+overflow)
+}
+
+// wrappedSlice returns the text of the screen rows, for a soft wrapped file.
+func (file *File) wrappedSlice(nRows, nCols int) []string {
+	strs := []string{}
+	for _, sr := range file.screenRows(nRows, nCols) {
+		line := file.buffer.GetRowDirect(sr.row).Tabs2spaces(file.tabWidth)
+		strs = append(strs, strings.Repeat(" ", sr.indent)+line.Slice(sr.start, sr.end).ToString())
+	}
+	return strs
+}
+
+// clipColors restricts line colors to the part of the line from start to end.
+func clipColors(colors []syntaxcolor.LineColor, start, end int) []syntaxcolor.LineColor {
+	clipped := []syntaxcolor.LineColor{}
+	for _, lc := range colors {
+		if lc.Start < start {
+			lc.Start = start
+		}
+		if lc.End > end {
+			lc.End = end
+		}
+		if lc.End > lc.Start {
+			clipped = append(clipped, lc)
+		}
+	}
+	return clipped
+}
+
+// rowSegments returns the screen row on which a buffer row starts, and the
+// screen rows it is split into.
+func (file *File) rowSegments(row, nCols int) (int, []screenRow) {
+	if !file.wrapping() {
+		return row - file.rowOffset, []screenRow{{
+			row:   row,
+			start: file.colOffset,
+			end:   file.colOffset + nCols,
+		}}
+	}
+	screenRow, _ := file.wrappedCursor(row, 0)
+	return screenRow, file.wrapRow(row)
+}
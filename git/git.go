@@ -0,0 +1,141 @@
+// Package git reads and updates git repositories, via the git command line
+// tool, on behalf of the files being edited.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotTracked is returned when a file has no version in the repository.
+var ErrNotTracked = errors.New("not tracked by git")
+
+// run runs a git command in dir, with the given standard input.
+func run(dir, stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", err
+		}
+		return "", errors.New(msg)
+	}
+	return stdout.String(), nil
+}
+
+// split returns the directory and base name of a file.
+func split(path string) (string, string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	return filepath.Dir(absPath), filepath.Base(absPath)
+}
+
+// Available returns true if the git command can be found.
+func Available() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Show returns the contents of a file, as of HEAD, or (if index is true) as
+// staged in the index.
+func Show(path string, index bool) (string, error) {
+	dir, base := split(path)
+	rev := "HEAD:./" + base
+	if index {
+		rev = ":./" + base
+	}
+	out, err := run(dir, "", "show", rev)
+	if err != nil {
+		return "", ErrNotTracked
+	}
+	return out, nil
+}
+
+// Stage replaces the staged version of a file with the given contents,
+// leaving the working tree alone.
+func Stage(path, contents string) error {
+	dir, base := split(path)
+	sha, err := run(dir, contents, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	mode := "100644"
+	if out, err := run(dir, "", "ls-files", "-s", "--", base); err == nil {
+		if fields := strings.Fields(out); len(fields) > 0 {
+			mode = fields[0]
+		}
+	}
+	cacheinfo := fmt.Sprintf("%s,%s,%s", mode, strings.TrimSpace(sha), base)
+	_, err = run(dir, "", "update-index", "--add", "--cacheinfo", cacheinfo)
+	return err
+}
+
+// BlameInfo describes the commit which last changed a line.
+type BlameInfo struct {
+	Commit  string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// Uncommitted returns true if the line has not been committed yet.
+func (info BlameInfo) Uncommitted() bool {
+	return strings.Trim(info.Commit, "0") == ""
+}
+
+// String formats the blame info on one line.
+func (info BlameInfo) String() string {
+	if info.Uncommitted() {
+		return "Not committed yet"
+	}
+	commit := info.Commit
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	return fmt.Sprintf("%s %s %s %s", commit, info.Author,
+		info.Time.Format("2006-01-02"), info.Summary)
+}
+
+// Blame returns the commit which last changed a (zero-based) line of a
+// file, given the file's current contents.
+func Blame(path string, line int, contents string) (BlameInfo, error) {
+	dir, base := split(path)
+	lines := fmt.Sprintf("%d,%d", line+1, line+1)
+	out, err := run(dir, contents, "blame", "--porcelain", "-L", lines, "--contents", "-", "--", base)
+	if err != nil {
+		return BlameInfo{}, err
+	}
+
+	info := BlameInfo{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		text := scanner.Text()
+		key, value, _ := strings.Cut(text, " ")
+		switch {
+		case info.Commit == "":
+			info.Commit = key
+		case key == "author":
+			info.Author = value
+		case key == "author-time":
+			seconds, _ := strconv.ParseInt(value, 10, 64)
+			info.Time = time.Unix(seconds, 0)
+		case key == "summary":
+			info.Summary = value
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,81 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/wx13/sith/git"
+)
+
+// makeRepo creates a repository with one committed file, and returns the
+// file's path.
+func makeRepo(t *testing.T) string {
+	if !git.Available() {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	gitCmd := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	gitCmd("init", "-q")
+	gitCmd("config", "user.name", "Tester")
+	gitCmd("config", "user.email", "tester@example.com")
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("one\ntwo\nthree\n"), 0644)
+	gitCmd("add", "a.txt")
+	gitCmd("commit", "-q", "-m", "Initial commit")
+	return name
+}
+
+func TestShowAndStage(t *testing.T) {
+	name := makeRepo(t)
+
+	head, err := git.Show(name, false)
+	if err != nil || head != "one\ntwo\nthree\n" {
+		t.Errorf("Show HEAD: %q, %v", head, err)
+	}
+
+	if err := git.Stage(name, "one\n2\nthree\n"); err != nil {
+		t.Fatal(err)
+	}
+	index, _ := git.Show(name, true)
+	if index != "one\n2\nthree\n" {
+		t.Errorf("Show index: %q", index)
+	}
+	head, _ = git.Show(name, false)
+	if head != "one\ntwo\nthree\n" {
+		t.Errorf("HEAD should be unchanged: %q", head)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("working tree should be unchanged: %q", data)
+	}
+
+	untracked := filepath.Join(filepath.Dir(name), "b.txt")
+	os.WriteFile(untracked, []byte("b\n"), 0644)
+	if _, err := git.Show(untracked, false); err != git.ErrNotTracked {
+		t.Errorf("untracked file: %v", err)
+	}
+}
+
+func TestBlame(t *testing.T) {
+	name := makeRepo(t)
+
+	info, err := git.Blame(name, 1, "one\ntwo\nthree\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Uncommitted() || info.Author != "Tester" || info.Summary != "Initial commit" {
+		t.Errorf("unexpected blame: %+v", info)
+	}
+
+	info, _ = git.Blame(name, 1, "one\nnew\ntwo\nthree\n")
+	if !info.Uncommitted() {
+		t.Errorf("new line should be uncommitted: %+v", info)
+	}
+}
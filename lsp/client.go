@@ -0,0 +1,305 @@
+// Package lsp is a minimal Language Server Protocol client. It talks
+// JSON-RPC to a language server over the server's stdin/stdout.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout is how long to wait for the server to answer a request.
+var Timeout = 5 * time.Second
+
+// ErrClosed is returned for requests to a server which has exited.
+var ErrClosed = errors.New("language server closed")
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  interface{}      `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+}
+
+type incoming struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type response struct {
+	result json.RawMessage
+	err    error
+}
+
+// Client is a connection to a running language server.
+type Client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	nextID     int
+	pending    map[int]chan response
+	done       chan struct{}
+
+	diagnostics   map[string][]Diagnostic
+	onDiagnostics func(uri string)
+}
+
+// Start runs a language server command and initializes it for the root
+// directory. onDiagnostics (which may be nil) is called whenever the server
+// publishes new diagnostics.
+func Start(command, rootDir string, onDiagnostics func(uri string)) (*Client, error) {
+	args := regexp.MustCompile(`\s+`).Split(strings.TrimSpace(command), -1)
+	if len(args) == 0 || args[0] == "" {
+		return nil, errors.New("empty language server command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = rootDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	client := &Client{
+		cmd:           cmd,
+		stdin:         stdin,
+		stdout:        bufio.NewReader(stdout),
+		pending:       map[int]chan response{},
+		done:          make(chan struct{}),
+		diagnostics:   map[string][]Diagnostic{},
+		onDiagnostics: onDiagnostics,
+	}
+	go client.listen()
+
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   PathToURI(rootDir),
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{},
+				"completion":         map[string]interface{}{},
+				"hover":              map[string]interface{}{"contentFormat": []string{"plaintext"}},
+				"definition":         map[string]interface{}{},
+				"publishDiagnostics": map[string]interface{}{},
+			},
+		},
+	}
+	if _, err := client.Request(context.Background(), "initialize", params); err != nil {
+		client.Close()
+		return nil, err
+	}
+	client.Notify("initialized", map[string]interface{}{})
+	return client, nil
+}
+
+// Request sends a request and waits for the result. If the context is
+// cancelled first, the server is asked to cancel the request too.
+func (client *Client) Request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	client.nextID++
+	id := client.nextID
+	ch := make(chan response, 1)
+	client.pending[id] = ch
+	client.mutex.Unlock()
+
+	defer func() {
+		client.mutex.Lock()
+		delete(client.pending, id)
+		client.mutex.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.Itoa(id))
+	err := client.write(message{ID: &rawID, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp.result, resp.err
+	case <-client.done:
+		return nil, ErrClosed
+	case <-time.After(Timeout):
+		return nil, fmt.Errorf("%s: timed out", method)
+	case <-ctx.Done():
+		client.Notify("$/cancelRequest", map[string]interface{}{"id": id})
+		return nil, ctx.Err()
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (client *Client) Notify(method string, params interface{}) error {
+	return client.write(message{Method: method, Params: params})
+}
+
+// Diagnostics returns the latest diagnostics published for a document.
+func (client *Client) Diagnostics(uri string) []Diagnostic {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.diagnostics[uri]
+}
+
+// Shutdown asks the server to exit, and kills it if it does not.
+func (client *Client) Shutdown() {
+	select {
+	case <-client.done:
+		return
+	default:
+	}
+	client.Request(context.Background(), "shutdown", nil)
+	client.Notify("exit", nil)
+	select {
+	case <-client.done:
+	case <-time.After(Timeout):
+	}
+	client.Close()
+}
+
+// Close kills the server.
+func (client *Client) Close() {
+	client.stdin.Close()
+	if client.cmd.Process != nil {
+		client.cmd.Process.Kill()
+	}
+}
+
+// write sends a message with a Content-Length header.
+func (client *Client) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+	select {
+	case <-client.done:
+		return ErrClosed
+	default:
+	}
+	return WriteMessage(client.stdin, msg)
+}
+
+// listen reads messages from the server until it exits.
+func (client *Client) listen() {
+	defer func() {
+		close(client.done)
+		client.cmd.Wait()
+	}()
+	for {
+		data, err := ReadMessage(client.stdout)
+		if err != nil {
+			return
+		}
+		msg := incoming{}
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			// A request from the server (e.g. workspace/configuration). We
+			// don't support any, but answer so the server doesn't wait.
+			client.write(message{ID: msg.ID, Result: json.RawMessage("null")})
+		case msg.Method != "":
+			client.handleNotification(msg.Method, msg.Params)
+		case msg.ID != nil:
+			client.handleResponse(msg)
+		}
+	}
+}
+
+func (client *Client) handleResponse(msg incoming) {
+	id, err := strconv.Atoi(string(*msg.ID))
+	if err != nil {
+		return
+	}
+	client.mutex.Lock()
+	ch, ok := client.pending[id]
+	client.mutex.Unlock()
+	if !ok {
+		return
+	}
+	resp := response{result: msg.Result}
+	if msg.Error != nil {
+		resp.err = errors.New(msg.Error.Message)
+	}
+	ch <- resp
+}
+
+func (client *Client) handleNotification(method string, raw json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	params := publishDiagnosticsParams{}
+	if json.Unmarshal(raw, &params) != nil {
+		return
+	}
+	client.mutex.Lock()
+	client.diagnostics[params.URI] = params.Diagnostics
+	client.mutex.Unlock()
+	if client.onDiagnostics != nil {
+		client.onDiagnostics(params.URI)
+	}
+}
+
+// ReadMessage reads one Content-Length framed message.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// WriteMessage writes one Content-Length framed message.
+func WriteMessage(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
@@ -0,0 +1,122 @@
+package lsp
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Document is a file which the server is tracking. The whole text is sent
+// on every change (full document sync).
+type Document struct {
+	client     *Client
+	URI        string
+	languageID string
+	version    int
+	text       string
+	mutex      sync.Mutex
+}
+
+// Open tells the server that a document has been opened.
+func (client *Client) Open(path, languageID, text string) *Document {
+	doc := &Document{
+		client:     client,
+		URI:        PathToURI(path),
+		languageID: languageID,
+		version:    1,
+		text:       text,
+	}
+	client.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": textDocumentItem{
+			URI:        doc.URI,
+			LanguageID: languageID,
+			Version:    doc.version,
+			Text:       text,
+		},
+	})
+	return doc
+}
+
+// Change sends the new text of the document to the server, if it has
+// changed. It returns true if the text had changed.
+func (doc *Document) Change(text string) bool {
+	doc.mutex.Lock()
+	defer doc.mutex.Unlock()
+	if text == doc.text {
+		return false
+	}
+	doc.text = text
+	doc.version++
+	doc.client.Notify("textDocument/didChange", didChangeParams{
+		TextDocument:   textDocumentIdentifier{URI: doc.URI, Version: doc.version},
+		ContentChanges: []contentChange{{Text: text}},
+	})
+	return true
+}
+
+// Save tells the server that the document has been saved.
+func (doc *Document) Save() {
+	doc.client.Notify("textDocument/didSave", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: doc.URI},
+	})
+}
+
+// Close tells the server that the document has been closed.
+func (doc *Document) Close() {
+	doc.client.Notify("textDocument/didClose", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: doc.URI},
+	})
+}
+
+// Version returns the document version last sent to the server.
+func (doc *Document) Version() int {
+	doc.mutex.Lock()
+	defer doc.mutex.Unlock()
+	return doc.version
+}
+
+// Diagnostics returns the latest diagnostics for the document.
+func (doc *Document) Diagnostics() []Diagnostic {
+	return doc.client.Diagnostics(doc.URI)
+}
+
+// Completion asks for completions at a row and (rune) column.
+func (doc *Document) Completion(ctx context.Context, row, col int) ([]CompletionItem, error) {
+	result, err := doc.client.Request(ctx, "textDocument/completion", doc.position(row, col))
+	if err != nil {
+		return nil, err
+	}
+	return parseCompletion(result), nil
+}
+
+// Definition asks where the symbol at a row and (rune) column is defined.
+func (doc *Document) Definition(ctx context.Context, row, col int) ([]Location, error) {
+	result, err := doc.client.Request(ctx, "textDocument/definition", doc.position(row, col))
+	if err != nil {
+		return nil, err
+	}
+	return parseLocations(result), nil
+}
+
+// Hover asks for information about the symbol at a row and (rune) column.
+func (doc *Document) Hover(ctx context.Context, row, col int) (string, error) {
+	result, err := doc.client.Request(ctx, "textDocument/hover", doc.position(row, col))
+	if err != nil {
+		return "", err
+	}
+	return parseHover(result), nil
+}
+
+// position converts a rune position to an LSP (UTF-16) position.
+func (doc *Document) position(row, col int) textDocumentPosition {
+	doc.mutex.Lock()
+	lines := strings.Split(doc.text, "\n")
+	doc.mutex.Unlock()
+	if row < len(lines) {
+		col = UTF16Col(lines[row], col)
+	}
+	return textDocumentPosition{
+		TextDocument: textDocumentIdentifier{URI: doc.URI},
+		Position:     Position{Line: row, Character: col},
+	}
+}
@@ -0,0 +1,207 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wx13/sith/lsp"
+)
+
+// TestFakeServer is not a real test: when SITH_FAKE_LSP is set, the test
+// binary runs as a tiny language server, for the other tests to talk to.
+func TestFakeServer(t *testing.T) {
+	if os.Getenv("SITH_FAKE_LSP") == "" {
+		return
+	}
+	fakeServer()
+	os.Exit(0)
+}
+
+func fakeServer() {
+	in := bufio.NewReader(os.Stdin)
+	reply := func(id json.RawMessage, result interface{}) {
+		lsp.WriteMessage(os.Stdout, map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+	}
+	notify := func(method string, params interface{}) {
+		lsp.WriteMessage(os.Stdout, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	}
+	// Publish an error for every line containing "ERROR".
+	diagnose := func(uri, text string) {
+		diags := []lsp.Diagnostic{}
+		for row, line := range strings.Split(text, "\n") {
+			if col := strings.Index(line, "ERROR"); col >= 0 {
+				diags = append(diags, lsp.Diagnostic{
+					Range:    lsp.Range{Start: lsp.Position{Line: row, Character: col}},
+					Severity: lsp.SeverityError,
+					Message:  "bad thing",
+				})
+			}
+		}
+		notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diags})
+	}
+	for {
+		data, err := lsp.ReadMessage(in)
+		if err != nil {
+			return
+		}
+		msg := struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+				Position lsp.Position `json:"position"`
+			} `json:"params"`
+		}{}
+		json.Unmarshal(data, &msg)
+		uri := msg.Params.TextDocument.URI
+		pos := msg.Params.Position
+		switch msg.Method {
+		case "initialize":
+			reply(msg.ID, map[string]interface{}{"capabilities": map[string]interface{}{}})
+		case "textDocument/didOpen":
+			diagnose(uri, msg.Params.TextDocument.Text)
+		case "textDocument/didChange":
+			diagnose(uri, msg.Params.ContentChanges[0].Text)
+		case "textDocument/completion":
+			reply(msg.ID, map[string]interface{}{
+				"isIncomplete": false,
+				"items":        []map[string]string{{"label": "Println"}, {"label": "Printf", "insertText": "Printf"}},
+			})
+		case "textDocument/definition":
+			reply(msg.ID, []map[string]interface{}{{"targetUri": uri, "targetSelectionRange": lsp.Range{Start: pos}}})
+		case "textDocument/hover":
+			if pos.Line == 99 {
+				// Never answer, so that the request has to be cancelled.
+				break
+			}
+			value := fmt.Sprintf("hover at %d:%d", pos.Line, pos.Character)
+			reply(msg.ID, map[string]interface{}{"contents": map[string]string{"kind": "plaintext", "value": value}})
+		case "shutdown":
+			reply(msg.ID, nil)
+		case "exit":
+			return
+		}
+	}
+}
+
+func startFake(t *testing.T, diagChan chan string) *lsp.Client {
+	t.Setenv("SITH_FAKE_LSP", "1")
+	cmd := os.Args[0] + " -test.run=^TestFakeServer$"
+	client, err := lsp.Start(cmd, ".", func(uri string) { diagChan <- uri })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func waitDiagnostics(t *testing.T, diagChan chan string, doc *lsp.Document) []lsp.Diagnostic {
+	select {
+	case uri := <-diagChan:
+		if uri != doc.URI {
+			t.Errorf("diagnostics for %s, expected %s", uri, doc.URI)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no diagnostics published")
+	}
+	return doc.Diagnostics()
+}
+
+func TestClient(t *testing.T) {
+	diagChan := make(chan string, 10)
+	client := startFake(t, diagChan)
+	defer client.Shutdown()
+
+	doc := client.Open("foo.go", "go", "package foo\n\nERROR\n")
+	diags := waitDiagnostics(t, diagChan, doc)
+	if len(diags) != 1 || diags[0].Range.Start.Line != 2 || diags[0].Severity != lsp.SeverityError {
+		t.Errorf("bad diagnostics: %+v", diags)
+	}
+
+	if doc.Change("package foo\n\nERROR\n") {
+		t.Error("unchanged text should not be sent")
+	}
+	if !doc.Change("package foo\nERROR\n\n// ERROR\n") || doc.Version() != 2 {
+		t.Error("changed text should be sent with a new version")
+	}
+	diags = waitDiagnostics(t, diagChan, doc)
+	if len(diags) != 2 || diags[0].Range.Start.Line != 1 || diags[1].Range.Start.Character != 3 {
+		t.Errorf("bad diagnostics: %+v", diags)
+	}
+
+	ctx := context.Background()
+	items, err := doc.Completion(ctx, 0, 3)
+	if err != nil || len(items) != 2 || items[0].Text() != "Println" {
+		t.Errorf("bad completion: %+v, %v", items, err)
+	}
+
+	locs, err := doc.Definition(ctx, 3, 4)
+	if err != nil || len(locs) != 1 || locs[0].URI != doc.URI || locs[0].Range.Start.Line != 3 {
+		t.Errorf("bad definition: %+v, %v", locs, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := doc.Hover(ctx, 99, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+}
+
+func TestUnicodePositions(t *testing.T) {
+	diagChan := make(chan string, 10)
+	client := startFake(t, diagChan)
+	defer client.Shutdown()
+
+	// The emoji is one rune, but two UTF-16 code units.
+	doc := client.Open("foo.txt", "plaintext", "a😀bc\n")
+	hover, err := doc.Hover(context.Background(), 0, 3)
+	if err != nil || hover != "hover at 0:4" {
+		t.Errorf("bad hover: %q, %v", hover, err)
+	}
+
+	if col := lsp.UTF16Col("a😀bc", 3); col != 4 {
+		t.Errorf("UTF16Col: expected 4, got %d", col)
+	}
+	if col := lsp.RuneCol("a😀bc", 4); col != 3 {
+		t.Errorf("RuneCol: expected 3, got %d", col)
+	}
+}
+
+func TestReadWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	lsp.WriteMessage(&buf, map[string]string{"method": "héllo"})
+	lsp.WriteMessage(&buf, []int{1, 2})
+	r := bufio.NewReader(&buf)
+	for _, expected := range []string{`{"method":"héllo"}`, `[1,2]`} {
+		data, err := lsp.ReadMessage(r)
+		if err != nil || string(data) != expected {
+			t.Errorf("expected %s, got %s (%v)", expected, data, err)
+		}
+	}
+	if _, err := lsp.ReadMessage(r); err == nil {
+		t.Error("expected an error at end of input")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := lsp.PathToURI("/tmp/a b/c.go")
+	if uri != "file:///tmp/a%20b/c.go" {
+		t.Errorf("bad uri: %s", uri)
+	}
+	if path := lsp.URIToPath(uri); path != "/tmp/a b/c.go" {
+		t.Errorf("bad path: %s", path)
+	}
+}
@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"sync"
+)
+
+// Manager starts language servers on demand, one per server command, and
+// keeps them running until Shutdown.
+type Manager struct {
+	rootDir       string
+	onDiagnostics func(uri string)
+
+	mutex   sync.Mutex
+	clients map[string]*Client
+	errs    map[string]error
+}
+
+// NewManager creates a manager for servers rooted at rootDir.
+func NewManager(rootDir string, onDiagnostics func(uri string)) *Manager {
+	return &Manager{
+		rootDir:       rootDir,
+		onDiagnostics: onDiagnostics,
+		clients:       map[string]*Client{},
+		errs:          map[string]error{},
+	}
+}
+
+// Client returns the client for a server command, starting the server if
+// need be. A server which fails to start is not retried.
+func (manager *Manager) Client(command string) (*Client, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if client, ok := manager.clients[command]; ok {
+		return client, nil
+	}
+	if err, ok := manager.errs[command]; ok {
+		return nil, err
+	}
+	client, err := Start(command, manager.rootDir, manager.onDiagnostics)
+	if err != nil {
+		manager.errs[command] = err
+		return nil, err
+	}
+	manager.clients[command] = client
+	return client, nil
+}
+
+// Shutdown stops all the servers.
+func (manager *Manager) Shutdown() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	var wg sync.WaitGroup
+	for _, client := range manager.clients {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			client.Shutdown()
+		}(client)
+	}
+	wg.Wait()
+	manager.clients = map[string]*Client{}
+}
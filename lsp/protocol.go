@@ -0,0 +1,243 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// Position is a zero-based line and (UTF-16) character offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of text between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range within a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Path returns the file path of the location.
+func (loc Location) Path() string {
+	return URIToPath(loc.URI)
+}
+
+// Diagnostic severities.
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+// Diagnostic is an error or warning reported by the server.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// CompletionItem is a completion suggestion.
+type CompletionItem struct {
+	Label      string `json:"label"`
+	Detail     string `json:"detail,omitempty"`
+	InsertText string `json:"insertText,omitempty"`
+	FilterText string `json:"filterText,omitempty"`
+	TextEdit   *struct {
+		NewText string `json:"newText"`
+	} `json:"textEdit,omitempty"`
+}
+
+// Text returns the text to insert for the completion.
+func (item CompletionItem) Text() string {
+	if item.TextEdit != nil && item.TextEdit.NewText != "" {
+		return item.TextEdit.NewText
+	}
+	if item.InsertText != "" {
+		return item.InsertText
+	}
+	return item.Label
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version,omitempty"`
+}
+
+type textDocumentPosition struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type contentChange struct {
+	Text string `json:"text"`
+}
+
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []contentChange        `json:"contentChanges"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// parseCompletion decodes a completion result, which may be a list of items
+// or a CompletionList.
+func parseCompletion(raw json.RawMessage) []CompletionItem {
+	items := []CompletionItem{}
+	if json.Unmarshal(raw, &items) == nil {
+		return items
+	}
+	list := struct {
+		Items []CompletionItem `json:"items"`
+	}{}
+	json.Unmarshal(raw, &list)
+	return list.Items
+}
+
+// parseLocations decodes a definition result, which may be a single
+// location, a list of locations, or a list of location links.
+func parseLocations(raw json.RawMessage) []Location {
+	loc := Location{}
+	if json.Unmarshal(raw, &loc) == nil && loc.URI != "" {
+		return []Location{loc}
+	}
+	links := []struct {
+		Location
+		TargetURI   string `json:"targetUri"`
+		TargetRange Range  `json:"targetSelectionRange"`
+	}{}
+	json.Unmarshal(raw, &links)
+	locs := []Location{}
+	for _, link := range links {
+		if link.TargetURI != "" {
+			locs = append(locs, Location{URI: link.TargetURI, Range: link.TargetRange})
+		} else if link.URI != "" {
+			locs = append(locs, link.Location)
+		}
+	}
+	return locs
+}
+
+// parseHover decodes the contents of a hover result, which may be markup,
+// a marked string, or a list of marked strings.
+func parseHover(raw json.RawMessage) string {
+	hover := struct {
+		Contents json.RawMessage `json:"contents"`
+	}{}
+	if json.Unmarshal(raw, &hover) != nil || len(hover.Contents) == 0 {
+		return ""
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(hover.Contents, &parts) != nil {
+		parts = []json.RawMessage{hover.Contents}
+	}
+	strs := []string{}
+	for _, part := range parts {
+		str := ""
+		markup := struct {
+			Value string `json:"value"`
+		}{}
+		if json.Unmarshal(part, &str) != nil && json.Unmarshal(part, &markup) == nil {
+			str = markup.Value
+		}
+		str = strings.TrimSpace(str)
+		if str != "" {
+			strs = append(strs, str)
+		}
+	}
+	return strings.Join(strs, "\n")
+}
+
+// PathToURI converts a file path to a file:// URI.
+func PathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return u.String()
+}
+
+// URIToPath converts a file:// URI to a file path.
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// UTF16Col converts a rune column in a line to a UTF-16 column.
+func UTF16Col(line string, col int) int {
+	runes := []rune(line)
+	if col > len(runes) {
+		col = len(runes)
+	}
+	return len(utf16.Encode(runes[:col]))
+}
+
+// RuneCol converts a UTF-16 column in a line to a rune column.
+func RuneCol(line string, col int) int {
+	n := 0
+	for idx, r := range []rune(line) {
+		if n >= col {
+			return idx
+		}
+		n += utf16.RuneLen(r)
+	}
+	return len([]rune(line))
+}
+
+// languageIDs maps file extensions to LSP language identifiers, where
+// they differ.
+var languageIDs = map[string]string{
+	"py":   "python",
+	"js":   "javascript",
+	"ts":   "typescript",
+	"rs":   "rust",
+	"rb":   "ruby",
+	"sh":   "shellscript",
+	"bash": "shellscript",
+	"h":    "c",
+	"hpp":  "cpp",
+	"cc":   "cpp",
+	"md":   "markdown",
+	"yml":  "yaml",
+}
+
+// LanguageID returns the LSP language identifier for a file extension.
+func LanguageID(ext string) string {
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return ext
+}
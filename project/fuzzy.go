@@ -0,0 +1,141 @@
+package project
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fuzzy match scoring weights.
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 12
+	bonusSegment     = 10
+	bonusWordStart   = 8
+	bonusCamel       = 6
+	bonusBasename    = 4
+	bonusRecent      = 40
+)
+
+// Score scores a fuzzy match of pattern against a file path. The pattern
+// characters must appear in the path, in order (ignoring case). Matches at
+// the start of path segments and words, within the base name, and runs of
+// consecutive characters score higher; gaps and long paths score lower.
+// The second return value is false if the pattern does not match.
+func Score(pattern, path string) (int, bool) {
+	pat := []rune(strings.ToLower(pattern))
+	orig := []rune(path)
+	str := []rune(strings.ToLower(path))
+	if len(pat) == 0 {
+		return 0, true
+	}
+	if len(pat) > len(str) || len(str) != len(orig) {
+		return 0, false
+	}
+
+	// Quick check that the pattern is a subsequence.
+	k := 0
+	for _, c := range str {
+		if k < len(pat) && c == pat[k] {
+			k++
+		}
+	}
+	if k < len(pat) {
+		return 0, false
+	}
+
+	baseStart := strings.LastIndex(path, "/") + 1
+	baseStart = len([]rune(path[:baseStart]))
+	bonus := make([]int, len(str))
+	for j := range str {
+		if j >= baseStart {
+			bonus[j] += bonusBasename
+		}
+		if j == 0 || orig[j-1] == '/' {
+			bonus[j] += bonusSegment
+		} else if strings.ContainsRune("_-. ", orig[j-1]) {
+			bonus[j] += bonusWordStart
+		} else if unicode.IsUpper(orig[j]) && unicode.IsLower(orig[j-1]) {
+			bonus[j] += bonusCamel
+		}
+	}
+
+	// Dynamic programming: prev[j] is the best score with the previous
+	// pattern character matched at j. Gaps cost one point per character.
+	const none = -1 << 30
+	prev := make([]int, len(str))
+	curr := make([]int, len(str))
+	for j := range str {
+		prev[j] = none
+		if str[j] == pat[0] {
+			prev[j] = scoreMatch + bonus[j] - j/4
+		}
+	}
+	for i := 1; i < len(pat); i++ {
+		best := none // max of prev[k] + k, over k < j-1
+		for j := range str {
+			curr[j] = none
+			if j >= 2 && prev[j-2] != none && prev[j-2]+j-2 > best {
+				best = prev[j-2] + j - 2
+			}
+			if str[j] != pat[i] {
+				continue
+			}
+			score := none
+			if j >= 1 && prev[j-1] != none {
+				score = prev[j-1] + bonusConsecutive
+			}
+			if best != none && best-j+1 > score {
+				score = best - j + 1
+			}
+			if score != none {
+				curr[j] = score + scoreMatch + bonus[j]
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	result := none
+	for _, score := range prev {
+		if score > result {
+			result = score
+		}
+	}
+	if result == none {
+		return 0, false
+	}
+	return result - len(str)/8, true
+}
+
+// Rank returns the paths that match the pattern, best first. Recently
+// opened files (most recent first) get a bonus; with an empty pattern,
+// they come first.
+func Rank(pattern string, paths, recent []string) []string {
+	recentBonus := map[string]int{}
+	for k, path := range recent {
+		recentBonus[path] = bonusRecent + len(recent) - k
+	}
+	type scored struct {
+		path  string
+		score int
+	}
+	matches := []scored{}
+	for _, path := range paths {
+		score, ok := Score(pattern, path)
+		if !ok {
+			continue
+		}
+		matches = append(matches, scored{path, score + recentBonus[path]})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].path < matches[j].path
+	})
+	ranked := make([]string, len(matches))
+	for k, match := range matches {
+		ranked[k] = match.path
+	}
+	return ranked
+}
@@ -0,0 +1,60 @@
+package project_test
+
+import (
+	"testing"
+
+	"github.com/wx13/sith/project"
+)
+
+func TestScore(t *testing.T) {
+	if _, ok := project.Score("edt", "editor/editor.go"); !ok {
+		t.Error("edt should match editor/editor.go")
+	}
+	if _, ok := project.Score("xyz", "editor/editor.go"); ok {
+		t.Error("xyz should not match editor/editor.go")
+	}
+	if _, ok := project.Score("ogd", "editor/editor.go"); ok {
+		t.Error("characters must match in order")
+	}
+	if score, ok := project.Score("", "anything"); !ok || score != 0 {
+		t.Error("empty pattern should match everything with zero score")
+	}
+
+	// Basename and segment-start matches beat scattered ones.
+	a, _ := project.Score("menu", "ui/menu.go")
+	b, _ := project.Score("menu", "editor/mode/entry/ui.go")
+	if a <= b {
+		t.Error("basename match should score higher:", a, b)
+	}
+
+	// Consecutive matches beat gappy ones.
+	a, _ = project.Score("buf", "file/buffer/buffer.go")
+	b, _ = project.Score("buf", "file/bookmarks/util_funcs.go")
+	if a <= b {
+		t.Error("consecutive match should score higher:", a, b)
+	}
+}
+
+func TestRank(t *testing.T) {
+	paths := []string{
+		"config/config.go",
+		"editor/cutpaste.go",
+		"file/cursor/cursor.go",
+		"file/cursor/multicursor.go",
+	}
+	ranked := project.Rank("cursor", paths, nil)
+	if len(ranked) != 2 || ranked[0] != "file/cursor/cursor.go" {
+		t.Error("Bad ranking:", ranked)
+	}
+
+	recent := []string{"file/cursor/multicursor.go"}
+	ranked = project.Rank("cursor", paths, recent)
+	if ranked[0] != "file/cursor/multicursor.go" {
+		t.Error("Recent file should rank first:", ranked)
+	}
+
+	ranked = project.Rank("", paths, recent)
+	if len(ranked) != len(paths) || ranked[0] != "file/cursor/multicursor.go" {
+		t.Error("Empty pattern should list recent files first:", ranked)
+	}
+}
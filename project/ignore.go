@@ -0,0 +1,111 @@
+package project
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore file.
+type ignoreRule struct {
+	pattern  string
+	base     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// readIgnoreFile reads the .gitignore file in dir (if any). The base is the
+// directory's path relative to the project root, using forward slashes.
+func readIgnoreFile(dir, base string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	return parseIgnore(string(data), base)
+}
+
+// parseIgnore parses the contents of a .gitignore file.
+func parseIgnore(text, base string) []ignoreRule {
+	rules := []ignoreRule{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// A slash anywhere but the end anchors the pattern to the base dir.
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// match checks a slash-separated path (relative to the project root)
+// against the rule.
+func (rule ignoreRule) match(relPath string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if rule.base != "" {
+		if !strings.HasPrefix(relPath, rule.base+"/") {
+			return false
+		}
+		relPath = relPath[len(rule.base)+1:]
+	}
+	if !rule.anchored {
+		ok, _ := path.Match(rule.pattern, path.Base(relPath))
+		return ok
+	}
+	return globMatch(strings.Split(rule.pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// globMatch matches path segments against pattern segments, where a "**"
+// segment matches zero or more path segments.
+func globMatch(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for k := 0; k <= len(segments); k++ {
+			if globMatch(pattern[1:], segments[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && globMatch(pattern[1:], segments[1:])
+}
+
+// ignored returns true if the path is excluded by the rules. Later rules
+// take precedence over earlier ones.
+func ignored(rules []ignoreRule, relPath string, isDir bool) bool {
+	ignore := false
+	for _, rule := range rules {
+		if rule.match(relPath, isDir) {
+			ignore = !rule.negate
+		}
+	}
+	return ignore
+}
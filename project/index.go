@@ -0,0 +1,92 @@
+package project
+
+import (
+	"sync"
+	"time"
+)
+
+// Index is a list of project files, built in the background. It can be
+// used while it is still being built.
+type Index struct {
+	root     string
+	files    []string
+	building bool
+	built    time.Time
+	mutex    *sync.Mutex
+
+	// done is closed when the current build is complete.
+	done chan struct{}
+}
+
+// NewIndex creates an index of the files under root, and starts building it.
+func NewIndex(root string) *Index {
+	index := &Index{
+		root:  root,
+		mutex: &sync.Mutex{},
+	}
+	index.Refresh()
+	return index
+}
+
+// Refresh rebuilds the index in the background. Until it is done, the old
+// list of files (if any) is kept; on the first build, files are added as
+// they are found.
+func (index *Index) Refresh() {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if index.building {
+		return
+	}
+	index.building = true
+	incremental := index.built.IsZero()
+	done := make(chan struct{})
+	index.done = done
+	go func() {
+		files := []string{}
+		walk(index.root, "", nil, func(name string) {
+			if incremental {
+				index.mutex.Lock()
+				index.files = append(index.files, name)
+				index.mutex.Unlock()
+			} else {
+				files = append(files, name)
+			}
+		})
+		index.mutex.Lock()
+		if !incremental {
+			index.files = files
+		}
+		index.building = false
+		index.built = time.Now()
+		index.mutex.Unlock()
+		close(done)
+	}()
+}
+
+// Files returns the indexed files, and true if the index is complete.
+func (index *Index) Files() ([]string, bool) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	files := make([]string, len(index.files))
+	copy(files, index.files)
+	return files, !index.building
+}
+
+// Age returns the time since the index was last completed. It is zero
+// before the first build completes.
+func (index *Index) Age() time.Duration {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if index.built.IsZero() {
+		return 0
+	}
+	return time.Since(index.built)
+}
+
+// Wait blocks until the index is complete.
+func (index *Index) Wait() {
+	index.mutex.Lock()
+	done := index.done
+	index.mutex.Unlock()
+	<-done
+}
@@ -0,0 +1,167 @@
+// Package project deals with the files in the working directory, whether or
+// not they are open in the editor: listing them (honoring .gitignore) and
+// searching through them.
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wx13/sith/file/buffer"
+)
+
+// maxFileSize is the largest file that Grep will look at.
+const maxFileSize = 10 * 1024 * 1024
+
+// Match is a single search result.
+type Match struct {
+	Path string
+	Row  int
+	Col  int
+	Text string
+}
+
+// String formats the match as path:line: text (with a one-based line number).
+func (match Match) String() string {
+	return fmt.Sprintf("%s:%d: %s", match.Path, match.Row+1, strings.TrimSpace(match.Text))
+}
+
+// Files lists the files under root, relative to root. Hidden files and
+// directories are skipped, as is anything matched by a .gitignore file.
+func Files(root string) ([]string, error) {
+	files := []string{}
+	if _, err := os.Stat(root); err != nil {
+		return files, err
+	}
+	walk(root, "", nil, func(name string) {
+		files = append(files, name)
+	})
+	sort.Strings(files)
+	return files, nil
+}
+
+// walk recursively finds the files in root/rel, calling fn on each one.
+func walk(root, rel string, rules []ignoreRule, fn func(string)) {
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	rules = append(rules[:len(rules):len(rules)], readIgnoreFile(dir, rel)...)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		relPath := path.Join(rel, name)
+		if ignored(rules, relPath, entry.IsDir()) {
+			continue
+		}
+		if entry.IsDir() {
+			walk(root, relPath, rules, fn)
+		} else if entry.Type().IsRegular() {
+			fn(relPath)
+		}
+	}
+}
+
+// Grep searches all the project files under root for term, using the
+// same rules as the in-buffer search (/regex/flags, smart-case). It
+// returns at most max matches (all of them if max <= 0), sorted by path
+// and row.
+func Grep(root, term string, max int) ([]Match, error) {
+	re, err := buffer.Pattern(term)
+	if err != nil {
+		return nil, err
+	}
+	files, err := Files(root)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(chan string)
+	results := make(chan []Match)
+	var wg sync.WaitGroup
+	for k := 0; k < runtime.NumCPU(); k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range paths {
+				matches := grepFile(filepath.Join(root, filepath.FromSlash(name)), name, term, re)
+				if len(matches) > 0 {
+					results <- matches
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, name := range files {
+			paths <- name
+		}
+		close(paths)
+		wg.Wait()
+		close(results)
+	}()
+
+	matches := []Match{}
+	for m := range results {
+		matches = append(matches, m...)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Row < matches[j].Row
+	})
+	if max > 0 && len(matches) > max {
+		matches = matches[:max]
+	}
+	return matches, nil
+}
+
+// grepFile returns the first match on each line of a file. Binary and
+// very large files are skipped.
+func grepFile(filename, name, term string, re *regexp.Regexp) []Match {
+	info, err := os.Stat(filename)
+	if err != nil || info.Size() > maxFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || isBinary(data) {
+		return nil
+	}
+	matches := []Match{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxFileSize)
+	for row := 0; scanner.Scan(); row++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		// The regex is a quick filter; Search finds the (rune) column.
+		if !re.MatchString(text) {
+			continue
+		}
+		col, _ := buffer.MakeLine(text).Search(term, 0, -1)
+		if col >= 0 {
+			matches = append(matches, Match{Path: name, Row: row, Col: col, Text: text})
+		}
+	}
+	return matches
+}
+
+// isBinary guesses whether data is binary, by looking for a null byte
+// near the start.
+func isBinary(data []byte) bool {
+	n := len(data)
+	if n > 8000 {
+		n = 8000
+	}
+	return bytes.IndexByte(data[:n], 0) >= 0
+}
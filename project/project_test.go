@@ -0,0 +1,111 @@
+package project_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wx13/sith/project"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":        "*.log\nbuild/\n/top.txt\n!keep.log\n",
+		"main.go":           "",
+		"top.txt":           "",
+		"sub/top.txt":       "",
+		"debug.log":         "",
+		"keep.log":          "",
+		"build/out.go":      "",
+		".hidden/x.go":      "",
+		".env":              "",
+		"sub/.gitignore":    "secret*\n",
+		"sub/secret.go":     "",
+		"sub/deep/lib.go":   "",
+		"docs/build/doc.md": "",
+	})
+	files, err := project.Files(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(files, ",")
+	expected := "keep.log,main.go,sub/deep/lib.go,sub/top.txt"
+	if got != expected {
+		t.Errorf("Expected %s, but got %s", expected, got)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.txt":   "hello\nsay Hello\nnothing\n",
+		"b/c.txt": "x = hello(1)\n",
+		"bin.dat": "hello\x00world",
+	})
+	matches, err := project.Grep(root, "hello", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("Expected 3 matches, got %v", matches)
+	}
+	if matches[1].String() != "a.txt:2: say Hello" || matches[1].Col != 4 {
+		t.Error("Bad match:", matches[1], matches[1].Col)
+	}
+	if matches[2].Path != "b/c.txt" || matches[2].Row != 0 {
+		t.Error("Bad match:", matches[2])
+	}
+
+	matches, _ = project.Grep(root, "Hello", 0)
+	if len(matches) != 1 {
+		t.Error("Smart case should match only the capitalized term:", matches)
+	}
+
+	matches, _ = project.Grep(root, `/hello\(\d\)/`, 0)
+	if len(matches) != 1 || matches[0].Path != "b/c.txt" {
+		t.Error("Regex search failed:", matches)
+	}
+
+	matches, _ = project.Grep(root, "hello", 2)
+	if len(matches) != 2 {
+		t.Error("Grep should limit the number of matches:", matches)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore": "*.o\n",
+		"a.go":       "",
+		"a.o":        "",
+		"sub/b.go":   "",
+	})
+	index := project.NewIndex(root)
+	index.Wait()
+	files, done := index.Files()
+	if !done || strings.Join(files, ",") != "a.go,sub/b.go" {
+		t.Error("Bad index:", files, done)
+	}
+
+	writeFiles(t, root, map[string]string{"c.go": ""})
+	index.Refresh()
+	index.Wait()
+	files, _ = index.Files()
+	if len(files) != 3 {
+		t.Error("Refresh should pick up new files:", files)
+	}
+}
@@ -0,0 +1,169 @@
+// Package quickfix finds the error locations in the output of build and
+// test commands, and keeps a list of them to step through.
+package quickfix
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultFormats match the usual compiler error lines: file:line:col: msg,
+// and file:line: msg.
+var DefaultFormats = []string{
+	`^\s*(?P<file>[^:\s][^:]*):(?P<line>\d+):(?P<col>\d+):\s*(?P<msg>.*)$`,
+	`^\s*(?P<file>[^:\s][^:]*):(?P<line>\d+):\s*(?P<msg>.*)$`,
+}
+
+// Error is an error location in the output of a command.
+type Error struct {
+	Path    string
+	Row     int
+	Col     int
+	Message string
+}
+
+// String formats the error as path:line:col: msg (with one-based line and
+// column numbers).
+func (e Error) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Row+1, e.Col+1, e.Message)
+}
+
+// Compile compiles error formats: regular expressions with the named
+// groups "file" and "line", and optionally "col" and "msg". With no
+// formats, it uses DefaultFormats.
+func Compile(formats []string) ([]*regexp.Regexp, error) {
+	if len(formats) == 0 {
+		formats = DefaultFormats
+	}
+	res := []*regexp.Regexp{}
+	for _, format := range formats {
+		re, err := regexp.Compile(format)
+		if err != nil {
+			return nil, err
+		}
+		if re.SubexpIndex("file") < 0 || re.SubexpIndex("line") < 0 {
+			return nil, fmt.Errorf("error format %q needs file and line groups", format)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Parse finds the errors in a command's output. Each line is matched
+// against the formats in turn, and the first match wins.
+func Parse(output string, formats []*regexp.Regexp) []Error {
+	errs := []Error{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		for _, re := range formats {
+			if e, ok := parseLine(line, re); ok {
+				errs = append(errs, e)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// parseLine matches one line of output against an error format.
+func parseLine(line string, re *regexp.Regexp) (Error, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return Error{}, false
+	}
+	group := func(name string) string {
+		if k := re.SubexpIndex(name); k >= 0 {
+			return m[k]
+		}
+		return ""
+	}
+	row, err := strconv.Atoi(group("line"))
+	if err != nil || row < 1 {
+		return Error{}, false
+	}
+	col, err := strconv.Atoi(group("col"))
+	if err != nil || col < 1 {
+		col = 1
+	}
+	return Error{
+		Path:    group("file"),
+		Row:     row - 1,
+		Col:     col - 1,
+		Message: strings.TrimSpace(group("msg")),
+	}, true
+}
+
+// List is a list of errors, with a current one.
+type List struct {
+	Errors []Error
+	idx    int
+}
+
+// NewList makes a list of errors, positioned before the first one.
+func NewList(errs []Error) *List {
+	return &List{Errors: errs, idx: -1}
+}
+
+// Len returns the number of errors.
+func (list *List) Len() int {
+	return len(list.Errors)
+}
+
+// Index returns the index of the current error (-1 if there is none yet).
+func (list *List) Index() int {
+	return list.idx
+}
+
+// Next moves to the next error, wrapping around at the end.
+func (list *List) Next() (Error, bool) {
+	return list.step(1)
+}
+
+// Prev moves to the previous error, wrapping around at the start.
+func (list *List) Prev() (Error, bool) {
+	return list.step(-1)
+}
+
+// Select makes an error current.
+func (list *List) Select(idx int) (Error, bool) {
+	if idx < 0 || idx >= len(list.Errors) {
+		return Error{}, false
+	}
+	list.idx = idx
+	return list.Errors[idx], true
+}
+
+func (list *List) step(dir int) (Error, bool) {
+	n := len(list.Errors)
+	if n == 0 {
+		return Error{}, false
+	}
+	if list.idx < 0 && dir < 0 {
+		list.idx = 0
+	}
+	list.idx = ((list.idx+dir)%n + n) % n
+	return list.Errors[list.idx], true
+}
+
+// Rows maps the rows of a file's errors to their messages. Paths are
+// compared as absolute paths.
+func (list *List) Rows(path string) map[int]string {
+	rows := map[int]string{}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return rows
+	}
+	for _, e := range list.Errors {
+		absErr, err := filepath.Abs(e.Path)
+		if err != nil || absErr != absPath {
+			continue
+		}
+		if _, ok := rows[e.Row]; !ok {
+			rows[e.Row] = e.Message
+		}
+	}
+	return rows
+}
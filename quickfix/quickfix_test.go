@@ -0,0 +1,77 @@
+package quickfix_test
+
+import (
+	"testing"
+
+	"github.com/wx13/sith/quickfix"
+)
+
+func TestParse(t *testing.T) {
+	output := "" +
+		"# github.com/wx13/sith/editor\n" +
+		"editor/build.go:12:5: undefined: foo\n" +
+		"    build_test.go:40: expected 3, got 4\r\n" +
+		"--- FAIL: TestBuild (0.00s)\n" +
+		"see http://example.com:8080/x\n"
+	formats, err := quickfix.Compile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := quickfix.Parse(output, formats)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if errs[0].String() != "editor/build.go:12:5: undefined: foo" {
+		t.Error("wrong first error:", errs[0])
+	}
+	if e := errs[1]; e.Path != "build_test.go" || e.Row != 39 || e.Col != 0 ||
+		e.Message != "expected 3, got 4" {
+		t.Errorf("wrong second error: %+v", e)
+	}
+
+	formats, err = quickfix.Compile([]string{`^(?P<file>\S+)\((?P<line>\d+)\): (?P<msg>.*)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs = quickfix.Parse("main.c(7): missing semicolon\n", formats)
+	if len(errs) != 1 || errs[0].Path != "main.c" || errs[0].Row != 6 {
+		t.Errorf("custom format failed: %v", errs)
+	}
+
+	if _, err := quickfix.Compile([]string{`(?P<file>\S+)`}); err == nil {
+		t.Error("expected an error for a format without a line group")
+	}
+	if _, err := quickfix.Compile([]string{`(`}); err == nil {
+		t.Error("expected an error for a bad regex")
+	}
+}
+
+func TestList(t *testing.T) {
+	list := quickfix.NewList([]quickfix.Error{
+		{Path: "a.go", Row: 3, Message: "one"},
+		{Path: "b.go", Row: 1, Message: "two"},
+		{Path: "./a.go", Row: 3, Message: "three"},
+	})
+	if e, _ := list.Next(); e.Message != "one" {
+		t.Error("expected the first error, got", e)
+	}
+	list.Next()
+	if e, _ := list.Next(); e.Message != "three" || list.Index() != 2 {
+		t.Error("expected the last error, got", e)
+	}
+	if e, _ := list.Next(); e.Message != "one" {
+		t.Error("expected to wrap around to the first error, got", e)
+	}
+	if e, _ := list.Prev(); e.Message != "three" {
+		t.Error("expected to wrap around to the last error, got", e)
+	}
+
+	rows := list.Rows("a.go")
+	if len(rows) != 1 || rows[3] != "one" {
+		t.Error("wrong rows for a.go:", rows)
+	}
+
+	if _, ok := quickfix.NewList(nil).Next(); ok {
+		t.Error("an empty list has no next error")
+	}
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/terminal"
+)
+
+const macrosFile = "macros.json"
+
+// Macros holds recorded keyboard macros, by register name, across
+// sessions.
+type Macros struct {
+	Registers map[string][]terminal.Key `json:"registers"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// NewMacros creates a new Macros, loading from disk if available.
+func NewMacros() *Macros {
+	m := &Macros{
+		Registers: map[string][]terminal.Key{},
+	}
+
+	configDir := config.ConfigDir()
+	if configDir == "" {
+		return m
+	}
+
+	m.path = filepath.Join(configDir, macrosFile)
+	m.load()
+	return m
+}
+
+// load reads macros from disk.
+func (m *Macros) load() {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, m)
+	if m.Registers == nil {
+		m.Registers = map[string][]terminal.Key{}
+	}
+}
+
+// Save writes macros to disk.
+func (m *Macros) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0644)
+}
+
+// Get returns the macro in a register.
+func (m *Macros) Get(register string) ([]terminal.Key, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys, ok := m.Registers[register]
+	return keys, ok
+}
+
+// Set stores a macro in a register.
+func (m *Macros) Set(register string, keys []terminal.Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Registers[register] = keys
+}
+
+// Names returns the (sorted) names of the registers which hold macros.
+func (m *Macros) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := []string{}
+	for name := range m.Registers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -16,6 +16,7 @@ const (
 	sessionsDir       = "sessions"
 	maxSessionAge     = 30 * 24 * time.Hour // 30 days
 	maxSessionCount   = 50
+	maxRecentFiles    = 100
 )
 
 // FileState holds the state of a single open file.
@@ -29,6 +30,7 @@ type FileState struct {
 // Session holds the state of an editor session.
 type Session struct {
 	Files     []FileState `json:"files"`
+	Recent    []string    `json:"recent,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 
 	path string
@@ -135,6 +137,27 @@ func (s *Session) GetFiles() []FileState {
 	return s.Files
 }
 
+// AddRecent records a file as the most recently opened one.
+func (s *Session) AddRecent(path string) {
+	recent := []string{path}
+	for _, p := range s.Recent {
+		if p != path && len(recent) < maxRecentFiles {
+			recent = append(recent, p)
+		}
+	}
+	s.Recent = recent
+}
+
+// GetRecent returns the recently opened files, most recent first.
+func (s *Session) GetRecent() []string {
+	return s.Recent
+}
+
+// SetRecent replaces the list of recently opened files.
+func (s *Session) SetRecent(recent []string) {
+	s.Recent = recent
+}
+
 // Age returns how long ago the session was saved.
 func (s *Session) Age() time.Duration {
 	return time.Since(s.Timestamp)
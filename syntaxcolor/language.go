@@ -0,0 +1,215 @@
+package syntaxcolor
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/theme"
+)
+
+//go:embed languages/*.toml
+var builtinLanguages embed.FS
+
+// Language is a language definition: what its keywords, comments, strings,
+// etc. look like. Definitions are TOML files, built in or in
+// ~/.config/sith/syntax (which take precedence).
+type Language struct {
+	Name string
+
+	// Extensions are the file extensions (or, for files without one, the
+	// lower-cased file names) of the language. Aliases are other names for
+	// it, e.g. in markdown code blocks.
+	Extensions []string
+	Aliases    []string
+
+	Keywords  []string
+	Types     []string
+	Constants []string
+	// IgnoreCase makes keywords, types and constants case-insensitive.
+	IgnoreCase bool
+	// Numbers highlights numeric literals.
+	Numbers bool
+
+	LineComments []string
+	// BlockComment is the start and end of a block comment.
+	BlockComment []string
+
+	// Strings are the string delimiters, and Escape is the escape
+	// character within strings (if any).
+	Strings []string
+	Escape  string
+	// MultilineStrings are delimiters of strings which can span lines (at
+	// most two).
+	MultilineStrings []string
+
+	// Rules are extra patterns, mapped to a theme role or color.
+	Rules map[string]string
+}
+
+var languages struct {
+	list []*Language
+	once sync.Once
+}
+
+// Languages returns all the language definitions.
+func Languages() []*Language {
+	languages.once.Do(func() {
+		languages.list = loadLanguages()
+	})
+	return languages.list
+}
+
+// FindLanguage returns the language with a file extension, name or alias
+// (or nil if there is none).
+func FindLanguage(key string) *Language {
+	key = strings.ToLower(key)
+	for _, lang := range Languages() {
+		if lang.Name == key {
+			return lang
+		}
+		for _, names := range [][]string{lang.Extensions, lang.Aliases} {
+			for _, name := range names {
+				if strings.ToLower(name) == key {
+					return lang
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadLanguages reads the built-in and user language definitions.
+func loadLanguages() []*Language {
+	byName := map[string]*Language{}
+	entries, _ := builtinLanguages.ReadDir("languages")
+	for _, entry := range entries {
+		data, err := builtinLanguages.ReadFile("languages/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		if lang, err := parseLanguage(entry.Name(), data); err == nil {
+			byName[lang.Name] = lang
+		}
+	}
+
+	if dir := config.ConfigDir(); dir != "" {
+		dir = filepath.Join(dir, "syntax")
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".toml") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			if lang, err := parseLanguage(entry.Name(), data); err == nil {
+				byName[lang.Name] = lang
+			}
+		}
+	}
+
+	list := []*Language{}
+	for _, lang := range byName {
+		list = append(list, lang)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// parseLanguage parses a language definition. The name defaults to the
+// file name.
+func parseLanguage(filename string, data []byte) (*Language, error) {
+	lang := &Language{}
+	if _, err := toml.Decode(string(data), lang); err != nil {
+		return nil, err
+	}
+	if lang.Name == "" {
+		lang.Name = strings.TrimSuffix(filename, ".toml")
+	}
+	lang.Name = strings.ToLower(lang.Name)
+	return lang, nil
+}
+
+// wordPattern matches any of a list of words.
+func wordPattern(words []string, ignoreCase bool) string {
+	quoted := make([]string, len(words))
+	for k, word := range words {
+		quoted[k] = regexp.QuoteMeta(word)
+	}
+	pattern := `\b(?:` + strings.Join(quoted, "|") + `)\b`
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return pattern
+}
+
+// stringPattern matches a string with the given delimiter.
+func stringPattern(delim, escape string) string {
+	d := regexp.QuoteMeta(delim)
+	if escape == "" {
+		return d + ".*?" + d
+	}
+	return d + "(?:" + regexp.QuoteMeta(escape) + ".|.)*?" + d
+}
+
+const numberPattern = `\b(?:0[xX][0-9a-fA-F_]+|0[bB][01_]+|[0-9][0-9_]*(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?)\b`
+
+// addLanguage adds the rules from a language definition. They go ahead of
+// the rules from the config, so that (for example) a string with an
+// escaped quote is matched as a whole.
+func (rules *SyntaxRules) addLanguage(lang *Language) {
+	list := rules.list
+	rules.list = nil
+	add := func(pattern, role string) {
+		rules.addRule(pattern, Color{fg: theme.Fg(role), bg: theme.Bg(role)})
+	}
+
+	for _, comment := range lang.LineComments {
+		add(regexp.QuoteMeta(comment)+".*$", "comment")
+	}
+	for _, delim := range lang.Strings {
+		add(stringPattern(delim, lang.Escape), "string")
+	}
+	patterns := []string{}
+	for pattern := range lang.Rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		add(pattern, lang.Rules[pattern])
+	}
+	if len(lang.Keywords) > 0 {
+		add(wordPattern(lang.Keywords, lang.IgnoreCase), "keyword")
+	}
+	if len(lang.Types) > 0 {
+		add(wordPattern(lang.Types, lang.IgnoreCase), "type")
+	}
+	if len(lang.Constants) > 0 {
+		add(wordPattern(lang.Constants, lang.IgnoreCase), "constant")
+	}
+	if lang.Numbers {
+		add(numberPattern, "number")
+	}
+	rules.list = append(rules.list, list...)
+
+	if len(lang.BlockComment) == 2 {
+		rules.AddBlockComment(lang.BlockComment[0], lang.BlockComment[1],
+			theme.Fg("comment"), tcell.ColorDefault)
+	}
+	states := []LineState{StateString, StateRawString}
+	for k, delim := range lang.MultilineStrings {
+		if k >= len(states) {
+			break
+		}
+		rules.AddMultilineString(delim, theme.Fg("string"), tcell.ColorDefault, states[k])
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/wx13/sith/config"
+	"github.com/wx13/sith/theme"
 )
 
 // LineState represents the syntax state at the start/end of a line.
@@ -100,13 +101,13 @@ func (rules *SyntaxRules) ingestConfig(cfg config.Config) {
 	for pattern, color := range cfg.SyntaxRules {
 		if color.Clobber {
 			rules.addClobberRule(pattern, Color{
-				fg: toColor(color.FG),
-				bg: toColor(color.BG),
+				fg: theme.Fg(color.FG),
+				bg: theme.Bg(color.BG),
 			})
 		} else {
 			rules.addRule(pattern, Color{
-				fg: toColor(color.FG),
-				bg: toColor(color.BG),
+				fg: theme.Fg(color.FG),
+				bg: theme.Bg(color.BG),
 			})
 		}
 	}
@@ -132,36 +133,23 @@ func (rules *SyntaxRules) AddMultilineString(delim string, fg, bg tcell.Color, s
 	})
 }
 
-func toColor(name string) tcell.Color {
-	colormap := map[string]tcell.Color{
-		"green":   tcell.ColorGreen,
-		"red":     tcell.ColorRed,
-		"blue":    tcell.ColorBlue,
-		"cyan":    tcell.ColorTeal,
-		"magenta": tcell.ColorPurple,
-		"yellow":  tcell.ColorYellow,
-		"white":   tcell.ColorWhite,
-		"black":   tcell.ColorBlack,
-		"default": tcell.ColorDefault,
-	}
-	color, ok := colormap[name]
-	if ok {
-		return color
-	}
-	return tcell.ColorDefault
-}
-
 func (rules *SyntaxRules) addWhitespaceRule() {
 	rules.whitespace = regexp.MustCompile("[ \t]+$")
 }
 
 func (rules *SyntaxRules) addRule(reStr string, color Color) {
-	re, _ := regexp.Compile(reStr)
+	re, err := regexp.Compile(reStr)
+	if err != nil {
+		return
+	}
 	rules.list = append(rules.list, SyntaxRule{re, color})
 }
 
 func (rules *SyntaxRules) addClobberRule(reStr string, color Color) {
-	re, _ := regexp.Compile(reStr)
+	re, err := regexp.Compile(reStr)
+	if err != nil {
+		return
+	}
 	rules.clobber = append(rules.clobber, SyntaxRule{re, color})
 }
 
@@ -236,7 +224,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		if rules.codeBlockEnd.MatchString(str) {
 			// Color the closing ``` in a distinct color
 			result.Colors = append(result.Colors, LineColor{
-				Fg:    tcell.ColorBlue,
+				Fg:    theme.Fg("markup.code"),
 				Bg:    tcell.ColorDefault,
 				Start: 0,
 				End:   len(str),
@@ -260,7 +248,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		// Check if this line ends the equation
 		if rules.equationDelim.MatchString(str) {
 			result.Colors = append(result.Colors, LineColor{
-				Fg:    tcell.ColorPurple,
+				Fg:    theme.Fg("markup.math"),
 				Bg:    tcell.ColorDefault,
 				Start: 0,
 				End:   len(str),
@@ -270,7 +258,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		}
 		// Color the entire line as equation
 		result.Colors = append(result.Colors, LineColor{
-			Fg:    tcell.ColorPurple,
+			Fg:    theme.Fg("markup.math"),
 			Bg:    tcell.ColorDefault,
 			Start: 0,
 			End:   len(str),
@@ -284,7 +272,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		lang := strings.ToLower(match[1])
 		// Color the opening line (```python) in a distinct color
 		result.Colors = append(result.Colors, LineColor{
-			Fg:    tcell.ColorBlue,
+			Fg:    theme.Fg("markup.code"),
 			Bg:    tcell.ColorDefault,
 			Start: 0,
 			End:   len(str),
@@ -299,7 +287,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		if len(str) > 2 && strings.HasSuffix(strings.TrimSpace(str), "$$") && strings.Count(str, "$$") >= 2 {
 			// Single-line equation
 			result.Colors = append(result.Colors, LineColor{
-				Fg:    tcell.ColorPurple,
+				Fg:    theme.Fg("markup.math"),
 				Bg:    tcell.ColorDefault,
 				Start: 0,
 				End:   len(str),
@@ -309,7 +297,7 @@ func (rules *SyntaxRules) colorizeMarkdown(str string, startState LineState) Col
 		}
 		// Multi-line equation starts
 		result.Colors = append(result.Colors, LineColor{
-			Fg:    tcell.ColorPurple,
+			Fg:    theme.Fg("markup.math"),
 			Bg:    tcell.ColorDefault,
 			Start: 0,
 			End:   len(str),
@@ -496,6 +484,12 @@ func (sc *StateCache) SetEndState(lineNum int, state LineState) bool {
 	return oldState != state
 }
 
+// Len returns the number of lines whose end states are cached. The start
+// states are known up to and including line Len().
+func (sc *StateCache) Len() int {
+	return len(sc.states)
+}
+
 // Invalidate marks all states from lineNum onwards as needing recalculation.
 func (sc *StateCache) Invalidate(lineNum int) {
 	if lineNum < len(sc.states) {
@@ -508,36 +502,13 @@ func (sc *StateCache) Clear() {
 	sc.states = sc.states[:0]
 }
 
-// SetupForLanguage configures multiline rules based on file extension.
+// SetupForLanguage adds the rules from the language definition for a file
+// extension (see Language).
 func (rules *SyntaxRules) SetupForLanguage(ext string) {
 	ext = strings.ToLower(ext)
 
-	// C-style languages: block comments
-	cStyleLangs := map[string]bool{
-		"c": true, "h": true, "cc": true, "cpp": true, "c++": true,
-		"go": true, "java": true, "js": true, "ts": true, "tsx": true,
-		"jsx": true, "rs": true, "swift": true, "kt": true, "scala": true,
-		"cs": true, "php": true, "css": true, "scss": true, "less": true,
-	}
-
-	if cStyleLangs[ext] {
-		rules.AddBlockComment("/*", "*/", tcell.ColorTeal, tcell.ColorDefault)
-	}
-
-	// Python/Ruby: multiline strings with triple quotes
-	if ext == "py" {
-		rules.AddMultilineString(`"""`, tcell.ColorYellow, tcell.ColorDefault, StateString)
-		rules.AddMultilineString(`'''`, tcell.ColorYellow, tcell.ColorDefault, StateRawString)
-	}
-
-	// Go: raw strings with backticks
-	if ext == "go" {
-		rules.AddMultilineString("`", tcell.ColorYellow, tcell.ColorDefault, StateRawString)
-	}
-
-	// HTML/XML: comments
-	if ext == "html" || ext == "htm" || ext == "xml" || ext == "svg" {
-		rules.AddBlockComment("<!--", "-->", tcell.ColorTeal, tcell.ColorDefault)
+	if lang := FindLanguage(ext); lang != nil {
+		rules.addLanguage(lang)
 	}
 
 	// Markdown and Quarto: embedded code blocks and equations
@@ -6,6 +6,7 @@ import (
 
 	"github.com/wx13/sith/config"
 	"github.com/wx13/sith/syntaxcolor"
+	"github.com/wx13/sith/theme"
 )
 
 func ExampleSyntaxRules_Colorize() {
@@ -154,3 +155,44 @@ func TestQuartoCodeBlock(t *testing.T) {
 		t.Errorf("Expected end state to be a code block state for Quarto syntax, got %d", result.EndState)
 	}
 }
+
+func TestLanguages(t *testing.T) {
+	for _, key := range []string{"py", "python", "rs", "ts", "yaml", "json", "makefile", "dockerfile", "sql", "html"} {
+		if syntaxcolor.FindLanguage(key) == nil {
+			t.Errorf("no language definition for %q", key)
+		}
+	}
+	if syntaxcolor.FindLanguage("no-such-language") != nil {
+		t.Error("expected no language definition")
+	}
+
+	sr := syntaxcolor.NewSyntaxRules(config.Config{})
+	sr.SetupForLanguage("py")
+
+	lc := sr.Colorize("def f(x):")
+	if len(lc) == 0 || lc[0].Start != 0 || lc[0].End != 3 || lc[0].Fg != theme.Fg("keyword") {
+		t.Errorf("expected 'def' to be a keyword: %v", lc)
+	}
+	lc = sr.Colorize(`s = "a \" b" + x`)
+	if len(lc) != 1 || lc[0].Start != 4 || lc[0].End != 12 || lc[0].Fg != theme.Fg("string") {
+		t.Errorf("expected one string with an escaped quote: %v", lc)
+	}
+	result := sr.ColorizeWithState(`x = """start`, syntaxcolor.StateNormal)
+	if result.EndState != syntaxcolor.StateString {
+		t.Errorf("expected a multiline string, got state %v", result.EndState)
+	}
+
+	sr = syntaxcolor.NewSyntaxRules(config.Config{})
+	sr.SetupForLanguage("SQL")
+	lc = sr.Colorize("SELECT 1")
+	if len(lc) != 2 || lc[0].Fg != theme.Fg("keyword") || lc[1].Fg != theme.Fg("number") {
+		t.Errorf("expected a keyword and a number: %v", lc)
+	}
+
+	sr = syntaxcolor.NewSyntaxRules(config.Config{})
+	sr.SetupForLanguage("c")
+	result = sr.ColorizeWithState("int x; /* a", syntaxcolor.StateNormal)
+	if result.EndState != syntaxcolor.StateBlockComment {
+		t.Errorf("expected a block comment, got state %v", result.EndState)
+	}
+}
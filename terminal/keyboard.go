@@ -8,14 +8,28 @@ import (
 
 // Keyboard acts as an interface to the tcell keyboard.
 type Keyboard struct {
-	KeyMap map[tcell.Key]string
-	screen tcell.Screen
+	KeyMap      map[tcell.Key]string
+	ShiftKeyMap map[tcell.Key]string
+	screen      tcell.Screen
+
+	// The last mouse event, and whether the (left) button is down.
+	mouse      Mouse
+	buttonDown bool
+
+	// pasted is the text of the last paste.
+	pasted string
+
+	// Macros records the keypresses, and plays back macros.
+	Macros *Recorder
+
+	// held are the keys read by Wait, for GetKey to return.
+	held []Key
 }
 
 // NewKeyboard defines a map from tcell key to a
 // string representation.
 func NewKeyboard() *Keyboard {
-	kb := Keyboard{}
+	kb := Keyboard{Macros: NewRecorder()}
 	kb.KeyMap = map[tcell.Key]string{
 		tcell.KeyBackspace:  "backspace",
 		tcell.KeyBackspace2: "backspace",
@@ -55,6 +69,16 @@ func NewKeyboard() *Keyboard {
 		tcell.KeyCtrlZ:      "ctrlZ",
 		tcell.KeyCtrlBackslash: "ctrlSlash",
 	}
+	kb.ShiftKeyMap = map[tcell.Key]string{
+		tcell.KeyUp:    "shiftArrowUp",
+		tcell.KeyDown:  "shiftArrowDown",
+		tcell.KeyLeft:  "shiftArrowLeft",
+		tcell.KeyRight: "shiftArrowRight",
+		tcell.KeyPgUp:  "shiftPageUp",
+		tcell.KeyPgDn:  "shiftPageDown",
+		tcell.KeyHome:  "shiftHome",
+		tcell.KeyEnd:   "shiftEnd",
+	}
 	return &kb
 }
 
@@ -72,6 +96,13 @@ func (kb *Keyboard) keyToCmd(ev *tcell.EventKey) (string, rune) {
 	r := ev.Rune()
 	mod := ev.Modifiers()
 
+	if mod&tcell.ModShift != 0 {
+		cmd, ok := kb.ShiftKeyMap[key]
+		if ok {
+			return cmd, 0
+		}
+	}
+
 	cmd, ok := kb.KeyMap[key]
 	if ok {
 		return cmd, 0
@@ -107,6 +138,31 @@ func (kb *Keyboard) keyToCmd(ev *tcell.EventKey) (string, rune) {
 	return "unknown", 0
 }
 
+// ValidKey reports whether a key name (as returned by GetKey) can
+// be produced by the keyboard.
+func (kb *Keyboard) ValidKey(name string) bool {
+	if name == "space" || name == "ctrl6" {
+		return true
+	}
+	for _, cmd := range kb.KeyMap {
+		if cmd == name {
+			return true
+		}
+	}
+	for _, cmd := range kb.ShiftKeyMap {
+		if cmd == name {
+			return true
+		}
+	}
+	if strings.HasPrefix(name, "alt") {
+		r := []rune(strings.TrimPrefix(name, "alt"))
+		if len(r) == 1 && r[0] > 32 && r[0] < 127 {
+			return string(r) == strings.ToUpper(string(r))
+		}
+	}
+	return false
+}
+
 // GetCmdString turns tcell keyboard input into a string representation
 // of the keypress. If the result is "char", then it also returns the rune.
 func (kb *Keyboard) GetCmdString(ev *tcell.EventKey) (string, rune) {
@@ -114,24 +170,108 @@ func (kb *Keyboard) GetCmdString(ev *tcell.EventKey) (string, rune) {
 }
 
 // GetKey returns the human-readable name for a keypress,
-// or the rune if it is character.
+// or the rune if it is character. Macro keys being played back
+// come first, then any keys held back by Wait, and real keypresses are
+// recorded (see Macros). Mouse events are returned as "mouse" (see
+// Mouse), and are not recorded. Text pasted into the terminal is returned
+// as "paste" (see Pasted), and Interrupt makes it return "interrupt".
+// Functions sent with Post are run while it waits.
 func (kb *Keyboard) GetKey() (string, rune) {
+	if key, ok := kb.Macros.Next(); ok {
+		if key.Cmd == "paste" {
+			kb.pasted = key.Text
+		}
+		return key.Cmd, key.Rune
+	}
+	var key Key
+	if len(kb.held) > 0 {
+		key, kb.held = kb.held[0], kb.held[1:]
+	} else {
+		key = kb.poll()
+	}
+	switch key.Cmd {
+	case "interrupt":
+		return key.Cmd, 0
+	case "mouse":
+		kb.mouse = key.mouse
+		return key.Cmd, 0
+	case "paste":
+		kb.pasted = key.Text
+	}
+	kb.Macros.Record(key)
+	return key.Cmd, key.Rune
+}
+
+// Wait reads events until an Interrupt, running functions sent with Post
+// as GetKey does. Ctrl-C calls cancel. Any other keys (and pastes and
+// mouse events) are held back for GetKey, and only recorded once it
+// returns them.
+func (kb *Keyboard) Wait(cancel func()) {
+	for {
+		key := kb.poll()
+		switch key.Cmd {
+		case "interrupt":
+			return
+		case "ctrlC":
+			cancel()
+		default:
+			kb.held = append(kb.held, key)
+		}
+	}
+}
+
+// poll waits for the next keypress, paste, mouse event or interrupt.
+func (kb *Keyboard) poll() Key {
 	for {
 		ev := kb.screen.PollEvent()
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
-			return kb.GetCmdString(ev)
+			cmd, r := kb.GetCmdString(ev)
+			return Key{Cmd: cmd, Rune: r}
+		case *tcell.EventPaste:
+			if ev.Start() {
+				return Key{Cmd: "paste", Text: kb.readPaste()}
+			}
+		case *tcell.EventMouse:
+			if mouse, ok := kb.mouseEvent(ev); ok {
+				return Key{Cmd: "mouse", mouse: mouse}
+			}
+		case *tcell.EventInterrupt:
+			if fn, ok := ev.Data().(func()); ok {
+				fn()
+				continue
+			}
+			return Key{Cmd: "interrupt"}
 		case *tcell.EventResize:
 			kb.screen.Sync()
 		}
 	}
 }
 
-// Mock keyboard for testing.
+// Interrupt wakes up GetKey (from another goroutine), so that it returns
+// "interrupt".
+func (kb *Keyboard) Interrupt() {
+	kb.screen.PostEvent(tcell.NewEventInterrupt(nil))
+}
+
+// Post runs fn (from another goroutine) on the goroutine reading the keys,
+// the next time it waits in GetKey. This hands background work back to the
+// editor, without disturbing whatever is waiting for a key.
+func (kb *Keyboard) Post(fn func()) {
+	kb.screen.PostEventWait(tcell.NewEventInterrupt(fn))
+}
+
+// Mock keyboard for testing. Each "mouse" key takes the next of the
+// mouse events (see SetMice), and each "paste" key the next of the pastes
+// (see SetPastes).
 type MockKeyboard struct {
-	keys  []string
-	runes []rune
-	idx   int
+	keys   []string
+	runes  []rune
+	idx    int
+	mice   []Mouse
+	mouse  Mouse
+	pastes []string
+	pasted string
 }
 
 func NewMockKeyboard(keys []string, runes []rune) *MockKeyboard {
@@ -153,5 +293,33 @@ func (mkb *MockKeyboard) GetKey() (string, rune) {
 		r = mkb.runes[idx]
 	}
 	mkb.idx++
+	if key == "mouse" && len(mkb.mice) > 0 {
+		mkb.mouse = mkb.mice[0]
+		mkb.mice = mkb.mice[1:]
+	}
+	if key == "paste" && len(mkb.pastes) > 0 {
+		mkb.pasted = mkb.pastes[0]
+		mkb.pastes = mkb.pastes[1:]
+	}
 	return key, r
 }
+
+// SetMice sets the mouse events for the "mouse" keys.
+func (mkb *MockKeyboard) SetMice(mice ...Mouse) {
+	mkb.mice = mice
+}
+
+// Mouse returns the last mouse event.
+func (mkb *MockKeyboard) Mouse() Mouse {
+	return mkb.mouse
+}
+
+// SetPastes sets the text for the "paste" keys.
+func (mkb *MockKeyboard) SetPastes(pastes ...string) {
+	mkb.pastes = pastes
+}
+
+// Pasted returns the text of the last paste.
+func (mkb *MockKeyboard) Pasted() string {
+	return mkb.pasted
+}
@@ -0,0 +1,60 @@
+package terminal_test
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/terminal"
+)
+
+func TestPost(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 10)
+	sim := screen.GetTcell().(tcell.SimulationScreen)
+	kb := terminal.NewKeyboard()
+	kb.SetScreen(sim)
+
+	// Posted functions run while waiting for a key, and don't count as one.
+	ran := false
+	kb.Post(func() { ran = true })
+	sim.InjectKey(tcell.KeyRune, 'a', tcell.ModNone)
+	if cmd, r := kb.GetKey(); cmd != "char" || r != 'a' || !ran {
+		t.Errorf("expected the function to run before the key, got %s %q, %v", cmd, r, ran)
+	}
+
+	kb.Interrupt()
+	if cmd, _ := kb.GetKey(); cmd != "interrupt" {
+		t.Error("expected an interrupt, got", cmd)
+	}
+}
+
+func TestWait(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 10)
+	sim := screen.GetTcell().(tcell.SimulationScreen)
+	kb := terminal.NewKeyboard()
+	kb.SetScreen(sim)
+
+	// Keys typed while waiting are kept for later, apart from ctrl-C.
+	kb.Macros.Start()
+	sim.InjectKey(tcell.KeyRune, 'a', tcell.ModNone)
+	sim.InjectKey(tcell.KeyCtrlC, 0, tcell.ModCtrl)
+	sim.InjectMouse(3, 4, tcell.Button1, tcell.ModNone)
+	kb.Interrupt()
+	cancelled := 0
+	kb.Wait(func() { cancelled++ })
+	if cancelled != 1 {
+		t.Error("ctrl-C should cancel once, got", cancelled)
+	}
+	if kb.Macros.Len() != 0 {
+		t.Error("keys should not be recorded while waiting:", kb.Macros.Len())
+	}
+
+	if cmd, r := kb.GetKey(); cmd != "char" || r != 'a' {
+		t.Errorf("expected the held key, got %s %q", cmd, r)
+	}
+	if cmd, _ := kb.GetKey(); cmd != "mouse" || kb.Mouse().X != 3 || kb.Mouse().Y != 4 {
+		t.Error("expected the held mouse event, got", cmd, kb.Mouse())
+	}
+	if keys := kb.Macros.Stop(-1); len(keys) != 1 || keys[0].Rune != 'a' {
+		t.Error("the held key should be recorded once it is returned:", keys)
+	}
+}
@@ -0,0 +1,111 @@
+package terminal
+
+import (
+	"sync"
+)
+
+// Key is a keypress, as returned by GetKey. A paste carries the pasted
+// text.
+type Key struct {
+	Cmd  string `json:"cmd"`
+	Rune rune   `json:"rune,omitempty"`
+	Text string `json:"text,omitempty"`
+
+	// mouse is the mouse event, for a "mouse" key held back by Wait.
+	mouse Mouse
+}
+
+// Recorder records keypresses (for macros), and plays them back. Keys
+// being played back are returned by GetKey ahead of any real keypresses,
+// so they reach prompts and menus as well as the main key map.
+type Recorder struct {
+	recording bool
+	keys      []Key
+	queue     []Key
+	mutex     *sync.Mutex
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{mutex: &sync.Mutex{}}
+}
+
+// Start starts recording keypresses.
+func (rec *Recorder) Start() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.recording = true
+	rec.keys = []Key{}
+}
+
+// Stop stops recording, and returns the first n recorded keys (or all of
+// them, if n is negative). Dropping the trailing keys lets the caller
+// leave out the keys which stopped the recording.
+func (rec *Recorder) Stop(n int) []Key {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.recording = false
+	keys := rec.keys
+	if n >= 0 && n < len(keys) {
+		keys = keys[:n]
+	}
+	rec.keys = nil
+	return keys
+}
+
+// Recording returns true if keypresses are being recorded.
+func (rec *Recorder) Recording() bool {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return rec.recording
+}
+
+// Len returns the number of keys recorded so far.
+func (rec *Recorder) Len() int {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return len(rec.keys)
+}
+
+// Record records a keypress, if recording.
+func (rec *Recorder) Record(key Key) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if rec.recording {
+		rec.keys = append(rec.keys, key)
+	}
+}
+
+// Play queues keys to be played back. They go ahead of any keys already
+// queued, so that a macro which plays another macro works as expected.
+func (rec *Recorder) Play(keys []Key) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.queue = append(append([]Key{}, keys...), rec.queue...)
+}
+
+// Playing returns true if there are keys waiting to be played back.
+func (rec *Recorder) Playing() bool {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return len(rec.queue) > 0
+}
+
+// Cancel discards any keys waiting to be played back.
+func (rec *Recorder) Cancel() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.queue = nil
+}
+
+// Next returns the next key to be played back.
+func (rec *Recorder) Next() (Key, bool) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if len(rec.queue) == 0 {
+		return Key{}, false
+	}
+	key := rec.queue[0]
+	rec.queue = rec.queue[1:]
+	return key, true
+}
@@ -0,0 +1,41 @@
+package terminal_test
+
+import (
+	"testing"
+
+	"github.com/wx13/sith/terminal"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := terminal.NewRecorder()
+
+	// Keys are only recorded while recording.
+	rec.Record(terminal.Key{Cmd: "char", Rune: 'x'})
+	rec.Start()
+	rec.Record(terminal.Key{Cmd: "char", Rune: 'a'})
+	rec.Record(terminal.Key{Cmd: "enter"})
+	rec.Record(terminal.Key{Cmd: "alt6"})
+	keys := rec.Stop(2)
+	if len(keys) != 2 || keys[0].Rune != 'a' || keys[1].Cmd != "enter" {
+		t.Errorf("wrong keys recorded: %v", keys)
+	}
+	if rec.Recording() {
+		t.Error("should have stopped recording")
+	}
+
+	// Nested playback goes ahead of keys already queued.
+	rec.Play([]terminal.Key{{Cmd: "char", Rune: '1'}, {Cmd: "char", Rune: '2'}})
+	key, _ := rec.Next()
+	rec.Play([]terminal.Key{{Cmd: "char", Rune: 'n'}})
+	played := []rune{key.Rune}
+	for rec.Playing() {
+		key, _ := rec.Next()
+		played = append(played, key.Rune)
+	}
+	if string(played) != "1n2" {
+		t.Errorf("wrong playback order: %q", string(played))
+	}
+	if _, ok := rec.Next(); ok {
+		t.Error("queue should be empty")
+	}
+}
@@ -0,0 +1,60 @@
+package terminal
+
+import (
+	"github.com/gdamore/tcell/v2"
+)
+
+// MouseAction is what a mouse event does.
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseDrag
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// Mouse is a mouse event, at a terminal cell (X, Y). GetKey returns
+// "mouse" for these, and the keyboard's Mouse method has the details.
+type Mouse struct {
+	Action    MouseAction
+	X, Y      int
+	Ctrl, Alt bool
+}
+
+// mouseEvent turns a tcell mouse event into a Mouse. Terminals report which
+// buttons are down, so a press is told apart from a drag by whether the
+// button was already down. It returns false for events of no interest,
+// such as releases and motion with no button down.
+func (kb *Keyboard) mouseEvent(ev *tcell.EventMouse) (Mouse, bool) {
+	x, y := ev.Position()
+	mod := ev.Modifiers()
+	mouse := Mouse{
+		X:    x,
+		Y:    y,
+		Ctrl: mod&tcell.ModCtrl != 0,
+		Alt:  mod&tcell.ModAlt != 0,
+	}
+	buttons := ev.Buttons()
+	switch {
+	case buttons&tcell.WheelUp != 0:
+		mouse.Action = MouseWheelUp
+	case buttons&tcell.WheelDown != 0:
+		mouse.Action = MouseWheelDown
+	case buttons&tcell.Button1 != 0:
+		mouse.Action = MousePress
+		if kb.buttonDown {
+			mouse.Action = MouseDrag
+		}
+		kb.buttonDown = true
+	default:
+		kb.buttonDown = false
+		return mouse, false
+	}
+	return mouse, true
+}
+
+// Mouse returns the last mouse event (see GetKey).
+func (kb *Keyboard) Mouse() Mouse {
+	return kb.mouse
+}
@@ -0,0 +1,52 @@
+package terminal_test
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/terminal"
+)
+
+func TestMouse(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 10)
+	sim := screen.GetTcell().(tcell.SimulationScreen)
+	kb := terminal.NewKeyboard()
+	kb.SetScreen(sim)
+
+	// Motion with no button down, and releases, are left out.
+	sim.InjectMouse(1, 1, tcell.ButtonNone, tcell.ModNone)
+	sim.InjectMouse(3, 4, tcell.Button1, tcell.ModCtrl)
+	sim.InjectMouse(5, 6, tcell.Button1, tcell.ModNone)
+	sim.InjectMouse(5, 6, tcell.ButtonNone, tcell.ModNone)
+	sim.InjectMouse(2, 2, tcell.WheelDown, tcell.ModNone)
+	sim.InjectMouse(7, 8, tcell.Button1, tcell.ModAlt)
+	expected := []terminal.Mouse{
+		{Action: terminal.MousePress, X: 3, Y: 4, Ctrl: true},
+		{Action: terminal.MouseDrag, X: 5, Y: 6},
+		{Action: terminal.MouseWheelDown, X: 2, Y: 2},
+		{Action: terminal.MousePress, X: 7, Y: 8, Alt: true},
+	}
+	for _, exp := range expected {
+		cmd, _ := kb.GetKey()
+		if cmd != "mouse" || kb.Mouse() != exp {
+			t.Errorf("expected %v, got %s %v", exp, cmd, kb.Mouse())
+		}
+	}
+}
+
+func TestFromCell(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 10)
+	screen.SetGutterWidth(3)
+	if row, col, ok := screen.FromCell(5, 2); !ok || row != 2 || col != 2 {
+		t.Error("wrong position:", row, col, ok)
+	}
+
+	sub := screen.Sub()
+	sub.SetRegion(terminal.Region{Row: 1, Col: 10, Rows: 5, Cols: 10})
+	if row, col, ok := sub.FromCell(12, 3); !ok || row != 2 || col != 2-sub.GutterWidth() {
+		t.Error("wrong position in region:", row, col, ok)
+	}
+	if _, _, ok := sub.FromCell(5, 3); ok {
+		t.Error("cell should be outside of the region")
+	}
+}
@@ -0,0 +1,57 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// readPaste reads the keys of a bracketed paste (after the start of the
+// paste) up to the end of the paste, and returns them as text. Line
+// endings become newlines. Functions sent with Post are run as they come,
+// and an Interrupt is passed on once the paste is over.
+func (kb *Keyboard) readPaste() string {
+	var text strings.Builder
+	interrupted := false
+	defer func() {
+		if interrupted {
+			kb.Interrupt()
+		}
+	}()
+	for {
+		switch ev := kb.screen.PollEvent().(type) {
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyRune:
+				text.WriteRune(ev.Rune())
+			case tcell.KeyCR:
+				text.WriteRune('\r')
+			case tcell.KeyLF:
+				text.WriteRune('\n')
+			case tcell.KeyTab:
+				text.WriteRune('\t')
+			}
+		case *tcell.EventPaste:
+			if ev.End() {
+				str := strings.ReplaceAll(text.String(), "\r\n", "\n")
+				return strings.ReplaceAll(str, "\r", "\n")
+			}
+		case *tcell.EventInterrupt:
+			if fn, ok := ev.Data().(func()); ok {
+				fn()
+			} else {
+				interrupted = true
+			}
+		case *tcell.EventResize:
+			kb.screen.Sync()
+		case nil:
+			// The screen has been closed.
+			return text.String()
+		}
+	}
+}
+
+// Pasted returns the text of the last paste (see GetKey).
+func (kb *Keyboard) Pasted() string {
+	return kb.pasted
+}
@@ -0,0 +1,64 @@
+package terminal_test
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/terminal"
+)
+
+func TestPaste(t *testing.T) {
+	screen := terminal.NewSimulationScreen(20, 10)
+	sim := screen.GetTcell().(tcell.SimulationScreen)
+	kb := terminal.NewKeyboard()
+	kb.SetScreen(sim)
+
+	// The event queue is short, so feed it as the keyboard reads.
+	posted := false
+	go func() {
+		sim.PostEvent(tcell.NewEventPaste(true))
+		for _, r := range "if x {\r\n\ty()\r}" {
+			if r == 'y' {
+				// Posted functions run during the paste, and
+				// interrupts wait until it is over.
+				kb.Post(func() { posted = true })
+				sim.PostEventWait(tcell.NewEventInterrupt(nil))
+			}
+			switch r {
+			case '\r':
+				sim.InjectKey(tcell.KeyCR, 0, tcell.ModNone)
+			case '\n':
+				sim.InjectKey(tcell.KeyLF, 0, tcell.ModNone)
+			case '\t':
+				sim.InjectKey(tcell.KeyTab, 0, tcell.ModNone)
+			default:
+				sim.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+			}
+		}
+		sim.PostEvent(tcell.NewEventPaste(false))
+		sim.InjectKey(tcell.KeyRune, 'z', tcell.ModNone)
+	}()
+
+	kb.Macros.Start()
+	cmd, _ := kb.GetKey()
+	if cmd != "paste" || kb.Pasted() != "if x {\n\ty()\n}" {
+		t.Errorf("expected a paste, got %s %q", cmd, kb.Pasted())
+	}
+	if !posted {
+		t.Error("the posted function should have run during the paste")
+	}
+	keys := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		cmd, r := kb.GetKey()
+		keys[cmd+string(r)] = true
+	}
+	if !keys["charz"] || !keys["interrupt\x00"] {
+		t.Error("expected a keypress and an interrupt after the paste, got", keys)
+	}
+
+	// A paste plays back from a macro.
+	kb.Macros.Play(kb.Macros.Stop(1))
+	if cmd, _ := kb.GetKey(); cmd != "paste" || kb.Pasted() != "if x {\n\ty()\n}" {
+		t.Errorf("expected the paste to play back, got %s %q", cmd, kb.Pasted())
+	}
+}
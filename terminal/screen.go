@@ -8,6 +8,7 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
 	"github.com/wx13/sith/syntaxcolor"
+	"github.com/wx13/sith/theme"
 )
 
 type charMode int
@@ -36,6 +37,10 @@ const (
 	charModeFullUnicode
 )
 
+// indicatorWidth is the width of the indicator columns at the right of the
+// gutter: diff indicators (col 0) and code block bars (col 1).
+const indicatorWidth = 2
+
 // Screen is an interface the the terminal screen.
 type Screen struct {
 	row, col int
@@ -52,6 +57,16 @@ type Screen struct {
 
 	// gutterWidth reserves columns on the left for indicators (code blocks, git status, etc.)
 	gutterWidth int
+
+	// A sub-screen draws into a region of its parent's terminal.
+	parent *Screen
+	region *Region
+}
+
+// Region is a rectangle of the terminal, in terminal coordinates.
+type Region struct {
+	Row, Col   int
+	Rows, Cols int
 }
 
 // toStyle converts fg/bg Attributes to a tcell.Style.
@@ -81,6 +96,28 @@ func toStyle(fg, bg Attribute) tcell.Style {
 
 // NewScreen creates a new screen object.
 func NewScreen() *Screen {
+	tc, err := tcell.NewScreen()
+	if err != nil {
+		panic(err)
+	}
+	screen := newScreen(tc)
+	screen.tcell.EnableMouse()
+	screen.tcell.EnablePaste()
+	theme.SetColors(tc.Colors())
+	return screen
+}
+
+// NewSimulationScreen creates a screen which draws to memory instead of a
+// terminal (for testing).
+func NewSimulationScreen(cols, rows int) *Screen {
+	tc := tcell.NewSimulationScreen("UTF-8")
+	screen := newScreen(tc)
+	tc.SetSize(cols, rows)
+	return screen
+}
+
+// newScreen creates a screen object around a tcell screen.
+func newScreen(tc tcell.Screen) *Screen {
 	screen := Screen{
 		row:         0,
 		col:         0,
@@ -90,38 +127,139 @@ func NewScreen() *Screen {
 		dieChan:     make(chan struct{}, 1),
 		tbMutex:     &sync.Mutex{},
 		charMode:    charModeFullUnicode,
-		gutterWidth: 2, // Reserve 2 columns: diff indicators (col 0), code block bars (col 1)
+		gutterWidth: indicatorWidth,
 	}
 	screen.tbMutex.Lock()
-	var err error
-	screen.tcell, err = tcell.NewScreen()
-	if err != nil {
-		panic(err)
-	}
+	screen.tcell = tc
 	if err := screen.tcell.Init(); err != nil {
 		panic(err)
 	}
-	screen.tcell.EnableMouse()
 	screen.tbMutex.Unlock()
 	screen.handleRequests()
 	return &screen
 }
 
+// Sub creates a screen which draws into a region of this screen's terminal.
+// The region is set with SetRegion.
+func (screen *Screen) Sub() *Screen {
+	base := screen.base()
+	cols, rows := screen.TermSize()
+	return &Screen{
+		bg:          base.bg,
+		fg:          base.fg,
+		flushChan:   base.flushChan,
+		dieChan:     base.dieChan,
+		tbMutex:     base.tbMutex,
+		gutterWidth: screen.gutterWidth,
+		parent:      base,
+		region:      &Region{Rows: rows, Cols: cols},
+	}
+}
+
+// SetRegion sets the part of the terminal a sub-screen draws into.
+func (screen *Screen) SetRegion(region Region) {
+	if screen.parent == nil {
+		return
+	}
+	screen.tbMutex.Lock()
+	*screen.region = region
+	screen.tbMutex.Unlock()
+}
+
+// base returns the top-level screen, which owns the terminal.
+func (screen *Screen) base() *Screen {
+	if screen.parent != nil {
+		return screen.parent
+	}
+	return screen
+}
+
+// tc returns the underlying tcell screen.
+func (screen *Screen) tc() tcell.Screen {
+	return screen.base().tcell
+}
+
+// toCell converts a row and (text-area) column into terminal coordinates.
+// It returns false if the position falls outside of the screen's region.
+func (screen *Screen) toCell(row, col int) (int, int, bool) {
+	x := col + screen.gutterWidth
+	if x < 0 {
+		return 0, 0, false
+	}
+	if screen.region == nil {
+		return x, row, true
+	}
+	if x >= screen.region.Cols || row < 0 || row >= screen.region.Rows {
+		return 0, 0, false
+	}
+	return x + screen.region.Col, row + screen.region.Row, true
+}
+
+// FromCell converts terminal coordinates into a row and (text-area) column,
+// the reverse of toCell. Columns in the gutter are negative. It returns
+// false if the cell falls outside of the screen's region (but still gives
+// the position relative to the region).
+func (screen *Screen) FromCell(x, y int) (int, int, bool) {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	if screen.region != nil {
+		x -= screen.region.Col
+		y -= screen.region.Row
+		if x < 0 || y < 0 || x >= screen.region.Cols || y >= screen.region.Rows {
+			return y, x - screen.gutterWidth, false
+		}
+	}
+	return y, x - screen.gutterWidth, true
+}
+
+// termSize returns the size of the screen (or region) including the gutter.
+// The caller must hold the lock.
+func (screen *Screen) termSize() (int, int) {
+	if screen.region != nil {
+		return screen.region.Cols, screen.region.Rows
+	}
+	return screen.tc().Size()
+}
+
+// TermSize returns the size of the whole terminal.
+func (screen *Screen) TermSize() (int, int) {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	return screen.tc().Size()
+}
+
 // Size returns the usable screen size (col, row), accounting for the gutter.
 func (screen *Screen) Size() (int, int) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
-	cols, rows := screen.tcell.Size()
+	cols, rows := screen.termSize()
 	return cols - screen.gutterWidth, rows
 }
 
-// SetGutterWidth sets the width of the left gutter (reserved for indicators).
+// SetGutterWidth sets the width of the left gutter (reserved for indicators,
+// and optionally line numbers). It is at least indicatorWidth.
 func (screen *Screen) SetGutterWidth(width int) {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	if width < indicatorWidth {
+		width = indicatorWidth
+	}
 	screen.gutterWidth = width
 }
 
+// SetLineNumberWidth makes room in the gutter for line numbers of up to
+// width digits (or for none, if width is zero).
+func (screen *Screen) SetLineNumberWidth(width int) {
+	if width > 0 {
+		width++
+	}
+	screen.SetGutterWidth(indicatorWidth + width)
+}
+
 // GutterWidth returns the current gutter width.
 func (screen *Screen) GutterWidth() int {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
 	return screen.gutterWidth
 }
 
@@ -207,18 +345,22 @@ func (screen *Screen) SetCursor(r, c int) {
 	screen.row = r
 	screen.col = c
 	screen.tbMutex.Lock()
-	screen.tcell.ShowCursor(c+screen.gutterWidth, r)
+	if x, y, ok := screen.toCell(r, c); ok {
+		screen.tc().ShowCursor(x, y)
+	}
 	screen.tbMutex.Unlock()
 }
 
-// Clear clears the screen.
+// Clear clears the screen (or the sub-screen's region).
 func (screen *Screen) Clear() {
-	screen.tbMutex.Lock()
-	screen.tcell.Clear()
-	screen.tbMutex.Unlock()
+	if screen.region == nil {
+		screen.tbMutex.Lock()
+		screen.tcell.Clear()
+		screen.tbMutex.Unlock()
+	}
 	cols, rows := screen.Size()
 	for row := 0; row < rows; row++ {
-		screen.WriteString(row, 0, strings.Repeat(" ", cols))
+		screen.WriteString(row, -screen.gutterWidth, strings.Repeat(" ", cols+screen.gutterWidth))
 	}
 }
 
@@ -230,7 +372,7 @@ func (screen *Screen) ReallyClear() {
 		screen.WriteString(row, 0, strings.Repeat(".", cols))
 	}
 	screen.tbMutex.Lock()
-	screen.tcell.Show()
+	screen.tc().Show()
 	screen.tbMutex.Unlock()
 	for row := 0; row < rows; row++ {
 		screen.WriteString(row, 0, strings.Repeat(" ", cols))
@@ -242,11 +384,15 @@ func (screen *Screen) ReallyClear() {
 func (screen *Screen) DecorateStatusLine() {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
-	cols, rows := screen.tcell.Size()
-	style := tcell.StyleDefault.Foreground(tcell.ColorBlue)
-	for col := 0; col < cols; col++ {
-		mainc, combc, _, _ := screen.tcell.GetContent(col, rows-1)
-		screen.tcell.SetContent(col, rows-1, mainc, combc, style)
+	cols, rows := screen.termSize()
+	style := theme.Get("status").Style()
+	for col := -screen.gutterWidth; col < cols-screen.gutterWidth; col++ {
+		x, y, ok := screen.toCell(rows-1, col)
+		if !ok {
+			continue
+		}
+		mainc, combc, _, _ := screen.tc().GetContent(x, y)
+		screen.tc().SetContent(x, y, mainc, combc, style)
 	}
 }
 
@@ -259,14 +405,13 @@ func (screen *Screen) WriteString(row, col int, s string) {
 func (screen *Screen) Underline(row, start_col, end_col, offset int) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
-	cols, _ := screen.tcell.Size()
 	for col := start_col; col < end_col; col++ {
-		adjCol := col - offset + screen.gutterWidth
-		if adjCol > cols || adjCol < 0 {
+		x, y, ok := screen.toCell(row, col-offset)
+		if !ok {
 			continue
 		}
-		mainc, combc, style, _ := screen.tcell.GetContent(adjCol, row)
-		screen.tcell.SetContent(adjCol, row, mainc, combc, style.Underline(true))
+		mainc, combc, style, _ := screen.tc().GetContent(x, y)
+		screen.tc().SetContent(x, y, mainc, combc, style.Underline(true))
 	}
 }
 
@@ -274,36 +419,58 @@ func (screen *Screen) Underline(row, start_col, end_col, offset int) {
 func (screen *Screen) Colorize(row int, colors []syntaxcolor.LineColor, offset int) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
-	cols, _ := screen.tcell.Size()
 	for _, lc := range colors {
 		style := tcell.StyleDefault.Foreground(lc.Fg).Background(lc.Bg)
 		for col := lc.Start; col < lc.End; col++ {
-			adjCol := col - offset + screen.gutterWidth
-			if adjCol > cols || adjCol < 0 {
+			x, y, ok := screen.toCell(row, col-offset)
+			if !ok {
 				continue
 			}
-			mainc, combc, _, _ := screen.tcell.GetContent(adjCol, row)
-			screen.tcell.SetContent(adjCol, row, mainc, combc, style)
+			mainc, combc, _, _ := screen.tc().GetContent(x, y)
+			screen.tc().SetContent(x, y, mainc, combc, style)
 		}
 	}
 }
 
-// DrawLeftBar draws a vertical bar character in the second gutter column.
+// DrawLeftBar draws a vertical bar character in the second indicator column.
 // Used to visually indicate code blocks in markdown files.
 func (screen *Screen) DrawLeftBar(row int, color tcell.Color) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
 	style := tcell.StyleDefault.Foreground(color)
-	screen.tcell.SetContent(1, row, '▌', nil, style)
+	if x, y, ok := screen.toCell(row, 1-indicatorWidth); ok {
+		screen.tc().SetContent(x, y, '▌', nil, style)
+	}
 }
 
-// DrawGutterSymbol draws a symbol in the first gutter column.
+// DrawGutterSymbol draws a symbol in the first indicator column.
 // Used for diff indicators (new lines, changed lines).
 func (screen *Screen) DrawGutterSymbol(row int, symbol rune, color tcell.Color) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
 	style := tcell.StyleDefault.Foreground(color)
-	screen.tcell.SetContent(0, row, symbol, nil, style)
+	if x, y, ok := screen.toCell(row, -indicatorWidth); ok {
+		screen.tc().SetContent(x, y, symbol, nil, style)
+	}
+}
+
+// DrawLineNumber draws a line number in the gutter, to the left of the
+// indicator columns. It is right-aligned, with a space before the
+// indicators, and cut short if the gutter is too narrow.
+func (screen *Screen) DrawLineNumber(row int, num string, color tcell.Color) {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	style := tcell.StyleDefault.Foreground(color)
+	width := screen.gutterWidth - indicatorWidth - 1
+	if len(num) > width {
+		num = num[len(num)-width:]
+	}
+	col := -indicatorWidth - 1 - len(num)
+	for k, c := range num {
+		if x, y, ok := screen.toCell(row, col+k); ok {
+			screen.tc().SetContent(x, y, c, nil, style)
+		}
+	}
 }
 
 // PrintableRune uses the charMode to convert the rune into
@@ -312,17 +479,18 @@ func (screen *Screen) PrintableRune(c rune) (rune, int) {
 	if c < 32 {
 		return c, 0
 	}
-	if screen.charMode == charModeASCII {
+	mode := screen.base().charMode
+	if mode == charModeASCII {
 		if c >= 127 {
 			c = '*'
 		}
 	}
-	if screen.charMode == charModeSomeUnicode {
+	if mode == charModeSomeUnicode {
 		if c >= 734 {
 			c = 183
 		}
 	}
-	if screen.charMode == charModeNarrowUnicode {
+	if mode == charModeNarrowUnicode {
 		w := runewidth.RuneWidth(c)
 		if w != 1 {
 			c = 183
@@ -354,7 +522,9 @@ func (screen *Screen) WriteStringColor(row, col int, s string, fg, bg Attribute)
 		if n <= 0 {
 			continue
 		}
-		screen.tcell.SetContent(col+k+screen.gutterWidth, row, r, nil, style)
+		if x, y, ok := screen.toCell(row, col+k); ok {
+			screen.tc().SetContent(x, y, r, nil, style)
+		}
 		k += n
 	}
 }
@@ -383,9 +553,45 @@ func (screen *Screen) Alert(msg string) {
 func (screen *Screen) Highlight(row, col int) {
 	screen.tbMutex.Lock()
 	defer screen.tbMutex.Unlock()
-	adjCol := col + screen.gutterWidth
-	mainc, combc, style, _ := screen.tcell.GetContent(adjCol, row)
-	screen.tcell.SetContent(adjCol, row, mainc, combc, style.Reverse(true))
+	x, y, ok := screen.toCell(row, col)
+	if !ok {
+		return
+	}
+	mainc, combc, style, _ := screen.tc().GetContent(x, y)
+	screen.tc().SetContent(x, y, mainc, combc, style.Reverse(true))
+}
+
+// HighlightRole colors a range of columns in a row in the colors of a theme
+// role, keeping any colors which the role does not set.
+func (screen *Screen) HighlightRole(row, startCol, endCol int, role string) {
+	color := theme.Get(role)
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	for col := startCol; col <= endCol; col++ {
+		x, y, ok := screen.toCell(row, col)
+		if !ok {
+			continue
+		}
+		mainc, combc, style, _ := screen.tc().GetContent(x, y)
+		screen.tc().SetContent(x, y, mainc, combc, color.Overlay(style))
+	}
+}
+
+// RoleColors returns the foreground (with attributes) and background colors
+// of a theme role, for WriteStringColor and ColorRange.
+func RoleColors(role string) (Attribute, Attribute) {
+	color := theme.Get(role)
+	fg := Attribute(color.Fg)
+	if color.Attrs&tcell.AttrBold != 0 {
+		fg |= AttrBold
+	}
+	if color.Attrs&tcell.AttrReverse != 0 {
+		fg |= AttrReverse
+	}
+	if color.Attrs&tcell.AttrUnderline != 0 {
+		fg |= AttrUnderline
+	}
+	return fg, Attribute(color.Bg)
 }
 
 // HighlightRange reverses the screen color over a range of rows/columns.
@@ -394,9 +600,12 @@ func (screen *Screen) HighlightRange(startRow, endRow, startCol, endCol int) {
 	defer screen.tbMutex.Unlock()
 	for row := startRow; row <= endRow; row++ {
 		for col := startCol; col <= endCol; col++ {
-			adjCol := col + screen.gutterWidth
-			mainc, combc, style, _ := screen.tcell.GetContent(adjCol, row)
-			screen.tcell.SetContent(adjCol, row, mainc, combc, style.Reverse(true))
+			x, y, ok := screen.toCell(row, col)
+			if !ok {
+				continue
+			}
+			mainc, combc, style, _ := screen.tc().GetContent(x, y)
+			screen.tc().SetContent(x, y, mainc, combc, style.Reverse(true))
 		}
 	}
 }
@@ -408,16 +617,19 @@ func (screen *Screen) ColorRange(startRow, endRow, startCol, endCol int, fg, bg
 	style := toStyle(fg, bg)
 	for row := startRow; row <= endRow; row++ {
 		for col := startCol; col <= endCol; col++ {
-			adjCol := col + screen.gutterWidth
-			mainc, combc, _, _ := screen.tcell.GetContent(adjCol, row)
-			screen.tcell.SetContent(adjCol, row, mainc, combc, style)
+			x, y, ok := screen.toCell(row, col)
+			if !ok {
+				continue
+			}
+			mainc, combc, _, _ := screen.tc().GetContent(x, y)
+			screen.tc().SetContent(x, y, mainc, combc, style)
 		}
 	}
 }
 
 // SetCharMode sets the character display mode.
 func (screen *Screen) SetCharMode(c int) {
-	screen.charMode = charMode(c)
+	screen.base().charMode = charMode(c)
 }
 
 // ListCharModes lists the available character display modes.
@@ -432,5 +644,15 @@ func (screen *Screen) ListCharModes() []string {
 
 // GetTcell returns the underlying tcell.Screen for keyboard polling.
 func (screen *Screen) GetTcell() tcell.Screen {
-	return screen.tcell
+	return screen.tc()
+}
+
+// DrawVLine draws a vertical separator line, in terminal coordinates.
+func (screen *Screen) DrawVLine(col, row, rows int) {
+	screen.tbMutex.Lock()
+	defer screen.tbMutex.Unlock()
+	style := theme.Get("border").Style()
+	for r := row; r < row+rows; r++ {
+		screen.tc().SetContent(col, r, '│', nil, style)
+	}
 }
@@ -0,0 +1,287 @@
+// Package theme maps semantic roles (comment, string, gutter.added,
+// status.modified, selection, ...) to colors. Themes are TOML files: the
+// built-in ones, and any in ~/.config/sith/themes.
+package theme
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/config"
+)
+
+// DefaultName is the name of the default theme.
+const DefaultName = "default"
+
+//go:embed themes/*.toml
+var builtin embed.FS
+
+// Style is the look of a role, as written in a theme file.
+type Style struct {
+	FG    string
+	BG    string
+	Attrs []string
+}
+
+// Theme maps roles to styles.
+type Theme struct {
+	Name  string
+	Roles map[string]Style
+}
+
+// Color is the resolved look of a role. Default colors are left as they
+// are (e.g. by Overlay).
+type Color struct {
+	Fg, Bg tcell.Color
+	Attrs  tcell.AttrMask
+}
+
+// Style converts the color to a tcell style.
+func (color Color) Style() tcell.Style {
+	return tcell.StyleDefault.Foreground(color.Fg).Background(color.Bg).Attributes(color.Attrs)
+}
+
+// Overlay applies the color over an existing style, keeping the parts of
+// the style which the color does not set.
+func (color Color) Overlay(style tcell.Style) tcell.Style {
+	if color.Fg != tcell.ColorDefault {
+		style = style.Foreground(color.Fg)
+	}
+	if color.Bg != tcell.ColorDefault {
+		style = style.Background(color.Bg)
+	}
+	_, _, attrs := style.Decompose()
+	return style.Attributes(attrs | color.Attrs)
+}
+
+// state is the current theme, resolved for the terminal.
+type state struct {
+	theme  *Theme
+	colors map[string]Color
+	ncolor int
+	mutex  sync.Mutex
+}
+
+var current = &state{ncolor: 1 << 24}
+
+func init() {
+	theme, err := Load(DefaultName)
+	if err != nil {
+		panic(err)
+	}
+	Use(theme)
+}
+
+// Load reads a theme by name, from the user's theme directory or the
+// built-in themes. Roles which the theme leaves out are taken from the
+// default theme.
+func Load(name string) (*Theme, error) {
+	theme, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == DefaultName {
+		return theme, nil
+	}
+	def, err := read(DefaultName)
+	if err != nil {
+		return nil, err
+	}
+	for role, style := range def.Roles {
+		if _, ok := theme.Roles[role]; !ok {
+			theme.Roles[role] = style
+		}
+	}
+	return theme, nil
+}
+
+// read reads a theme file, without filling in missing roles.
+func read(name string) (*Theme, error) {
+	theme := &Theme{}
+	data, err := builtin.ReadFile("themes/" + name + ".toml")
+	if dir := userDir(); dir != "" {
+		if userData, userErr := os.ReadFile(filepath.Join(dir, name+".toml")); userErr == nil {
+			data, err = userData, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no such theme: %s", name)
+	}
+	if _, err := toml.Decode(string(data), theme); err != nil {
+		return nil, fmt.Errorf("theme %s: %v", name, err)
+	}
+	theme.Name = name
+	if theme.Roles == nil {
+		theme.Roles = map[string]Style{}
+	}
+	return theme, nil
+}
+
+// userDir returns the directory of the user's own themes.
+func userDir() string {
+	dir := config.ConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "themes")
+}
+
+// Names returns the names of the available themes.
+func Names() []string {
+	found := map[string]bool{}
+	entries, _ := builtin.ReadDir("themes")
+	if dir := userDir(); dir != "" {
+		userEntries, _ := os.ReadDir(dir)
+		entries = append(entries, userEntries...)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".toml") {
+			found[strings.TrimSuffix(entry.Name(), ".toml")] = true
+		}
+	}
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Use makes a theme the current theme.
+func Use(theme *Theme) {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	current.theme = theme
+	current.resolve()
+}
+
+// SetColors sets the number of colors the terminal can show. Truecolor
+// (hex) colors are shown as the nearest palette color on terminals with
+// fewer than 1<<24 colors.
+func SetColors(n int) {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	current.ncolor = n
+	current.resolve()
+}
+
+// resolve works out the colors of each role. The caller must hold the lock.
+func (st *state) resolve() {
+	st.colors = map[string]Color{}
+	for role, style := range st.theme.Roles {
+		st.colors[role] = Color{
+			Fg:    st.fit(ParseColor(style.FG)),
+			Bg:    st.fit(ParseColor(style.BG)),
+			Attrs: parseAttrs(style.Attrs),
+		}
+	}
+}
+
+// fit returns the nearest color the terminal can show.
+func (st *state) fit(color tcell.Color) tcell.Color {
+	if !color.IsRGB() || st.ncolor >= 1<<24 {
+		return color
+	}
+	n := st.ncolor
+	if n > 256 {
+		n = 256
+	}
+	palette := make([]tcell.Color, n)
+	for k := range palette {
+		palette[k] = tcell.PaletteColor(k)
+	}
+	return tcell.FindColor(color, palette)
+}
+
+// lookup returns the colors of a role. A role which is not in the theme
+// falls back to its parent (e.g. "status.modified" to "status"). The caller
+// must hold the lock.
+func (st *state) lookup(role string) (Color, bool) {
+	for {
+		if color, ok := st.colors[role]; ok {
+			return color, true
+		}
+		k := strings.LastIndex(role, ".")
+		if k < 0 {
+			return Color{}, false
+		}
+		role = role[:k]
+	}
+}
+
+// Name returns the name of the current theme.
+func Name() string {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	return current.theme.Name
+}
+
+// Get returns the colors of a role in the current theme.
+func Get(role string) Color {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	color, _ := current.lookup(role)
+	return color
+}
+
+// Fg returns a foreground color, given either a role or a color.
+func Fg(name string) tcell.Color {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	if color, ok := current.lookup(name); ok {
+		return color.Fg
+	}
+	return current.fit(ParseColor(name))
+}
+
+// Bg returns a background color, given either a role or a color.
+func Bg(name string) tcell.Color {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	if color, ok := current.lookup(name); ok {
+		return color.Bg
+	}
+	return current.fit(ParseColor(name))
+}
+
+// ParseColor converts a color name, 256-color palette number or "#rrggbb"
+// hex color to a tcell color. Unknown colors are the terminal's default.
+func ParseColor(name string) tcell.Color {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "default":
+		return tcell.ColorDefault
+	case "cyan":
+		return tcell.ColorTeal
+	case "magenta":
+		return tcell.ColorPurple
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < 256 {
+		return tcell.PaletteColor(n)
+	}
+	return tcell.GetColor(name)
+}
+
+// parseAttrs converts attribute names to a tcell attribute mask.
+func parseAttrs(names []string) tcell.AttrMask {
+	var attrs tcell.AttrMask
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "bold":
+			attrs |= tcell.AttrBold
+		case "reverse":
+			attrs |= tcell.AttrReverse
+		case "underline":
+			attrs |= tcell.AttrUnderline
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,70 @@
+package theme_test
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/wx13/sith/theme"
+)
+
+func TestParseColor(t *testing.T) {
+	colors := map[string]tcell.Color{
+		"":        tcell.ColorDefault,
+		"default": tcell.ColorDefault,
+		"red":     tcell.ColorRed,
+		"cyan":    tcell.ColorTeal,
+		"Magenta": tcell.ColorPurple,
+		"42":      tcell.PaletteColor(42),
+		"#ff8800": tcell.NewHexColor(0xff8800),
+		"bogus":   tcell.ColorDefault,
+	}
+	for name, expected := range colors {
+		if color := theme.ParseColor(name); color != expected {
+			t.Errorf("%q: expected %v, got %v", name, expected, color)
+		}
+	}
+}
+
+func TestThemes(t *testing.T) {
+	defer func() {
+		def, _ := theme.Load(theme.DefaultName)
+		theme.Use(def)
+		theme.SetColors(1 << 24)
+	}()
+
+	names := theme.Names()
+	if len(names) < 2 || names[0] != "default" {
+		t.Errorf("expected the built-in themes, got %v", names)
+	}
+	if _, err := theme.Load("no-such-theme"); err == nil {
+		t.Error("expected an error for a missing theme")
+	}
+
+	if theme.Fg("comment") != tcell.ColorTeal {
+		t.Error("wrong default comment color:", theme.Fg("comment"))
+	}
+	if theme.Get("status.no-such-role") != theme.Get("status") {
+		t.Error("roles should fall back to their parent")
+	}
+	if theme.Fg("red") != tcell.ColorRed {
+		t.Error("colors which are not roles should be parsed")
+	}
+
+	dark, err := theme.Load("solarized-dark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme.Use(dark)
+	if theme.Name() != "solarized-dark" {
+		t.Error("wrong theme name:", theme.Name())
+	}
+	if theme.Fg("comment") != tcell.NewHexColor(0x586e75) {
+		t.Error("expected truecolor, got", theme.Fg("comment"))
+	}
+
+	// On a 256-color terminal, hex colors become palette colors.
+	theme.SetColors(256)
+	if color := theme.Fg("comment"); color.IsRGB() || !color.Valid() {
+		t.Error("expected a palette color, got", color)
+	}
+}
@@ -25,7 +25,7 @@ func NewMenu(screen Screen, keyboard Keyboard) *Menu {
 	menu.screen = screen
 	menu.keyboard = keyboard
 	menu.setDims()
-	menu.borderColor = terminal.ColorBlue
+	menu.borderColor, _ = terminal.RoleColors("border")
 	menu.selections = []int{}
 	return &menu
 }
@@ -63,7 +63,8 @@ func (menu *Menu) Clear() {
 
 func (menu *Menu) showSearchStr(searchStr string) {
 	borderColor := menu.borderColor
-	menu.screen.WriteStringColor(menu.row0-1, menu.col0, searchStr, terminal.ColorWhite|terminal.AttrBold, borderColor)
+	titleColor, _ := terminal.RoleColors("title")
+	menu.screen.WriteStringColor(menu.row0-1, menu.col0, searchStr, titleColor, borderColor)
 }
 
 // Show displays a menu of choices on the screen.
@@ -88,7 +89,8 @@ func (menu *Menu) Show(choices []string) {
 	}
 	for _, row := range menu.selections {
 		r := menu.row0 + row - menu.rowShift
-		menu.screen.ColorRange(r, r, menu.col0, menu.col0+menu.cols-1, terminal.ColorGreen, terminal.ColorDefault)
+		fg, bg := terminal.RoleColors("menu.selected")
+		menu.screen.ColorRange(r, r, menu.col0, menu.col0+menu.cols-1, fg, bg)
 	}
 	r := menu.row0 + menu.cursor - menu.rowShift
 	menu.screen.HighlightRange(r, r, menu.col0, menu.col0+menu.cols-1)
@@ -188,6 +190,10 @@ func (menu *Menu) ChooseMulti(choices []string, idx int, searchStr string,
 			menu.toggleSelection(menu.cursor)
 		case "altC":
 			menu.selections = []int{}
+		case "mouse":
+			if menu.mouse(len(choices)) {
+				return menu.appendSelection(menu.cursor), ""
+			}
 		default:
 		}
 		// User keys
@@ -199,6 +205,112 @@ func (menu *Menu) ChooseMulti(choices []string, idx int, searchStr string,
 	}
 }
 
+// ChooseLive is like Choose, except that the choices come from a filter
+// function, which is re-run on the search string after every keypress.
+// It returns the chosen string ("" if there are no choices), and the key
+// that caused the menu to exit.
+func (menu *Menu) ChooseLive(filter func(string) []string, searchStr string,
+	keys ...string) (string, string) {
+
+	menu.cursor = 0
+	for {
+		menu.Clear()
+		choices := filter(searchStr)
+		menu.choices = choices
+		menu.setDims()
+		if menu.cursor >= len(choices) {
+			menu.cursor = len(choices) - 1
+		}
+		if menu.cursor < 0 {
+			menu.cursor = 0
+		}
+		chosen := ""
+		if len(choices) > 0 {
+			chosen = choices[menu.cursor]
+		}
+		menu.Show(choices)
+		menu.showSearchStr(searchStr)
+		menu.screen.Flush()
+		cmd, r := menu.keyboard.GetKey()
+		switch cmd {
+		case "enter":
+			return chosen, ""
+		case "ctrlC":
+			return chosen, "cancel"
+		case "arrowDown":
+			if menu.cursor < len(choices)-1 {
+				menu.cursor++
+			}
+		case "arrowUp":
+			if menu.cursor > 0 {
+				menu.cursor--
+			}
+		case "pageDown":
+			menu.cursor += 10
+		case "pageUp":
+			menu.cursor -= 10
+		case "char":
+			searchStr += string(r)
+			menu.cursor = 0
+		case "space":
+			searchStr += " "
+			menu.cursor = 0
+		case "backspace":
+			if len(searchStr) > 0 {
+				rs := []rune(searchStr)
+				searchStr = string(rs[:len(rs)-1])
+				menu.cursor = 0
+			}
+		case "ctrlU":
+			searchStr = ""
+			menu.cursor = 0
+		case "mouse":
+			if menu.mouse(len(choices)) {
+				return choices[menu.cursor], ""
+			}
+		default:
+		}
+		for _, key := range keys {
+			if cmd == key {
+				return chosen, key
+			}
+		}
+	}
+}
+
+// mouse handles a mouse event in a menu of n choices. The wheel moves the
+// cursor, and clicking on a choice picks it (returning true).
+func (menu *Menu) mouse(n int) bool {
+	kb, ok := menu.keyboard.(MouseKeyboard)
+	if !ok {
+		return false
+	}
+	mouse := kb.Mouse()
+	switch mouse.Action {
+	case terminal.MouseWheelUp:
+		if menu.cursor > 0 {
+			menu.cursor--
+		}
+	case terminal.MouseWheelDown:
+		if menu.cursor < n-1 {
+			menu.cursor++
+		}
+	case terminal.MousePress:
+		screen, ok := menu.screen.(MouseScreen)
+		if !ok {
+			return false
+		}
+		row, col, ok := screen.FromCell(mouse.X, mouse.Y)
+		idx := menu.rowShift + row - menu.row0
+		if ok && row >= menu.row0 && row < menu.row0+menu.rows &&
+			col >= menu.col0 && col < menu.col0+menu.cols && idx < n {
+			menu.cursor = idx
+			return true
+		}
+	}
+	return false
+}
+
 // Search searches menu options for a partial string match.
 func (menu *Menu) Search(choices []string, searchStr string) int {
 	for index := 0; index < len(choices); index++ {
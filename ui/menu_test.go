@@ -3,6 +3,7 @@ package ui_test
 import (
 	"github.com/wx13/sith/terminal"
 	"github.com/wx13/sith/ui"
+	"strings"
 	"testing"
 )
 
@@ -80,3 +81,88 @@ func TestMenuChoose(t *testing.T) {
 	}
 
 }
+
+func TestMenuChooseLive(t *testing.T) {
+
+	screen := MockScreen{}
+	choices := []string{"zero", "one", "two", "three"}
+	filter := func(str string) []string {
+		matches := []string{}
+		for _, choice := range choices {
+			if strings.Contains(choice, str) {
+				matches = append(matches, choice)
+			}
+		}
+		return matches
+	}
+
+	kb := terminal.NewMockKeyboard(
+		[]string{"char", "arrowDown", "enter"},
+		[]rune{'e', 0, 0},
+	)
+	menu := ui.NewMenu(screen, kb)
+	chosen, ans := menu.ChooseLive(filter, "")
+	if ans != "" || chosen != "one" {
+		t.Error("Expected 'one', '', got", chosen, ans)
+	}
+
+	kb = terminal.NewMockKeyboard(
+		[]string{"char", "backspace", "char", "ctrlO"},
+		[]rune{'x', 0, 'w', 0},
+	)
+	menu = ui.NewMenu(screen, kb)
+	chosen, ans = menu.ChooseLive(filter, "t", "ctrlO")
+	if ans != "ctrlO" || chosen != "two" {
+		t.Error("Expected 'two', 'ctrlO', got", chosen, ans)
+	}
+
+	kb = terminal.NewMockKeyboard([]string{"char", "enter"}, []rune{'q'})
+	menu = ui.NewMenu(screen, kb)
+	chosen, _ = menu.ChooseLive(filter, "")
+	if chosen != "" {
+		t.Error("Expected no choice, got", chosen)
+	}
+
+}
+
+// MockMouseScreen is a MockScreen with no gutter.
+type MockMouseScreen struct {
+	MockScreen
+}
+
+func (ms MockMouseScreen) FromCell(x, y int) (int, int, bool) {
+	return y, x, true
+}
+
+func TestMenuMouse(t *testing.T) {
+
+	screen := MockMouseScreen{}
+	choices := []string{"zero", "one", "two", "three"}
+
+	// Clicking outside of the menu does nothing; clicking a choice picks
+	// it. The menu starts four rows and columns in.
+	kb := terminal.NewMockKeyboard([]string{"mouse", "mouse"}, []rune{})
+	kb.SetMice(
+		terminal.Mouse{Action: terminal.MousePress, X: 1, Y: 5},
+		terminal.Mouse{Action: terminal.MousePress, X: 5, Y: 6},
+	)
+	menu := ui.NewMenu(screen, kb)
+	idx, ans := menu.Choose(choices, 0, "")
+	if ans != "" || idx != 2 {
+		t.Error("Expected 2, '', got", idx, ans)
+	}
+
+	// The wheel moves the cursor.
+	kb = terminal.NewMockKeyboard([]string{"mouse", "mouse", "mouse", "enter"}, []rune{})
+	kb.SetMice(
+		terminal.Mouse{Action: terminal.MouseWheelDown},
+		terminal.Mouse{Action: terminal.MouseWheelDown},
+		terminal.Mouse{Action: terminal.MouseWheelUp},
+	)
+	menu = ui.NewMenu(screen, kb)
+	idx, _ = menu.Choose(choices, 0, "")
+	if idx != 1 {
+		t.Error("Expected 1, got", idx)
+	}
+
+}
@@ -177,6 +177,13 @@ loop:
 		case "space":
 			prompt.answer = prompt.answer[:prompt.col] + " " + prompt.answer[prompt.col:]
 			prompt.col++
+		case "paste":
+			// Only the first line: the answer is a single line.
+			if kb, ok := prompt.keyboard.(PasteKeyboard); ok {
+				text, _, _ := strings.Cut(kb.Pasted(), "\n")
+				prompt.answer = prompt.answer[:prompt.col] + text + prompt.answer[prompt.col:]
+				prompt.col += len(text)
+			}
 		default:
 		}
 	}
@@ -49,3 +49,16 @@ func TestPromptAskYesNo(t *testing.T) {
 	}
 
 }
+
+func TestPromptPaste(t *testing.T) {
+	kb := terminal.NewMockKeyboard(
+		[]string{"char", "paste", "enter"},
+		[]rune{'a', 0, 0},
+	)
+	kb.SetPastes("bc\nd")
+	prompt := ui.MakePrompt(MockScreen{}, kb)
+	answer, err := prompt.Ask("What?", nil)
+	if err != nil || answer != "abc" {
+		t.Errorf("expected the first line of the paste, got %q, %v", answer, err)
+	}
+}
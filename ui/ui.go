@@ -21,3 +21,23 @@ type Screen interface {
 type Keyboard interface {
 	GetKey() (string, rune)
 }
+
+// MouseKeyboard is a Keyboard which reports mouse events (as the "mouse"
+// key).
+type MouseKeyboard interface {
+	Keyboard
+	Mouse() terminal.Mouse
+}
+
+// PasteKeyboard is a Keyboard which reports text pasted into the terminal
+// (as the "paste" key).
+type PasteKeyboard interface {
+	Keyboard
+	Pasted() string
+}
+
+// MouseScreen is a Screen which can find the position of a terminal cell.
+type MouseScreen interface {
+	Screen
+	FromCell(x, y int) (int, int, bool)
+}